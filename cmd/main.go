@@ -16,17 +16,18 @@ type Params struct {
 type Options struct {
 	Params
 
-	ServerCmd         cmd.ServerCommand            `command:"server"`
-	FillItems         cmd.FillItemsCommand         `command:"fill_items"`
-	FillRecipes       cmd.FillRecipesCommand       `command:"fill_recipes"`
-	FillInGameRecipes cmd.FillInGameRecipesCommand `command:"fill_from_game"`
+	ServerCmd     cmd.ServerCommand        `command:"server"`
+	Import        cmd.ImportCommand        `command:"import" description:"Import items and recipes from dumps and datapacks"`
+	RecipesExport cmd.RecipesExportCommand `command:"recipes-export" description:"Export recipes bundle"`
+	RecipesImport cmd.RecipesImportCommand `command:"recipes-import" description:"Import recipes bundle"`
+	Replay        cmd.ReplayCommand        `command:"replay"`
 }
 
 func main() {
 	var options Options
 	p := flags.NewParser(&options, flags.Default)
 	p.CommandHandler = func(command flags.Commander, args []string) error {
-		if c, ok := command.(*cmd.ServerCommand); ok {
+		if c, ok := command.(cmd.ParamsCommand); ok {
 			c.SetParams(&cmd.ServerCommandParameters{
 				Config: options.Config,
 				DB:     options.DB,
@@ -7,6 +7,7 @@ import (
 	"github.com/asek-ll/aecc-server/internal/dao"
 	"github.com/asek-ll/aecc-server/internal/services/clients"
 	"github.com/asek-ll/aecc-server/internal/services/clientscripts"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
 	"github.com/asek-ll/aecc-server/internal/services/crafter"
 	"github.com/asek-ll/aecc-server/internal/services/item"
 	"github.com/asek-ll/aecc-server/internal/services/modem"
@@ -15,6 +16,7 @@ import (
 	"github.com/asek-ll/aecc-server/internal/services/storage"
 	"github.com/asek-ll/aecc-server/internal/services/worker"
 	"github.com/asek-ll/aecc-server/internal/wsmethods"
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
 )
 
 type App struct {
@@ -35,4 +37,6 @@ type App struct {
 	ItemManager                *item.ItemManager
 	ScriptsManager             *clientscripts.ScriptsManager
 	ClientsService             *clients.ClientsService
+	Recorder                   wsrpc.Recorder
+	CondService                *cond.CondService
 }
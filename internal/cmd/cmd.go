@@ -3,3 +3,8 @@ package cmd
 type Command interface {
 	Exec(args []string) error
 }
+
+// ParamsCommand is command using global config and database params
+type ParamsCommand interface {
+	SetParams(params *ServerCommandParameters)
+}
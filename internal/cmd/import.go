@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/config"
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/recipe"
+	"github.com/jessevdk/go-flags"
+)
+
+var _ flags.Commander = &ImportCommand{}
+
+// ImportCommand imports items and recipes from files, directories are walked
+// for JSON files and datapack archives
+type ImportCommand struct {
+	params *ServerCommandParameters
+
+	Args struct {
+		Paths []string `positional-arg-name:"path" required:"1"`
+	} `positional-args:"yes"`
+}
+
+func (c *ImportCommand) SetParams(params *ServerCommandParameters) {
+	c.params = params
+}
+
+func (c *ImportCommand) readSources() ([]recipe.ImportSource, error) {
+	var sources []recipe.ImportSource
+	for _, root := range c.Args.Paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if path != root && !strings.HasSuffix(path, ".json") && !strings.HasSuffix(path, ".zip") {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fileSources, err := recipe.ReadImportSources(path, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			sources = append(sources, fileSources...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+func (c *ImportCommand) Execute(args []string) error {
+	configLoader, err := config.NewConfigLoader(c.params.Config)
+	if err != nil {
+		return err
+	}
+
+	daos, err := dao.NewDaoProvider(c.params.DB)
+	if err != nil {
+		return err
+	}
+
+	sources, err := c.readSources()
+	if err != nil {
+		return err
+	}
+
+	report, err := recipe.NewRecipeManager(daos).ImportRecipeBook(sources, configLoader.Config.RecipeImport.Types)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Items: %d, tags: %d, recipes: %d, book recipes: %d, existing: %d, skipped: %d\n",
+		report.Items, report.Tags, report.Recipes, report.ImportedRecipes, report.Existing, len(report.Skipped))
+	for _, skip := range report.Skipped {
+		fmt.Printf("SKIP %s: %s\n", skip.Source, skip.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/recipe"
+	"github.com/jessevdk/go-flags"
+)
+
+var _ flags.Commander = &RecipesExportCommand{}
+var _ flags.Commander = &RecipesImportCommand{}
+
+// RecipesExportCommand writes recipe bundle to file or stdout
+type RecipesExportCommand struct {
+	params *ServerCommandParameters
+
+	Type   *string `long:"type" description:"Export recipes of type only"`
+	Name   string  `long:"name" description:"Export recipes with name containing value"`
+	Output string  `short:"o" long:"output" description:"Bundle file, stdout by default"`
+}
+
+func (c *RecipesExportCommand) SetParams(params *ServerCommandParameters) {
+	c.params = params
+}
+
+func (c *RecipesExportCommand) Execute(args []string) error {
+	daos, err := dao.NewDaoProvider(c.params.DB)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := recipe.NewRecipeManager(daos).ExportRecipeBundle(recipe.RecipeBundleFilter{
+		Type: c.Type,
+		Name: c.Name,
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(c.Output, data, 0644)
+}
+
+// RecipesImportCommand imports recipe bundle, changes are only printed with
+// dry run
+type RecipesImportCommand struct {
+	params *ServerCommandParameters
+
+	Conflict string `long:"conflict" description:"Handling of changed recipes with same name" choice:"skip" choice:"overwrite" choice:"rename" default:"skip"`
+	DryRun   bool   `long:"dry-run" description:"Show changes without applying them"`
+
+	Args struct {
+		File string `positional-arg-name:"file" required:"1"`
+	} `positional-args:"yes"`
+}
+
+func (c *RecipesImportCommand) SetParams(params *ServerCommandParameters) {
+	c.params = params
+}
+
+func (c *RecipesImportCommand) Execute(args []string) error {
+	data, err := os.ReadFile(c.Args.File)
+	if err != nil {
+		return err
+	}
+
+	daos, err := dao.NewDaoProvider(c.params.DB)
+	if err != nil {
+		return err
+	}
+	manager := recipe.NewRecipeManager(daos)
+
+	plan, err := manager.PlanRecipeBundleImport(data, c.Conflict)
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range plan.Items {
+		fmt.Printf("item %s: add\n", uid)
+	}
+	for _, name := range plan.RecipeTypes {
+		fmt.Printf("recipe type %s: add\n", name)
+	}
+	for _, change := range plan.Changes {
+		if change.NewName != "" {
+			fmt.Printf("recipe %s: %s as %s\n", change.Name, change.Action, change.NewName)
+		} else {
+			fmt.Printf("recipe %s: %s\n", change.Name, change.Action)
+		}
+	}
+	for _, errMsg := range plan.Errors {
+		fmt.Printf("ERROR %s\n", errMsg)
+	}
+
+	if c.DryRun {
+		return nil
+	}
+	return manager.ApplyRecipeBundleImport(plan)
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/jessevdk/go-flags"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var _ flags.Commander = &ReplayCommand{}
+
+// ReplayCommand plays recorded client side of rpc session against running
+// server: client requests are sent in recorded order and server requests are
+// answered with recorded responses for the same method
+type ReplayCommand struct {
+	Url      string        `long:"url" description:"Server websocket url" default:"ws://localhost:8080/ws/"`
+	Secret   string        `long:"secret" description:"Client secret" required:"true"`
+	Token    string        `long:"token" description:"Client login token"`
+	File     string        `long:"file" description:"Records file written by file recorder"`
+	DB       string        `long:"db" description:"Database with recorded rpc_records"`
+	Client   string        `long:"client" description:"Recorded client ID" required:"true"`
+	Session  uint          `long:"session" description:"Recorded websocket client ID, first recorded session by default"`
+	Realtime bool          `long:"realtime" description:"Keep recorded delays between client requests"`
+	Wait     time.Duration `long:"wait" description:"Time to wait for server requests after last client request" default:"10s"`
+}
+
+type replayMessage struct {
+	JsonRpc string          `json:"jsonrpc"`
+	ID      *uint           `json:"id,omitempty"`
+	Method  *string         `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+type replaySession struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	// recorded client responses to server requests by method
+	responses   map[string][]*replayMessage
+	responsesMu sync.Mutex
+}
+
+func (c *ReplayCommand) loadRecords() ([]dao.RpcRecord, error) {
+	filter := dao.RpcRecordFilter{ClientID: c.Client}
+	if c.File != "" {
+		return wsrpc.ReadRecordsFile(c.File, filter)
+	}
+	if c.DB != "" {
+		db, err := sql.Open("sqlite3", c.DB)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		recordsDao, err := dao.NewRpcRecordsDao(db)
+		if err != nil {
+			return nil, err
+		}
+		return recordsDao.Query(filter)
+	}
+	return nil, errors.New("one of --file or --db is required")
+}
+
+func (c *ReplayCommand) selectSession(records []dao.RpcRecord) []dao.RpcRecord {
+	session := c.Session
+	if session == 0 && len(records) > 0 {
+		session = records[0].WsClientID
+	}
+	var result []dao.RpcRecord
+	for _, record := range records {
+		if record.WsClientID == session {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+func (c *ReplayCommand) Execute(args []string) error {
+	records, err := c.loadRecords()
+	if err != nil {
+		return err
+	}
+	records = c.selectSession(records)
+	if len(records) == 0 {
+		return fmt.Errorf("no records for client %s", c.Client)
+	}
+
+	session := &replaySession{
+		responses: make(map[string][]*replayMessage),
+	}
+	var requests []dao.RpcRecord
+	for _, record := range records {
+		if record.Direction != wsrpc.RECORD_DIRECTION_IN {
+			continue
+		}
+		var msg replayMessage
+		err := json.Unmarshal(record.Payload, &msg)
+		if err != nil {
+			return fmt.Errorf("invalid record payload: %w", err)
+		}
+		if msg.Method != nil {
+			requests = append(requests, record)
+		} else {
+			session.responses[record.Method] = append(session.responses[record.Method], &msg)
+		}
+	}
+	log.Printf("[INFO] Replay %d client requests and %d responses from session %d", len(requests), len(records)-len(requests), records[0].WsClientID)
+
+	dialer := ws.Dialer{
+		Header: ws.HandshakeHeaderHTTP(http.Header{"X-Client-Secret": []string{c.Secret}}),
+	}
+	conn, _, _, err := dialer.Dial(context.Background(), c.Url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	session.conn = conn
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.listen()
+	}()
+
+	for i, record := range requests {
+		if c.Realtime && i > 0 {
+			time.Sleep(record.Time.Sub(requests[i-1].Time))
+		}
+		payload := record.Payload
+		if record.Method == "login" {
+			payload, err = c.withToken(payload)
+			if err != nil {
+				return err
+			}
+		}
+		log.Printf("[INFO] -> %s", payload)
+		err := session.write(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(c.Wait):
+	}
+
+	for method, rest := range session.responses {
+		if len(rest) > 0 {
+			log.Printf("[WARN] %d recorded responses for %s were not requested by server", len(rest), method)
+		}
+	}
+	return nil
+}
+
+// withToken replaces redacted login token with provided one
+func (c *ReplayCommand) withToken(payload []byte) ([]byte, error) {
+	var msg map[string]any
+	err := json.Unmarshal(payload, &msg)
+	if err != nil {
+		return nil, err
+	}
+	params, ok := msg["params"].(map[string]any)
+	if !ok {
+		return payload, nil
+	}
+	if c.Token != "" {
+		params["token"] = c.Token
+	} else {
+		delete(params, "token")
+	}
+	return json.Marshal(msg)
+}
+
+func (s *replaySession) write(payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return wsutil.WriteClientText(s.conn, payload)
+}
+
+func (s *replaySession) listen() error {
+	for {
+		data, err := wsutil.ReadServerText(s.conn)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] <- %s", data)
+
+		var msg replayMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			log.Printf("[WARN] Invalid server message: %v", err)
+			continue
+		}
+		if msg.Method == nil || msg.ID == nil {
+			continue
+		}
+
+		if *msg.Method == "setToken" {
+			// replay must not store rotated token, as real client won't know it
+			err = s.refuseToken(*msg.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		s.responsesMu.Lock()
+		queue := s.responses[*msg.Method]
+		var response *replayMessage
+		if len(queue) > 0 {
+			response = queue[0]
+			s.responses[*msg.Method] = queue[1:]
+		}
+		s.responsesMu.Unlock()
+
+		if response == nil {
+			log.Printf("[WARN] No recorded response for %s", *msg.Method)
+			continue
+		}
+
+		response.ID = msg.ID
+		payload, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] -> %s", payload)
+		err = s.write(payload)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *replaySession) refuseToken(id uint) error {
+	payload, err := json.Marshal(&replayMessage{
+		JsonRpc: "2.0",
+		ID:      &id,
+		Result:  json.RawMessage("false"),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] -> %s", payload)
+	return s.write(payload)
+}
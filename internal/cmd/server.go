@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -57,49 +58,69 @@ func (s *ServerCommand) Execute(args []string) error {
 	}
 
 	wsServer := ws.NewServer(128, 1, time.Millisecond*1000)
+	wsServer.SetCompression(configLoader.Config.ClientServer.Compression)
 	rpcServer := wsrpc.NewServer(wsServer)
+	rpcServer.SetQueueLimits(configLoader.Config.ClientServer.MaxInFlight, configLoader.Config.ClientServer.MaxQueued)
+
+	recorder, err := createRecorder(configLoader.Config.ClientServer.Recorder, daos)
+	if err != nil {
+		return err
+	}
+	if recorder != nil {
+		rpcServer.SetRecorder(recorder)
+	}
 
 	scriptsmanager := clientscripts.NewScriptsManager(daos)
-	clientsService := clients.NewClientsService(daos.Clients)
+	clientsService := clients.NewClientsService(daos.Clients, daos.ClientAuth, configLoader.Config.WebServer.Auth.RequireEnrollment)
 
-	clientsManager := wsmethods.NewClientsManager(rpcServer, daos.Clients, configLoader, scriptsmanager)
+	clientsManager := wsmethods.NewClientsManager(rpcServer, daos.Clients, configLoader, scriptsmanager, clientsService)
 	storageAdapter := wsmethods.NewStorageAdapter(clientsManager)
 
 	storageService := storage.NewStorage(daos, storageAdapter)
-	playerManager := player.NewPlayerManager(daos, clientsManager, storageService)
+	condService := cond.NewCondService(clientsManager, storageService, daos)
+	transferTransationManager := storage.NewTransferTransactionManager(configLoader, daos.StoredTX, storageAdapter, storageService)
+	playerManager := player.NewPlayerManager(daos, clientsManager, storageService, transferTransationManager, condService)
 	itemManager := item.NewItemManager(daos)
 
 	plannerService := crafter.NewPlanner(daos, storageService)
 	recipeManager := recipe.NewRecipeManager(daos)
-	workerFactory := crafter.NewWorkerFactory(storageService, daos)
+	workerFactory := crafter.NewWorkerFactory(storageService, transferTransationManager, daos)
 	crafterService := crafter.NewCrafter(daos, plannerService, workerFactory, storageService)
 	modemManager := modem.NewModemManager(clientsManager)
-	transferTransationManager := storage.NewTransferTransactionManager(configLoader, daos.StoredTX, storageAdapter, storageService)
 
 	stateUpdater := crafter.NewStateUpdater(storageService, daos, crafterService)
 	stateUpdater.Start()
 
-	exporterWorker := worker.NewExporterWorker(*storageService)
-	importerWorker := worker.NewImporterWorker(*storageService)
-	fluidImporterWorker := worker.NewFluidImporterWorker(*storageService, configLoader.Config.Importers.FluidImporters)
+	exporterWorker := worker.NewExporterWorker(*storageService, daos, storageAdapter, condService)
+	importerWorker := worker.NewImporterWorker(*storageService, daos, storageAdapter, condService)
+	fluidImporterWorker := worker.NewFluidImporterWorker(*storageService)
+	actuatorWorker := worker.NewActuatorWorker(modemManager, condService)
 
-	condService := cond.NewCondService(clientsManager)
 	processingCrafterWorker := worker.NewProcessingCrafterWorker(
 		daos,
 		storageService,
 		storageAdapter,
 		transferTransationManager,
 		condService,
+		actuatorWorker,
 	)
+	shapedCrafterWorker := worker.NewShapedCrafterWorker(daos, storageService, transferTransationManager, clientsManager)
+	machineGroupWorker := worker.NewMachineGroupWorker(processingCrafterWorker)
 	workerManager := worker.NewWorkerManager(configLoader,
 		daos,
 		exporterWorker,
 		importerWorker,
 		processingCrafterWorker,
 		fluidImporterWorker,
+		shapedCrafterWorker,
+		machineGroupWorker,
+		actuatorWorker,
+		workerFactory,
+		clientsManager.Events(),
 	)
 
 	clientsManager.SetClientListener(workerFactory)
+	condService.SetWorkerStateProvider(workerManager.GetWorkerState)
 
 	app := &app.App{
 		Daos:                       daos,
@@ -112,6 +133,7 @@ func (s *ServerCommand) Execute(args []string) error {
 		ClientsManager:             clientsManager,
 		WorkerFactory:              workerFactory,
 		WorkerManager:              workerManager,
+		CondService:                condService,
 		ModemManager:               modemManager,
 		StorageAdapter:             storageAdapter,
 		TransferTransactionManager: transferTransationManager,
@@ -119,6 +141,7 @@ func (s *ServerCommand) Execute(args []string) error {
 		ItemManager:                itemManager,
 		ScriptsManager:             scriptsmanager,
 		ClientsService:             clientsService,
+		Recorder:                   recorder,
 	}
 	mux, err := server.CreateMux(app, wsServer)
 	if err != nil {
@@ -139,3 +162,19 @@ func (s *ServerCommand) Execute(args []string) error {
 
 	return eg.Wait()
 }
+
+func createRecorder(cfg config.RecorderConfig, daos *dao.DaoProvider) (wsrpc.Recorder, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "sqlite":
+		return daos.RpcRecords, nil
+	case "file":
+		path := cfg.Path
+		if path == "" {
+			path = "rpc-records.jsonl"
+		}
+		return wsrpc.NewFileRecorder(path, int64(cfg.MaxSizeMB)*1024*1024, cfg.MaxFiles)
+	}
+	return nil, fmt.Errorf("unknown recorder type: %s", cfg.Type)
+}
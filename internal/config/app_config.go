@@ -1,10 +1,14 @@
 package config
 
+import "github.com/asek-ll/aecc-server/internal/dao"
+
 type AppConfig struct {
 	Storage   StorageConfig   `json:"storage"`
 	Crafters  CraftersConfig  `json:"crafters"`
 	Importers ImportersConfig `json:"importers"`
 
+	RecipeImport RecipeImportConfig `json:"recipeImport"`
+
 	WebServer    WebServerConfig    `json:"webServer"`
 	ClientServer ClientServerConfig `json:"clientServer"`
 }
@@ -37,6 +41,25 @@ type ProcessCrafterConfig struct {
 
 	ResultFluids []string `json:"resultFluids"`
 	ResultTank   string   `json:"resultTank"`
+
+	WakeOn []string `json:"wakeOn"`
+
+	// BatchSize is max repeats pushed in one transaction, SlotCapacity and
+	// TankCapacity limit amount of single ingredient in machine, MaxInFlight
+	// is number of crafts waited at once, waited craft is closed with
+	// received yield when no results arrive for WaitTimeout seconds
+	BatchSize    int `json:"batchSize"`
+	SlotCapacity int `json:"slotCapacity"`
+	TankCapacity int `json:"tankCapacity"`
+	MaxInFlight  int `json:"maxInFlight"`
+	WaitTimeout  int `json:"waitTimeout"`
+
+	PreCraft  []dao.ActuatorStep `json:"preCraft"`
+	PostCraft []dao.ActuatorStep `json:"postCraft"`
+
+	// MaxCrafts limits crafts submitted in single run, set by machine group
+	// to spread crafts between machines
+	MaxCrafts int `json:"-"`
 }
 
 type ImportersConfig struct {
@@ -44,6 +67,12 @@ type ImportersConfig struct {
 	FluidImporters   []FluidImporterConfig   `json:"fluid"`
 }
 
+// RecipeImportConfig maps datapack recipe types to recipe types, Types
+// extend and override default mapping of vanilla crafting and smelting
+type RecipeImportConfig struct {
+	Types map[string]string `json:"types"`
+}
+
 type StorageImporterConfig struct {
 	Storage string   `json:"storage"`
 	Items   []string `json:"items"`
@@ -72,11 +101,26 @@ type AuthConfig struct {
 	TokenSecret   string   `json:"tokenSecret"`
 	Admins        []string `json:"admins"`
 	AdminPassword string   `json:"adminPassword"`
+
+	RequireEnrollment bool `json:"requireEnrollment"`
 }
 
 type ClientServerConfig struct {
-	Url        string `json:"url"`
-	ListenAddr string `json:"addr"`
+	Url         string `json:"url"`
+	ListenAddr  string `json:"addr"`
+	MaxInFlight int    `json:"maxInFlight"`
+	MaxQueued   int    `json:"maxQueued"`
+	Compression bool   `json:"compression"`
+
+	Recorder RecorderConfig `json:"recorder"`
+}
+
+// RecorderConfig enables recording of rpc traffic, Type is "file" or "sqlite"
+type RecorderConfig struct {
+	Type      string `json:"type"`
+	Path      string `json:"path"`
+	MaxSizeMB int    `json:"maxSizeMB"`
+	MaxFiles  int    `json:"maxFiles"`
 }
 
 type WebServerConfig struct {
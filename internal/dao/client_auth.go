@@ -0,0 +1,393 @@
+package dao
+
+import (
+	"database/sql"
+	"time"
+)
+
+type ClientEnrollment struct {
+	Code      string
+	CreatedBy string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	ClientID  *string
+}
+
+type ClientAuditRecord struct {
+	ID        int
+	ClientID  string
+	Action    string
+	Actor     string
+	Details   string
+	CreatedAt time.Time
+}
+
+type ClientAuthDao struct {
+	db *sql.DB
+}
+
+func NewClientAuthDao(db *sql.DB) (*ClientAuthDao, error) {
+	sqlStmt := `
+	CREATE TABLE IF NOT EXISTS client_enrollments (
+		code string NOT NULL PRIMARY KEY,
+		created_by string NOT NULL,
+		created_at timestamp NOT NULL,
+		expires_at timestamp NOT NULL,
+		used_at timestamp,
+		client_id string
+	);
+
+	CREATE TABLE IF NOT EXISTS client_tokens (
+		client_id string NOT NULL PRIMARY KEY,
+		token_hash string NOT NULL,
+		issued_at timestamp NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS client_pending_tokens (
+		client_id string NOT NULL PRIMARY KEY,
+		token_hash string NOT NULL,
+		issued_at timestamp NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS client_first_login (
+		client_id string NOT NULL PRIMARY KEY,
+		logged_at timestamp NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS client_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id string NOT NULL,
+		action string NOT NULL,
+		actor string NOT NULL,
+		details string NOT NULL,
+		created_at timestamp NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS client_audit_client_idx ON client_audit(client_id);
+	`
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientAuthDao{db: db}, nil
+}
+
+func (d *ClientAuthDao) CreateEnrollment(enrollment *ClientEnrollment) error {
+	_, err := d.db.Exec(`
+		INSERT INTO client_enrollments (code, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, enrollment.Code, enrollment.CreatedBy, enrollment.CreatedAt, enrollment.ExpiresAt)
+	return err
+}
+
+func readEnrollments(rows *sql.Rows) ([]*ClientEnrollment, error) {
+	defer rows.Close()
+
+	var result []*ClientEnrollment
+	for rows.Next() {
+		var enrollment ClientEnrollment
+		err := rows.Scan(
+			&enrollment.Code,
+			&enrollment.CreatedBy,
+			&enrollment.CreatedAt,
+			&enrollment.ExpiresAt,
+			&enrollment.UsedAt,
+			&enrollment.ClientID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &enrollment)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (d *ClientAuthDao) GetActiveEnrollments(now time.Time) ([]*ClientEnrollment, error) {
+	rows, err := d.db.Query(`
+		SELECT code, created_by, created_at, expires_at, used_at, client_id
+		FROM client_enrollments
+		WHERE used_at IS NULL AND expires_at > ?
+		ORDER BY created_at DESC
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	return readEnrollments(rows)
+}
+
+// EnrollClient consumes enrollment code and creates authorized client, it
+// returns nil if code is unknown, expired or already used
+func (d *ClientAuthDao) EnrollClient(code string, client *Client, now time.Time) (*ClientEnrollment, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT code, created_by, created_at, expires_at, used_at, client_id
+		FROM client_enrollments
+		WHERE code = ? AND used_at IS NULL AND expires_at > ?
+	`, code, now)
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := readEnrollments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(enrollments) == 0 {
+		return nil, nil
+	}
+
+	enrollment := enrollments[0]
+	enrollment.UsedAt = &now
+	enrollment.ClientID = &client.ID
+
+	_, err = tx.Exec("UPDATE client_enrollments SET used_at = ?, client_id = ? WHERE code = ?", now, client.ID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Authorized = true
+	_, err = tx.Exec(`
+		INSERT INTO clients (id, label, role, online, last_login, wsclient_id, authorized)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, client.ID, client.Label, client.Role, client.Online, client.LastLogin, client.WSClientID, client.Authorized)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addAuditRecordInOuterTx(tx, &ClientAuditRecord{
+		ClientID:  client.ID,
+		Action:    "enroll",
+		Actor:     enrollment.CreatedBy,
+		Details:   "enrolled with code " + code,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollment, tx.Commit()
+}
+
+func (d *ClientAuthDao) DeleteEnrollment(code string) error {
+	_, err := d.db.Exec("DELETE FROM client_enrollments WHERE code = ?", code)
+	return err
+}
+
+func (d *ClientAuthDao) GetTokenHash(clientID string) (string, error) {
+	row := d.db.QueryRow("SELECT token_hash FROM client_tokens WHERE client_id = ?", clientID)
+	var hash string
+	err := row.Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// GetPendingTokenHash returns hash of issued token which client has not
+// confirmed yet
+func (d *ClientAuthDao) GetPendingTokenHash(clientID string) (string, error) {
+	row := d.db.QueryRow("SELECT token_hash FROM client_pending_tokens WHERE client_id = ?", clientID)
+	var hash string
+	err := row.Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+func (d *ClientAuthDao) SetPendingTokenHash(clientID string, hash string) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO client_pending_tokens (client_id, token_hash, issued_at)
+		VALUES (?, ?, ?)
+	`, clientID, hash, time.Now())
+	return err
+}
+
+// ConfirmPendingToken replaces token of client by pending one
+func (d *ClientAuthDao) ConfirmPendingToken(clientID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO client_tokens (client_id, token_hash, issued_at)
+		SELECT client_id, token_hash, issued_at FROM client_pending_tokens WHERE client_id = ?
+	`, clientID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM client_pending_tokens WHERE client_id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseFirstLogin records login of client without token, returns false when
+// such login was already used after client was authorized
+func (d *ClientAuthDao) UseFirstLogin(clientID string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.Exec("INSERT OR IGNORE INTO client_first_login (client_id, logged_at) VALUES (?, ?)", clientID, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	err = addAuditRecordInOuterTx(tx, &ClientAuditRecord{
+		ClientID:  clientID,
+		Action:    "first_login",
+		Actor:     "client",
+		Details:   "login without token",
+		CreatedAt: now,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (d *ClientAuthDao) AuthorizeClient(clientID string, actor string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE clients SET authorized = true WHERE id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	// authorized client gets token on next login
+	_, err = tx.Exec("DELETE FROM client_first_login WHERE client_id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	err = addAuditRecordInOuterTx(tx, &ClientAuditRecord{
+		ClientID:  clientID,
+		Action:    "authorize",
+		Actor:     actor,
+		Details:   "",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *ClientAuthDao) RevokeClient(clientID string, actor string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE clients SET authorized = false WHERE id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM client_tokens WHERE client_id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM client_pending_tokens WHERE client_id = ?", clientID)
+	if err != nil {
+		return err
+	}
+
+	err = addAuditRecordInOuterTx(tx, &ClientAuditRecord{
+		ClientID:  clientID,
+		Action:    "revoke",
+		Actor:     actor,
+		Details:   "",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func addAuditRecordInOuterTx(tx *sql.Tx, record *ClientAuditRecord) error {
+	_, err := tx.Exec(`
+		INSERT INTO client_audit (client_id, action, actor, details, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, record.ClientID, record.Action, record.Actor, record.Details, record.CreatedAt)
+	return err
+}
+
+func (d *ClientAuthDao) AddAuditRecord(record *ClientAuditRecord) error {
+	_, err := d.db.Exec(`
+		INSERT INTO client_audit (client_id, action, actor, details, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, record.ClientID, record.Action, record.Actor, record.Details, record.CreatedAt)
+	return err
+}
+
+func (d *ClientAuthDao) GetAuditRecords(clientID string) ([]ClientAuditRecord, error) {
+	rows, err := d.db.Query(`
+		SELECT id, client_id, action, actor, details, created_at
+		FROM client_audit
+		WHERE client_id = ?
+		ORDER BY id DESC
+	`, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ClientAuditRecord
+	for rows.Next() {
+		var record ClientAuditRecord
+		err := rows.Scan(&record.ID, &record.ClientID, &record.Action, &record.Actor, &record.Details, &record.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
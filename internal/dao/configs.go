@@ -20,6 +20,7 @@ func NewConfigsDao(db *sql.DB) (*ConfigsDao, error) {
 	);
 
 	INSERT OR IGNORE INTO configs(key, value) VALUES('do-cleanup-inventory', '');
+	INSERT OR IGNORE INTO configs(key, value) VALUES('cleanup-inventory-condition', '');
 	`
 	_, err := db.Exec(sqlStmt)
 	if err != nil {
@@ -0,0 +1,188 @@
+package dao
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// CraftYield is amount of single result received for processed craft,
+// unexpected yields are items which are not results of waited crafts
+type CraftYield struct {
+	ID         int       `json:"id"`
+	Time       time.Time `json:"time"`
+	WorkerKey  string    `json:"workerKey"`
+	CraftID    int       `json:"craftId"`
+	RecipeID   int       `json:"recipeId"`
+	Repeats    int       `json:"repeats"`
+	ItemUID    string    `json:"itemUid"`
+	Expected   float64   `json:"expected"`
+	Received   int       `json:"received"`
+	Unexpected bool      `json:"unexpected"`
+}
+
+// Deviation returns surplus for positive and shortfall for negative values
+func (y *CraftYield) Deviation() float64 {
+	return float64(y.Received) - y.Expected
+}
+
+type CraftYieldFilter struct {
+	WorkerKey      string
+	RecipeID       int
+	OnlyDeviations bool
+	Limit          int
+}
+
+// RecipeYieldStats is aggregated yield of recipe result
+type RecipeYieldStats struct {
+	RecipeID int     `json:"recipeId"`
+	ItemUID  string  `json:"itemUid"`
+	Crafts   int     `json:"crafts"`
+	Repeats  int     `json:"repeats"`
+	Expected float64 `json:"expected"`
+	Received int     `json:"received"`
+}
+
+// Ratio returns received to expected ratio
+func (s *RecipeYieldStats) Ratio() float64 {
+	if s.Expected == 0 {
+		return 0
+	}
+	return float64(s.Received) / s.Expected
+}
+
+type CraftYieldsDao struct {
+	db *sql.DB
+}
+
+func NewCraftYieldsDao(db *sql.DB) (*CraftYieldsDao, error) {
+	sqlStmt := `
+	CREATE TABLE IF NOT EXISTS craft_yield (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time timestamp NOT NULL,
+		worker_key string NOT NULL,
+		craft_id integer NOT NULL,
+		recipe_id integer NOT NULL,
+		repeats integer NOT NULL,
+		item_uid string NOT NULL,
+		expected real NOT NULL,
+		received integer NOT NULL,
+		unexpected boolean NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS craft_yield_recipe_idx ON craft_yield(recipe_id);
+	`
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CraftYieldsDao{db: db}, nil
+}
+
+func InsertCraftYieldsInOuterTx(tx *sql.Tx, yields []CraftYield) error {
+	for _, y := range yields {
+		if y.Time.IsZero() {
+			y.Time = time.Now()
+		}
+		_, err := tx.Exec(`
+		INSERT INTO craft_yield (time, worker_key, craft_id, recipe_id, repeats, item_uid, expected, received, unexpected)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, y.Time, y.WorkerKey, y.CraftID, y.RecipeID, y.Repeats, y.ItemUID, y.Expected, y.Received, y.Unexpected)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *CraftYieldsDao) InsertYields(yields []CraftYield) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = InsertCraftYieldsInOuterTx(tx, yields)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *CraftYieldsDao) Query(filter CraftYieldFilter) ([]CraftYield, error) {
+	var conditions []string
+	var args []any
+	if filter.WorkerKey != "" {
+		conditions = append(conditions, "worker_key = ?")
+		args = append(args, filter.WorkerKey)
+	}
+	if filter.RecipeID != 0 {
+		conditions = append(conditions, "recipe_id = ?")
+		args = append(args, filter.RecipeID)
+	}
+	if filter.OnlyDeviations {
+		conditions = append(conditions, "(unexpected OR received != expected)")
+	}
+
+	query := "SELECT id, time, worker_key, craft_id, recipe_id, repeats, item_uid, expected, received, unexpected FROM craft_yield"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []CraftYield
+	for rows.Next() {
+		var y CraftYield
+		err := rows.Scan(&y.ID, &y.Time, &y.WorkerKey, &y.CraftID, &y.RecipeID, &y.Repeats, &y.ItemUID, &y.Expected, &y.Received, &y.Unexpected)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, y)
+	}
+
+	return result, rows.Err()
+}
+
+// GetRecipeStats returns yields of expected results aggregated by recipe
+func (d *CraftYieldsDao) GetRecipeStats() ([]RecipeYieldStats, error) {
+	rows, err := d.db.Query(`
+	SELECT recipe_id, item_uid, COUNT(DISTINCT craft_id), SUM(repeats), SUM(expected), SUM(received)
+	FROM craft_yield
+	WHERE NOT unexpected
+	GROUP BY recipe_id, item_uid
+	ORDER BY recipe_id, item_uid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RecipeYieldStats
+	for rows.Next() {
+		var s RecipeYieldStats
+		err := rows.Scan(&s.RecipeID, &s.ItemUID, &s.Crafts, &s.Repeats, &s.Expected, &s.Received)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *CraftYieldsDao) Clear() error {
+	_, err := d.db.Exec("DELETE FROM craft_yield")
+	return err
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"github.com/asek-ll/aecc-server/internal/common"
 )
 
 type Craft struct {
@@ -204,6 +206,53 @@ func CommitCraftInOuterTx(tx *sql.Tx, craft *Craft, recipe *Recipe, repeats int)
 	return nil
 }
 
+// SplitCraftInOuterTx moves repeats of pending craft to new pending craft, so
+// parts of same craft can be processed in parallel
+func SplitCraftInOuterTx(tx *sql.Tx, craft *Craft, repeats int) (*Craft, error) {
+	if repeats <= 0 || repeats >= craft.Repeats {
+		return craft, nil
+	}
+
+	res, err := tx.Exec(`
+	UPDATE craft
+	SET repeats = repeats - ?
+	WHERE id = ? AND status = 'PENDING' AND repeats = ?`, repeats, craft.ID, craft.Repeats)
+	if err != nil {
+		return nil, err
+	}
+	afftected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if afftected != 1 {
+		return nil, errors.New("expected craft in PENDING state")
+	}
+
+	res, err = tx.Exec(`INSERT INTO
+	craft (plan_id, recipe_type, worker_id, status, created, recipe_id, repeats, commit_repeats)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		craft.PlanID, craft.RecipeType, craft.WorkerID, PENDING_CRAFT_STATUS, craft.Created, craft.RecipeID, repeats, 0)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	craft.Repeats -= repeats
+	return &Craft{
+		ID:         int(id),
+		PlanID:     craft.PlanID,
+		RecipeType: craft.RecipeType,
+		WorkerID:   craft.WorkerID,
+		Status:     PENDING_CRAFT_STATUS,
+		Created:    craft.Created,
+		RecipeID:   craft.RecipeID,
+		Repeats:    repeats,
+	}, nil
+}
+
 func (d *CraftsDao) CommitCraft(craft *Craft, recipe *Recipe, repeats int) error {
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -349,6 +398,26 @@ func (d *CraftsDao) FindById(craftId int) (*Craft, error) {
 	return crafts[0], nil
 }
 
+// FindByRecipes returns crafts of recipes which are not completed yet
+func (d *CraftsDao) FindByRecipes(recipeIds []int) ([]*Craft, error) {
+	if len(recipeIds) == 0 {
+		return nil, nil
+	}
+
+	rows, err := d.db.Query(fmt.Sprintf(`
+	SELECT `+craftsFieldList+`
+	FROM craft
+	WHERE recipe_id IN (?%s)
+	ORDER BY id`, strings.Repeat(",?", len(recipeIds)-1),
+	), common.ToArgs(recipeIds)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readCrafts(rows)
+}
+
 func (d *CraftsDao) FindNextByTypes(types []string, workerId string) ([]*Craft, error) {
 	if len(types) == 0 {
 		return nil, nil
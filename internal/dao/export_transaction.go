@@ -45,31 +45,60 @@ func NewStoredTXDao(db *sql.DB) (*StoredTXDao, error) {
 	return &StoredTXDao{db: db}, nil
 }
 
-func (d *StoredTXDao) InsertTransactionUncommitted(subjects []string, stx *StoredTX) (*sql.Tx, error) {
+// InsertTransaction records transaction intent and locks its subjects
+func (d *StoredTXDao) InsertTransaction(subjects []string, stx *StoredTX) error {
 	tx, err := d.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer tx.Rollback()
 
 	res, err := tx.Exec("INSERT INTO stored_tx (data) VALUES (?)", stx.Data)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	txid, err := res.LastInsertId()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	stx.ID = int(txid)
-
 	for _, subject := range subjects {
 		_, err := tx.Exec("INSERT INTO stored_tx_subjects (name, txid) VALUES (?, ?)", subject, txid)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	stx.ID = int(txid)
+
+	return nil
+}
+
+// Begin starts db transaction for changes of transfer done without stored
+// intent
+func (d *StoredTXDao) Begin() (*sql.Tx, error) {
+	return d.db.Begin()
+}
+
+// UpdateTransactionUncommitted updates transaction data in returned db
+// transaction, so caller can commit own changes atomically with it
+func (d *StoredTXDao) UpdateTransactionUncommitted(stx *StoredTX) (*sql.Tx, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE stored_tx SET data = ? WHERE id = ?", stx.Data, stx.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	return tx, nil
 }
 
@@ -112,6 +141,31 @@ func (d *StoredTXDao) FindLocks(subjects []string) ([]*StoredTX, error) {
 	return d.FindTransactionsByIds(ids)
 }
 
+// GetSubjects returns subjects locked by transaction
+func (d *StoredTXDao) GetSubjects(txid int) ([]string, error) {
+	rows, err := d.db.Query("SELECT name FROM stored_tx_subjects WHERE txid = ?", txid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []string
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, name)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return subjects, nil
+}
+
 func readStoredTransactions(rows *sql.Rows) ([]*StoredTX, error) {
 	var txs []*StoredTX
 
@@ -69,6 +69,42 @@ func (d *ImportedRecipesDao) InsertTag(name string, itemUID string) error {
 	return err
 }
 
+// InsertTagIfMissing adds item to tag, returns false if item is already
+// tagged
+func (d *ImportedRecipesDao) InsertTagIfMissing(name string, itemUID string) (bool, error) {
+	res, err := d.db.Exec(`
+	INSERT INTO item_tag(name, item_uid)
+	SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM item_tag WHERE name = ? AND item_uid = ?)`,
+		name, itemUID, name, itemUID)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+func (d *ImportedRecipesDao) FindItemsByTag(name string) ([]string, error) {
+	rows, err := d.db.Query("SELECT DISTINCT item_uid FROM item_tag WHERE name = ?", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var uid string
+		err := rows.Scan(&uid)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, uid)
+	}
+	return result, rows.Err()
+}
+
 func (d *ImportedRecipesDao) InsertRecipe(recipe ImportedRecipe) error {
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -97,6 +133,66 @@ func (d *ImportedRecipesDao) InsertRecipe(recipe ImportedRecipe) error {
 	return tx.Commit()
 }
 
+// Signature identifies imported recipe by result and ingredients, it is used
+// to skip already imported recipes
+func (r *ImportedRecipe) Signature() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s|%s|%s", r.ResultID, formatPtr(r.ResultCount), formatPtr(r.ResultNBT))
+	for _, ing := range r.Ingredients {
+		fmt.Fprintf(&sb, ";%d|%s|%s|%s|%s", ing.Slot, formatPtr(ing.Item), formatPtr(ing.ItemTag), formatPtr(ing.Count), formatPtr(ing.NBT))
+	}
+	return sb.String()
+}
+
+func formatPtr[T any](value *T) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(*value)
+}
+
+func (d *ImportedRecipesDao) GetRecipeSignatures() (map[string]struct{}, error) {
+	rows, err := d.db.Query(`
+	SELECT r.id, r.result_id, r.result_count, r.result_nbt, i.slot, i.item, i.item_tag, i.count, i.nbt
+	FROM imported_recipe r
+	LEFT JOIN imported_recipe_ingredient i ON i.recipe_id = r.id
+	ORDER BY r.id, i.rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipeById := make(map[int]*ImportedRecipe)
+	for rows.Next() {
+		var recipe ImportedRecipe
+		var slot *int
+		var ing ImportedIngredient
+		err := rows.Scan(&recipe.ID, &recipe.ResultID, &recipe.ResultCount, &recipe.ResultNBT, &slot, &ing.Item, &ing.ItemTag, &ing.Count, &ing.NBT)
+		if err != nil {
+			return nil, err
+		}
+		existing, e := recipeById[recipe.ID]
+		if !e {
+			existing = &recipe
+			recipeById[recipe.ID] = existing
+		}
+		if slot != nil {
+			ing.Slot = *slot
+			existing.Ingredients = append(existing.Ingredients, ing)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make(map[string]struct{})
+	for _, recipe := range recipeById {
+		signatures[recipe.Signature()] = struct{}{}
+	}
+	return signatures, nil
+}
+
 func (d *ImportedRecipesDao) FindRecipeByResult(uid string) ([]*Recipe, error) {
 	itemId, nbt := common.FromUid(uid)
 
@@ -181,6 +181,18 @@ func (d *ItemReserveDao) GetActualReserves() ([]ItemReserve, error) {
 	return result, nil
 }
 
+func (d *ItemReserveDao) GetReservedAmounts() (map[string]int, error) {
+	reserves, err := d.GetActualReserves()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(reserves))
+	for _, reserve := range reserves {
+		result[reserve.ItemUID] = reserve.Amount
+	}
+	return result, nil
+}
+
 func (d *ItemReserveDao) ClearActualReserves() error {
 	_, err := d.db.Exec(`
 	DELETE FROM item_reserve
@@ -104,6 +104,10 @@ func (d *ItemsDao) UpdateItem(uid string, item *Item) error {
 		if err != nil {
 			return err
 		}
+		_, err = tx.Exec(`UPDATE recipe_item_chance SET item_uid = ? WHERE item_uid = ?`, item.UID, uid)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = tx.Commit()
@@ -15,6 +15,13 @@ type PlanItemState struct {
 	RequiredAmount int
 }
 
+// PlanItemRequirement is item state of plan which still requires item
+type PlanItemRequirement struct {
+	PlanID     int
+	PlanStatus string
+	Item       PlanItemState
+}
+
 type PlanStepState struct {
 	RecipeID int
 	Repeats  int
@@ -52,6 +59,7 @@ func NewPlansDao(db *sql.DB) (*PlansDao, error) {
 		amount integer NOT NULL,
 		required_amount integer NOT NULL
 	);
+	CREATE INDEX IF NOT EXISTS plan_item_state_uid_idx ON plan_item_state(item_uid);
 
 	CREATE TABLE IF NOT EXISTS plan_step_state (
 		plan_id INTEGER NOT NULL,
@@ -170,6 +178,33 @@ func (d *PlansDao) GetPlanItemState(planId int) ([]PlanItemState, error) {
 	return readPlanItemState(rows)
 }
 
+func (d *PlansDao) FindPlansRequiringItem(uid string) ([]PlanItemRequirement, error) {
+	rows, err := d.db.Query(`
+	SELECT p.id, p.status, i.item_uid, i.amount, i.required_amount
+	FROM plan_item_state i JOIN plan_state p ON p.id = i.plan_id
+	WHERE i.item_uid = ? AND i.required_amount > i.amount
+	ORDER BY p.id`, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []PlanItemRequirement
+	for rows.Next() {
+		var r PlanItemRequirement
+		err := rows.Scan(&r.PlanID, &r.PlanStatus, &r.Item.ItemUID, &r.Item.Amount, &r.Item.RequiredAmount)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func readPlanItemState(rows *sql.Rows) ([]PlanItemState, error) {
 	var planItemState []PlanItemState
 	for rows.Next() {
@@ -21,6 +21,9 @@ type DaoProvider struct {
 	StoredTX         *StoredTXDao
 	WorkerState      *WorkerStateDao
 	ClientsScripts   *ClientsScriptsDao
+	ClientAuth       *ClientAuthDao
+	RpcRecords       *RpcRecordsDao
+	CraftYields      *CraftYieldsDao
 }
 
 func NewDaoProvider(databaseFile string) (*DaoProvider, error) {
@@ -99,6 +102,21 @@ func NewDaoProvider(databaseFile string) (*DaoProvider, error) {
 		return nil, err
 	}
 
+	clientAuthDao, err := NewClientAuthDao(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcRecordsDao, err := NewRpcRecordsDao(db)
+	if err != nil {
+		return nil, err
+	}
+
+	craftYieldsDao, err := NewCraftYieldsDao(db)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DaoProvider{
 		Clients:          clientsDao,
 		Seqs:             seqsDao,
@@ -114,5 +132,8 @@ func NewDaoProvider(databaseFile string) (*DaoProvider, error) {
 		StoredTX:         storedTXDao,
 		WorkerState:      workerStateDao,
 		ClientsScripts:   clientsScriptsDao,
+		ClientAuth:       clientAuthDao,
+		RpcRecords:       rpcRecordsDao,
+		CraftYields:      craftYieldsDao,
 	}, nil
 }
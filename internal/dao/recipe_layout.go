@@ -0,0 +1,191 @@
+package dao
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+)
+
+type RecipeLayoutSlot struct {
+	Slot  int    `json:"slot"`
+	Name  string `json:"name,omitempty"`
+	Fluid bool   `json:"fluid,omitempty"`
+}
+
+// RecipeLayout describes inputs of recipe type. Grid slots are numbered by
+// rows from 1, named slots annotate grid slots or, without grid, are the only
+// inputs of machine
+type RecipeLayout struct {
+	Columns int                `json:"columns,omitempty"`
+	Rows    int                `json:"rows,omitempty"`
+	Slots   []RecipeLayoutSlot `json:"slots,omitempty"`
+}
+
+var SHAPED_CRAFT_LAYOUT = RecipeLayout{Columns: 3, Rows: 3}
+
+func (l *RecipeLayout) HasGrid() bool {
+	return l.Columns > 0 && l.Rows > 0
+}
+
+func (l *RecipeLayout) GridSlot(row int, column int) int {
+	return row*l.Columns + column + 1
+}
+
+func (l *RecipeLayout) Validate() error {
+	if l.Columns < 0 || l.Rows < 0 || (l.Columns == 0) != (l.Rows == 0) {
+		return fmt.Errorf("invalid grid %dx%d", l.Columns, l.Rows)
+	}
+	if !l.HasGrid() && len(l.Slots) == 0 {
+		return fmt.Errorf("layout should have grid or slots")
+	}
+	seen := make(map[int]struct{})
+	for _, slot := range l.Slots {
+		if slot.Slot <= 0 {
+			return fmt.Errorf("invalid slot %d", slot.Slot)
+		}
+		if l.HasGrid() && slot.Slot > l.Columns*l.Rows {
+			return fmt.Errorf("slot %d is outside of %dx%d grid", slot.Slot, l.Columns, l.Rows)
+		}
+		if _, e := seen[slot.Slot]; e {
+			return fmt.Errorf("slot %d is defined twice", slot.Slot)
+		}
+		seen[slot.Slot] = struct{}{}
+	}
+	return nil
+}
+
+// InputSlots returns ordered slots of layout
+func (l *RecipeLayout) InputSlots() []int {
+	var slots []int
+	if l.HasGrid() {
+		for i := 1; i <= l.Columns*l.Rows; i++ {
+			slots = append(slots, i)
+		}
+		return slots
+	}
+	for _, slot := range l.Slots {
+		slots = append(slots, slot.Slot)
+	}
+	sort.Ints(slots)
+	return slots
+}
+
+func (l *RecipeLayout) HasSlot(slot int) bool {
+	if l.HasGrid() {
+		return slot >= 1 && slot <= l.Columns*l.Rows
+	}
+	return l.slot(slot) != nil
+}
+
+func (l *RecipeLayout) IsFluidSlot(slot int) bool {
+	s := l.slot(slot)
+	return s != nil && s.Fluid
+}
+
+func (l *RecipeLayout) SlotName(slot int) string {
+	s := l.slot(slot)
+	if s == nil {
+		return ""
+	}
+	return s.Name
+}
+
+func (l *RecipeLayout) slot(slot int) *RecipeLayoutSlot {
+	for i := range l.Slots {
+		if l.Slots[i].Slot == slot {
+			return &l.Slots[i]
+		}
+	}
+	return nil
+}
+
+// AssignSlots returns input slot for each ingredient and catalyst of recipe.
+// Ingredients without slot take free slots of layout in order, fluids without
+// slot get 0 when layout has no free fluid slots, they are pushed to tank.
+// Layout can be nil, then any slot is accepted
+func (l *RecipeLayout) AssignSlots(recipe *Recipe) ([]int, []int, error) {
+	used := make(map[int]struct{})
+	for _, items := range [][]RecipeItem{recipe.Ingredients, recipe.Catalysts} {
+		for _, item := range items {
+			if item.Slot == nil {
+				continue
+			}
+			slot := *item.Slot
+			// catalysts can be placed after grid
+			afterGrid := item.Role == CATALYST_ROLE && l != nil && l.HasGrid() && slot > l.Columns*l.Rows
+			if l != nil && !afterGrid {
+				if !l.HasSlot(slot) {
+					return nil, nil, fmt.Errorf("slot %d of '%s' is not in layout", slot, item.ItemUID)
+				}
+				if l.IsFluidSlot(slot) != common.IsFluid(item.ItemUID) {
+					return nil, nil, fmt.Errorf("slot %d does not accept '%s'", slot, item.ItemUID)
+				}
+			} else if l == nil && slot <= 0 {
+				return nil, nil, fmt.Errorf("invalid slot %d of '%s'", slot, item.ItemUID)
+			}
+			if _, e := used[slot]; e {
+				return nil, nil, fmt.Errorf("slot %d is used twice", slot)
+			}
+			used[slot] = struct{}{}
+		}
+	}
+
+	var free []int
+	if l != nil {
+		free = l.InputSlots()
+	}
+	next := func(item *RecipeItem) (int, error) {
+		fluid := common.IsFluid(item.ItemUID)
+		for _, slot := range free {
+			if _, e := used[slot]; !e && l.IsFluidSlot(slot) == fluid {
+				used[slot] = struct{}{}
+				return slot, nil
+			}
+		}
+		if fluid {
+			return 0, nil
+		}
+		slot := 1
+		if l != nil {
+			// only catalysts can be placed after grid
+			if !l.HasGrid() || item.Role != CATALYST_ROLE {
+				return 0, fmt.Errorf("no free slot for '%s'", item.ItemUID)
+			}
+			slot = l.Columns*l.Rows + 1
+		}
+		for {
+			if _, e := used[slot]; !e {
+				used[slot] = struct{}{}
+				return slot, nil
+			}
+			slot += 1
+		}
+	}
+
+	assign := func(items []RecipeItem) ([]int, error) {
+		slots := make([]int, len(items))
+		for i := range items {
+			if items[i].Slot != nil {
+				slots[i] = *items[i].Slot
+				continue
+			}
+			slot, err := next(&items[i])
+			if err != nil {
+				return nil, err
+			}
+			slots[i] = slot
+		}
+		return slots, nil
+	}
+
+	ingredients, err := assign(recipe.Ingredients)
+	if err != nil {
+		return nil, nil, err
+	}
+	catalysts, err := assign(recipe.Catalysts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ingredients, catalysts, nil
+}
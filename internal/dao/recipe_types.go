@@ -2,6 +2,7 @@ package dao
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 )
 
@@ -12,6 +13,8 @@ type RecipeTypesDao struct {
 type RecipeType struct {
 	Name     string
 	WorkerID string
+	// Layout of inputs, nil when type has no layout
+	Layout *RecipeLayout
 }
 
 func NewRecipeTypesDao(db *sql.DB) (*RecipeTypesDao, error) {
@@ -21,6 +24,11 @@ func NewRecipeTypesDao(db *sql.DB) (*RecipeTypesDao, error) {
 		name string NOT NULL,
 		worker_id string NOT NULL
 	);
+
+	CREATE TABLE IF NOT EXISTS recipe_type_layout (
+		name string PRIMARY KEY,
+		layout string NOT NULL
+	);
 	`
 	_, err := db.Exec(sqlStmt)
 	if err != nil {
@@ -34,21 +42,33 @@ func (d *RecipeTypesDao) InsertRecipeType(rt RecipeType) error {
 	return err
 }
 
+const recipeTypesQuery = `
+	SELECT t.name, t.worker_id, l.layout
+	FROM recipe_types t LEFT JOIN recipe_type_layout l ON l.name = t.name`
+
 func readRecipeTypes(rows *sql.Rows) ([]RecipeType, error) {
 	var recipeTypes []RecipeType
 	for rows.Next() {
 		var rt RecipeType
-		err := rows.Scan(&rt.Name, &rt.WorkerID)
+		var layout *string
+		err := rows.Scan(&rt.Name, &rt.WorkerID, &layout)
 		if err != nil {
 			return nil, err
 		}
+		if layout != nil {
+			rt.Layout = &RecipeLayout{}
+			err = json.Unmarshal([]byte(*layout), rt.Layout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid layout of recipe type '%s': %w", rt.Name, err)
+			}
+		}
 		recipeTypes = append(recipeTypes, rt)
 	}
-	return recipeTypes, nil
+	return recipeTypes, rows.Err()
 }
 
 func (d *RecipeTypesDao) GetRecipeTypes() ([]RecipeType, error) {
-	rows, err := d.db.Query("SELECT name, worker_id FROM recipe_types")
+	rows, err := d.db.Query(recipeTypesQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -58,22 +78,75 @@ func (d *RecipeTypesDao) GetRecipeTypes() ([]RecipeType, error) {
 }
 
 func (d *RecipeTypesDao) GetRecipeType(typeName string) (*RecipeType, error) {
-	rows, err := d.db.Query("SELECT name, worker_id FROM recipe_types WHERE name = ? LIMIT 1", typeName)
+	rows, err := d.db.Query(recipeTypesQuery+" WHERE t.name = ? LIMIT 1", typeName)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	if rows.Next() {
-		var rt RecipeType
-		err := rows.Scan(&rt.Name, &rt.WorkerID)
-		if err != nil {
-			return nil, err
+	recipeTypes, err := readRecipeTypes(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipeTypes) == 0 {
+		return nil, nil
+	}
+
+	return &recipeTypes[0], nil
+}
+
+// GetRecipeLayout returns layout of recipe inputs, shaped craft uses crafting
+// table grid, nil is returned for types without layout
+func (d *RecipeTypesDao) GetRecipeLayout(typeName string) (*RecipeLayout, error) {
+	if typeName == "" {
+		return &SHAPED_CRAFT_LAYOUT, nil
+	}
+	rt, err := d.GetRecipeType(typeName)
+	if err != nil || rt == nil {
+		return nil, err
+	}
+	return rt.Layout, nil
+}
+
+// GetRecipeLayouts returns layouts of all types which have it
+func (d *RecipeTypesDao) GetRecipeLayouts() (map[string]*RecipeLayout, error) {
+	recipeTypes, err := d.GetRecipeTypes()
+	if err != nil {
+		return nil, err
+	}
+	layouts := map[string]*RecipeLayout{"": &SHAPED_CRAFT_LAYOUT}
+	for _, rt := range recipeTypes {
+		if rt.Layout != nil {
+			layouts[rt.Name] = rt.Layout
 		}
-		return &rt, nil
 	}
+	return layouts, nil
+}
 
-	return nil, nil
+// SetRecipeLayout replaces layout of recipe type, nil layout removes it
+func (d *RecipeTypesDao) SetRecipeLayout(typeName string, layout *RecipeLayout) error {
+	if layout == nil {
+		_, err := d.db.Exec("DELETE FROM recipe_type_layout WHERE name = ?", typeName)
+		return err
+	}
+	err := layout.Validate()
+	if err != nil {
+		return err
+	}
+	rt, err := d.GetRecipeType(typeName)
+	if err != nil {
+		return err
+	}
+	if rt == nil {
+		return fmt.Errorf("recipe type '%s' not found", typeName)
+	}
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO recipe_type_layout(name, layout) VALUES(?, ?)
+	ON CONFLICT(name) DO UPDATE SET layout = excluded.layout`, typeName, string(data))
+	return err
 }
 
 func (d *RecipeTypesDao) DeleteRecipeType(typeName string) error {
@@ -86,9 +159,30 @@ func (d *RecipeTypesDao) DeleteRecipeType(typeName string) error {
 		return err
 	}
 	_, err = d.db.Exec("DELETE FROM recipe_types WHERE name = ?", typeName)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec("DELETE FROM recipe_type_layout WHERE name = ?", typeName)
 	return err
 }
 
+// EnsureRecipeTypes creates missing recipe types without worker
+func (d *RecipeTypesDao) EnsureRecipeTypes(names []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range names {
+		err = ensureRecipeType(tx, name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func SetWorkerForRecipeType(tx *sql.Tx, craftType string, workerKey string) (bool, error) {
 	if craftType == "" {
 		return false, fmt.Errorf("Invalid craft type: %s", craftType)
@@ -120,3 +214,28 @@ func UnSetWorkerForRecipeType(tx *sql.Tx, craftType string, workerKey string) (b
 	}
 	return rows == 1, nil
 }
+
+// ensureRecipeType creates recipe type without worker if it does not exist
+func ensureRecipeType(tx *sql.Tx, craftType string) error {
+	var exists int
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM recipe_types WHERE name = ?)", craftType).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 1 {
+		return nil
+	}
+	_, err = tx.Exec("INSERT INTO recipe_types(name, worker_id) VALUES(?, '')", craftType)
+	return err
+}
+
+// recipeTypeWorker returns key of worker assigned to recipe type, empty if
+// type has no worker
+func recipeTypeWorker(tx *sql.Tx, craftType string) (string, error) {
+	var workerKey string
+	err := tx.QueryRow("SELECT worker_id FROM recipe_types WHERE name = ?", craftType).Scan(&workerKey)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return workerKey, err
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"github.com/asek-ll/aecc-server/internal/common"
 )
@@ -17,6 +18,21 @@ type RecipeItem struct {
 	Amount  int
 	Role    string
 	Slot    *int
+	// Chance of result output per repeat, nil for guaranteed output
+	Chance *float64
+}
+
+// IsGuaranteed reports whether item is produced on every repeat
+func (i *RecipeItem) IsGuaranteed() bool {
+	return i.Chance == nil || *i.Chance >= 1
+}
+
+// ExpectedPerRepeat returns expected value of item amount per repeat
+func (i *RecipeItem) ExpectedPerRepeat() float64 {
+	if i.Chance == nil {
+		return float64(i.Amount)
+	}
+	return float64(i.Amount) * *i.Chance
 }
 
 type Recipe struct {
@@ -31,6 +47,8 @@ type Recipe struct {
 
 type RecipesDao struct {
 	db *sql.DB
+	// revision is incremented on every recipe change
+	revision atomic.Int64
 }
 
 func NewRecipesDao(db *sql.DB) (*RecipesDao, error) {
@@ -51,6 +69,14 @@ func NewRecipesDao(db *sql.DB) (*RecipesDao, error) {
 		slot integer
 	);
 	CREATE INDEX IF NOT EXISTS recipe_items_idx ON recipe_items(recipe_id);
+	CREATE INDEX IF NOT EXISTS recipe_items_uid_idx ON recipe_items(item_uid, role);
+
+	CREATE TABLE IF NOT EXISTS recipe_item_chance (
+		recipe_id INTEGER NOT NULL,
+		item_uid string NOT NULL,
+		chance real NOT NULL,
+		PRIMARY KEY (recipe_id, item_uid)
+	);
 	`
 
 	_, err := db.Exec(sqlStmt)
@@ -74,7 +100,8 @@ func readRecipes(rows *sql.Rows) ([]*Recipe, error) {
 		var role *string
 		var slot *int
 		var maxRepeats *int
-		err := rows.Scan(&id, &name, &typ, &maxRepeats, &item_uid, &amount, &role, &slot)
+		var chance *float64
+		err := rows.Scan(&id, &name, &typ, &maxRepeats, &item_uid, &amount, &role, &slot, &chance)
 		if err != nil {
 			return nil, err
 		}
@@ -98,6 +125,7 @@ func readRecipes(rows *sql.Rows) ([]*Recipe, error) {
 					Amount:  *amount,
 					Role:    *role,
 					Slot:    slot,
+					Chance:  chance,
 				})
 			} else if *role == INGREDIENT_ROLE {
 				recipe.Ingredients = append(recipe.Ingredients, RecipeItem{
@@ -161,6 +189,37 @@ func (r *RecipesDao) GetRecipesPage(filter string, fromId int) ([]*Recipe, error
 	return r.GetRecipesById(ids)
 }
 
+func (r *RecipesDao) GetRecipeNames() ([]string, error) {
+	rows, err := r.db.Query("SELECT DISTINCT name FROM recipes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Revision returns number of recipe changes since start, it can be used to
+// invalidate data computed from recipes
+func (r *RecipesDao) Revision() int64 {
+	return r.revision.Load()
+}
+
+// Touch marks recipes as changed, for changes of recipe items made outside of
+// this dao
+func (r *RecipesDao) Touch() {
+	r.revision.Add(1)
+}
+
 func (r *RecipesDao) InsertRecipe(recipe *Recipe) error {
 
 	tx, err := r.db.Begin()
@@ -169,55 +228,89 @@ func (r *RecipesDao) InsertRecipe(recipe *Recipe) error {
 	}
 	defer tx.Rollback()
 
-	res, err := tx.Exec("INSERT INTO recipes (name, type, max_repeats) VALUES (?, ?, ?)",
-		recipe.Name, recipe.Type, recipe.MaxRepeats)
+	err = insertRecipe(tx, recipe)
 	if err != nil {
 		return err
 	}
 
-	recipeId, err := res.LastInsertId()
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
-	recipe.ID = int(recipeId)
 
-	for _, item := range recipe.Results {
-		_, err := tx.Exec("INSERT INTO recipe_items (recipe_id, item_uid, amount, role, slot) VALUES (?, ?, ?, ?, ?)",
-			recipe.ID, item.ItemUID, item.Amount, RESULT_ROLE, item.Slot)
-		if err != nil {
-			return err
-		}
+	r.Touch()
+	return nil
+}
+
+func (r *RecipesDao) UpdateRecipe(recipe *Recipe) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	for _, item := range recipe.Ingredients {
-		_, err := tx.Exec("INSERT INTO recipe_items (recipe_id, item_uid, amount, role, slot) VALUES (?, ?, ?, ?, ?)",
-			recipe.ID, item.ItemUID, item.Amount, INGREDIENT_ROLE, item.Slot)
+	err = updateRecipe(tx, recipe)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	r.Touch()
+	return nil
+}
+
+// ApplyRecipes inserts and updates recipes in single transaction
+func (r *RecipesDao) ApplyRecipes(inserts []*Recipe, updates []*Recipe) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, recipe := range inserts {
+		err = insertRecipe(tx, recipe)
 		if err != nil {
 			return err
 		}
 	}
-
-	for _, item := range recipe.Catalysts {
-		_, err := tx.Exec("INSERT INTO recipe_items (recipe_id, item_uid, amount, role, slot) VALUES (?, ?, ?, ?, ?)",
-			recipe.ID, item.ItemUID, item.Amount, CATALYST_ROLE, item.Slot)
+	for _, recipe := range updates {
+		err = updateRecipe(tx, recipe)
 		if err != nil {
 			return err
 		}
 	}
 
 	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-	return err
+	r.Touch()
+	return nil
 }
 
-func (r *RecipesDao) UpdateRecipe(recipe *Recipe) error {
-	tx, err := r.db.Begin()
+func insertRecipe(tx *sql.Tx, recipe *Recipe) error {
+	res, err := tx.Exec("INSERT INTO recipes (name, type, max_repeats) VALUES (?, ?, ?)",
+		recipe.Name, recipe.Type, recipe.MaxRepeats)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	_, err = tx.Exec(`
+	recipeId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	recipe.ID = int(recipeId)
+
+	return insertRecipeItems(tx, recipe)
+}
+
+func updateRecipe(tx *sql.Tx, recipe *Recipe) error {
+	_, err := tx.Exec(`
 	UPDATE recipes
 	SET
 		name = ?,
@@ -234,12 +327,28 @@ func (r *RecipesDao) UpdateRecipe(recipe *Recipe) error {
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM recipe_item_chance WHERE recipe_id = ?", recipe.ID)
+	if err != nil {
+		return err
+	}
+
+	return insertRecipeItems(tx, recipe)
+}
+
+func insertRecipeItems(tx *sql.Tx, recipe *Recipe) error {
 	for _, item := range recipe.Results {
 		_, err := tx.Exec("INSERT INTO recipe_items (recipe_id, item_uid, amount, role, slot) VALUES (?, ?, ?, ?, ?)",
 			recipe.ID, item.ItemUID, item.Amount, RESULT_ROLE, item.Slot)
 		if err != nil {
 			return err
 		}
+		if item.Chance != nil {
+			_, err := tx.Exec("INSERT OR REPLACE INTO recipe_item_chance (recipe_id, item_uid, chance) VALUES (?, ?, ?)",
+				recipe.ID, item.ItemUID, *item.Chance)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, item := range recipe.Ingredients {
@@ -257,10 +366,7 @@ func (r *RecipesDao) UpdateRecipe(recipe *Recipe) error {
 			return err
 		}
 	}
-
-	err = tx.Commit()
-
-	return err
+	return nil
 }
 
 func (r *RecipesDao) GetRecipesByResults(itemUIDs []string) ([]*Recipe, error) {
@@ -297,14 +403,118 @@ func (r *RecipesDao) GetRecipesByResults(itemUIDs []string) ([]*Recipe, error) {
 	return r.GetRecipesById(ids)
 }
 
+// GetRecipesByUsages returns recipes which use items as ingredient or catalyst
+func (r *RecipesDao) GetRecipesByUsages(itemUIDs []string) ([]*Recipe, error) {
+	if len(itemUIDs) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+	SELECT DISTINCT ri.recipe_id FROM recipe_items ri
+	WHERE ri.item_uid IN (?%s) AND ri.role IN ('ingredient', 'catalyst')
+	`, strings.Repeat(", ?", len(itemUIDs)-1))
+
+	rows, err := r.db.Query(query, common.ToArgs(itemUIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetRecipesById(ids)
+}
+
+// FindRecipes returns recipes with name containing filter, recipeType nil
+// matches any type
+func (r *RecipesDao) FindRecipes(recipeType *string, filter string) ([]*Recipe, error) {
+	query := "SELECT id FROM recipes WHERE name LIKE ?"
+	args := []any{"%" + filter + "%"}
+	if recipeType != nil {
+		query += " AND type = ?"
+		args = append(args, *recipeType)
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetRecipesById(ids)
+}
+
+func (r *RecipesDao) GetRecipesByNames(names []string) ([]*Recipe, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id FROM recipes WHERE name IN (?%s) ORDER BY id
+	`, strings.Repeat(", ?", len(names)-1))
+
+	rows, err := r.db.Query(query, common.ToArgs(names)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetRecipesById(ids)
+}
+
 func (r *RecipesDao) GetRecipesById(ids []int) ([]*Recipe, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
 
 	query := fmt.Sprintf(`
-	SELECT r.id, r.name, r.type, r.max_repeats, ri.item_uid, ri.amount, ri.role, ri.slot FROM recipes r
+	SELECT r.id, r.name, r.type, r.max_repeats, ri.item_uid, ri.amount, ri.role, ri.slot, ric.chance FROM recipes r
 	LEFT JOIN recipe_items ri ON r.id = ri.recipe_id
+	LEFT JOIN recipe_item_chance ric ON ri.recipe_id = ric.recipe_id AND ri.item_uid = ric.item_uid AND ri.role = 'result'
 	WHERE r.id IN (?%s)
 	`, strings.Repeat(", ?", len(ids)-1))
 
@@ -347,7 +557,16 @@ func (r *RecipesDao) DeleteRecipe(id int) error {
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM recipe_item_chance WHERE recipe_id = ?", id)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-	return err
+	r.Touch()
+	return nil
 }
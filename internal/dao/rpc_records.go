@@ -0,0 +1,127 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+type RpcRecord struct {
+	Time       time.Time       `json:"time"`
+	Direction  string          `json:"direction"`
+	WsClientID uint            `json:"wsClientId"`
+	ClientID   string          `json:"clientId"`
+	Role       string          `json:"role"`
+	Method     string          `json:"method"`
+	MessageID  uint            `json:"messageId"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type RpcRecordFilter struct {
+	ClientID string
+	Method   string
+	Limit    int
+}
+
+func (f RpcRecordFilter) Match(record *RpcRecord) bool {
+	if f.ClientID != "" && record.ClientID != f.ClientID {
+		return false
+	}
+	if f.Method != "" && record.Method != f.Method {
+		return false
+	}
+	return true
+}
+
+type RpcRecordsDao struct {
+	db *sql.DB
+}
+
+func NewRpcRecordsDao(db *sql.DB) (*RpcRecordsDao, error) {
+	sqlStmt := `
+	CREATE TABLE IF NOT EXISTS rpc_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time timestamp NOT NULL,
+		direction string NOT NULL,
+		ws_client_id integer NOT NULL,
+		client_id string NOT NULL,
+		role string NOT NULL,
+		method string NOT NULL,
+		message_id integer NOT NULL,
+		payload string NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS rpc_records_client_idx ON rpc_records(client_id, method);
+	`
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RpcRecordsDao{db: db}, nil
+}
+
+func (d *RpcRecordsDao) Record(record *RpcRecord) error {
+	_, err := d.db.Exec(`
+		INSERT INTO rpc_records (time, direction, ws_client_id, client_id, role, method, message_id, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.Time, record.Direction, record.WsClientID, record.ClientID, record.Role, record.Method, record.MessageID, string(record.Payload))
+	return err
+}
+
+func (d *RpcRecordsDao) Query(filter RpcRecordFilter) ([]RpcRecord, error) {
+	var conditions []string
+	var args []any
+	if filter.ClientID != "" {
+		conditions = append(conditions, "client_id = ?")
+		args = append(args, filter.ClientID)
+	}
+	if filter.Method != "" {
+		conditions = append(conditions, "method = ?")
+		args = append(args, filter.Method)
+	}
+
+	query := "SELECT time, direction, ws_client_id, client_id, role, method, message_id, payload FROM rpc_records"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RpcRecord
+	for rows.Next() {
+		var record RpcRecord
+		var payload string
+		err := rows.Scan(&record.Time, &record.Direction, &record.WsClientID, &record.ClientID, &record.Role, &record.Method, &record.MessageID, &payload)
+		if err != nil {
+			return nil, err
+		}
+		record.Payload = json.RawMessage(payload)
+		result = append(result, record)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// records are returned in chronological order
+	slices.Reverse(result)
+
+	return result, nil
+}
+
+func (d *RpcRecordsDao) Clear() error {
+	_, err := d.db.Exec("DELETE FROM rpc_records")
+	return err
+}
@@ -3,6 +3,7 @@ package dao
 import (
 	"database/sql"
 	"errors"
+	"time"
 )
 
 type WorkerState struct {
@@ -20,6 +21,16 @@ func NewWorkerStateDao(db *sql.DB) (*WorkerStateDao, error) {
 		worker_key string NOT NULL,
 		wait_craft_id int NOT NULL
 	);
+	CREATE TABLE IF NOT EXISTS worker_wait_result (
+		craft_id int NOT NULL,
+		item_uid string NOT NULL,
+		received int NOT NULL,
+		PRIMARY KEY (craft_id, item_uid)
+	);
+	CREATE TABLE IF NOT EXISTS worker_wait_progress (
+		craft_id int NOT NULL PRIMARY KEY,
+		updated_at timestamp NOT NULL
+	);
 	`
 	_, err := db.Exec(sqlStmt)
 	if err != nil {
@@ -31,10 +42,29 @@ func NewWorkerStateDao(db *sql.DB) (*WorkerStateDao, error) {
 
 func SetWorkerWaitCraftInOuterTx(tx *sql.Tx, workerKey string, craft *Craft) error {
 	_, err := tx.Exec("INSERT INTO worker_state (worker_key, wait_craft_id) VALUES (?, ?)", workerKey, craft.ID)
+	if err != nil {
+		return err
+	}
+	return setWaitProgressInOuterTx(tx, craft.ID)
+}
+
+func setWaitProgressInOuterTx(tx *sql.Tx, craftID int) error {
+	_, err := tx.Exec(`INSERT INTO worker_wait_progress (craft_id, updated_at) VALUES (?, ?)
+	ON CONFLICT (craft_id) DO UPDATE SET updated_at = excluded.updated_at`, craftID, time.Now())
+	return err
+}
+
+func deleteWaitResultsInOuterTx(tx *sql.Tx, craftID int) error {
+	_, err := tx.Exec("DELETE FROM worker_wait_result WHERE craft_id = ?", craftID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM worker_wait_progress WHERE craft_id = ?", craftID)
 	return err
 }
 
-func (w *WorkerStateDao) CompleteWorkerWait(workerKey string, craft *Craft) error {
+// CompleteWorkerWait completes waited craft and records its yields
+func (w *WorkerStateDao) CompleteWorkerWait(workerKey string, craft *Craft, yields []CraftYield) error {
 	tx, err := w.db.Begin()
 	if err != nil {
 		return err
@@ -55,6 +85,16 @@ func (w *WorkerStateDao) CompleteWorkerWait(workerKey string, craft *Craft) erro
 		return errors.New("no worker wait to complete")
 	}
 
+	err = deleteWaitResultsInOuterTx(tx, craft.ID)
+	if err != nil {
+		return err
+	}
+
+	err = InsertCraftYieldsInOuterTx(tx, yields)
+	if err != nil {
+		return err
+	}
+
 	err = CompleteCraftInOuterTx(tx, craft)
 	if err != nil {
 		return err
@@ -63,27 +103,88 @@ func (w *WorkerStateDao) CompleteWorkerWait(workerKey string, craft *Craft) erro
 	return tx.Commit()
 }
 
-func (w *WorkerStateDao) GetWaitCraftID(workerKey string) (*int, error) {
-	rows, err := w.db.Query("SELECT wait_craft_id FROM worker_state WHERE worker_key = ?", workerKey)
+// GetWaitCraftIDs returns crafts waited by worker in order of submission
+func (w *WorkerStateDao) GetWaitCraftIDs(workerKey string) ([]int, error) {
+	rows, err := w.db.Query("SELECT wait_craft_id FROM worker_state WHERE worker_key = ? ORDER BY rowid", workerKey)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	if !rows.Next() {
-		return nil, nil
+	var result []int
+	for rows.Next() {
+		var waitCraftID int
+		err = rows.Scan(&waitCraftID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, waitCraftID)
 	}
 
-	var waitCraftID int
-	err = rows.Scan(&waitCraftID)
+	return result, rows.Err()
+}
+
+// GetWaitReceived returns amounts of results received for waited craft
+func (w *WorkerStateDao) GetWaitReceived(craftID int) (map[string]int, error) {
+	rows, err := w.db.Query("SELECT item_uid, received FROM worker_wait_result WHERE craft_id = ?", craftID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &waitCraftID, nil
+	result := make(map[string]int)
+	for rows.Next() {
+		var uid string
+		var received int
+		err = rows.Scan(&uid, &received)
+		if err != nil {
+			return nil, err
+		}
+		result[uid] = received
+	}
+
+	return result, rows.Err()
+}
+
+// GetWaitUpdatedAt returns time of submission or last received result of
+// waited craft, waits submitted by older versions start from now
+func (w *WorkerStateDao) GetWaitUpdatedAt(craftID int) (time.Time, error) {
+	_, err := w.db.Exec("INSERT OR IGNORE INTO worker_wait_progress (craft_id, updated_at) VALUES (?, ?)", craftID, time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	var updatedAt time.Time
+	err = w.db.QueryRow("SELECT updated_at FROM worker_wait_progress WHERE craft_id = ?", craftID).Scan(&updatedAt)
+	return updatedAt, err
+}
+
+func (w *WorkerStateDao) SetWaitReceived(craftID int, received map[string]int) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for uid, amount := range received {
+		_, err := tx.Exec(`INSERT INTO worker_wait_result (craft_id, item_uid, received) VALUES (?, ?, ?)
+		ON CONFLICT (craft_id, item_uid) DO UPDATE SET received = excluded.received`, craftID, uid, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setWaitProgressInOuterTx(tx, craftID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func DeleteWorkerStateInOuterTx(tx *sql.Tx, craftID int) error {
 	_, err := tx.Exec("DELETE FROM worker_state WHERE wait_craft_id = ?", craftID)
-	return err
+	if err != nil {
+		return err
+	}
+	return deleteWaitResultsInOuterTx(tx, craftID)
 }
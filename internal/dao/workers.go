@@ -4,43 +4,161 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"time"
 )
 
 var WORKER_TYPE_SHAPED_CRAFTER = "shaped_crafter"
 var WORKER_TYPE_PROCESSING_CRAFTER = "processing_crafter"
 var WORKER_TYPE_IMPORTER = "importer"
 var WORKER_TYPE_EXPORTER = "exporter"
+var WORKER_TYPE_FLUID_IMPORTER = "fluid_importer"
+var WORKER_TYPE_MACHINE_GROUP = "machine_group"
+var WORKER_TYPE_ACTUATOR = "actuator"
 
 var WORKER_TYPES = []string{
 	WORKER_TYPE_SHAPED_CRAFTER,
 	WORKER_TYPE_PROCESSING_CRAFTER,
 	WORKER_TYPE_IMPORTER,
 	WORKER_TYPE_EXPORTER,
+	WORKER_TYPE_FLUID_IMPORTER,
+	WORKER_TYPE_MACHINE_GROUP,
+	WORKER_TYPE_ACTUATOR,
 }
 
+const ACTUATOR_ACTION_CALL = "call"
+const ACTUATOR_ACTION_REDSTONE = "redstone"
+const ACTUATOR_ACTION_PULSE = "pulse"
+
+var ACTUATOR_ACTIONS = []string{
+	ACTUATOR_ACTION_CALL,
+	ACTUATOR_ACTION_REDSTONE,
+	ACTUATOR_ACTION_PULSE,
+}
+
+// ActuatorVerify reads Method of step peripheral after action and compares
+// result with Expect
+type ActuatorVerify struct {
+	Method string `json:"method"`
+	Args   []any  `json:"args,omitempty"`
+	Expect any    `json:"expect"`
+}
+
+// ActuatorStep is single action done through modem client. Call action calls
+// Method of Peripheral, redstone action sets Side output to Power and pulse
+// action sets Power for DurationMs and resets output to 0. Redstone
+// Peripheral is relay or integrator, empty means sides of modem computer
+type ActuatorStep struct {
+	Action     string          `json:"action"`
+	Peripheral string          `json:"peripheral,omitempty"`
+	Method     string          `json:"method,omitempty"`
+	Args       []any           `json:"args,omitempty"`
+	Side       string          `json:"side,omitempty"`
+	Power      int             `json:"power,omitempty"`
+	DurationMs int             `json:"durationMs,omitempty"`
+	TimeoutMs  int             `json:"timeoutMs,omitempty"`
+	Verify     *ActuatorVerify `json:"verify,omitempty"`
+}
+
+// ActuatorWorkerConfig runs Steps on each tick when Condition is true
+type ActuatorWorkerConfig struct {
+	Steps     []ActuatorStep `json:"steps"`
+	Condition string         `json:"condition,omitempty"`
+	WakeOn    []string       `json:"wakeOn,omitempty"`
+}
+
+// SingleImportConfig imports items from Storage (or only from Slot if set).
+// Allow and Deny contain item uids, "#tag" or "@mod" patterns, Leave is
+// amount of each item to keep in source and MaxPerTick limits items moved
+// per run
 type SingleImportConfig struct {
-	Storage string `json:"storage"`
-	Slot    int    `json:"slot"`
+	Storage    string   `json:"storage"`
+	Slot       int      `json:"slot"`
+	Allow      []string `json:"allow"`
+	Deny       []string `json:"deny"`
+	Leave      int      `json:"leave"`
+	MaxPerTick int      `json:"maxPerTick"`
 }
 
+// ImporterWorkerConfig imports only when Condition expression is true, empty
+// condition is always true
 type ImporterWorkerConfig struct {
-	Imports []SingleImportConfig `json:"imports"`
+	Imports   []SingleImportConfig `json:"imports"`
+	WakeOn    []string             `json:"wakeOn"`
+	Condition string               `json:"condition,omitempty"`
 }
 
+// SingleExportConfig keeps Amount of Item (or any item with Tag) stocked in
+// target Slots of Storage, or in whole Storage when no slots are set. Min is
+// amount which must remain in storage after export
 type SingleExportConfig struct {
 	Storage string `json:"storage"`
 	Item    string `json:"item"`
+	Tag     string `json:"tag"`
 	Slot    int    `json:"slot"`
+	Slots   []int  `json:"slots"`
 	Amount  int    `json:"amount"`
+	Min     int    `json:"min"`
+}
+
+func (c *SingleExportConfig) TargetSlots() []int {
+	if len(c.Slots) > 0 {
+		return c.Slots
+	}
+	if c.Slot > 0 {
+		return []int{c.Slot}
+	}
+	return nil
+}
+
+// SingleFluidImportConfig imports Fluids from Tank
+type SingleFluidImportConfig struct {
+	Tank   string   `json:"tank"`
+	Fluids []string `json:"fluids"`
+}
+
+type FluidImporterWorkerConfig struct {
+	Imports []SingleFluidImportConfig `json:"imports"`
 }
 
 type ExporterWorkerConfig struct {
-	Exports []SingleExportConfig `json:"exports"`
+	Exports   []SingleExportConfig `json:"exports"`
+	Condition string               `json:"condition,omitempty"`
 }
-type ShapedCrafterWorkerConfig struct {
+
+// ShapedCrafterTurtleConfig assigns crafting turtle client to worker, empty
+// storages default to worker storages
+type ShapedCrafterTurtleConfig struct {
+	Client        string `json:"client"`
 	InputStorage  string `json:"inputStorage"`
 	OutputStorage string `json:"outputStorage"`
 }
+
+// ShapedCrafterWorkerConfig crafts with several turtles in parallel,
+// ingredients are exported to InputStorage and results are imported from
+// OutputStorage. Empty InputStorage means turtle buffer and empty
+// OutputStorage means InputStorage
+type ShapedCrafterWorkerConfig struct {
+	InputStorage  string                      `json:"inputStorage"`
+	OutputStorage string                      `json:"outputStorage"`
+	Turtles       []ShapedCrafterTurtleConfig `json:"turtles"`
+}
+
+// TurtleStorages returns input and output storages of turtle with worker
+// defaults applied
+func (c *ShapedCrafterWorkerConfig) TurtleStorages(turtle *ShapedCrafterTurtleConfig) (string, string) {
+	inputStorage := turtle.InputStorage
+	if inputStorage == "" {
+		inputStorage = c.InputStorage
+	}
+	outputStorage := turtle.OutputStorage
+	if outputStorage == "" {
+		outputStorage = c.OutputStorage
+	}
+	return inputStorage, outputStorage
+}
+
 type ProcessingCrafterWorkerConfig struct {
 	CraftType      string `json:"craftType"`
 	InputInventory string `json:"inputInventory"`
@@ -55,20 +173,83 @@ type ProcessingCrafterWorkerConfig struct {
 
 	ResultFluids []string `json:"resultFluids"`
 	ResultTank   string   `json:"resultTank"`
+
+	WakeOn []string `json:"wakeOn"`
+
+	// BatchSize is max repeats pushed in one transaction, SlotCapacity and
+	// TankCapacity limit amount of single ingredient in machine, MaxInFlight
+	// is number of crafts waited at once, waited craft is closed with
+	// received yield when no results arrive for WaitTimeout seconds
+	BatchSize    int `json:"batchSize"`
+	SlotCapacity int `json:"slotCapacity"`
+	TankCapacity int `json:"tankCapacity"`
+	MaxInFlight  int `json:"maxInFlight"`
+	WaitTimeout  int `json:"waitTimeout"`
+
+	// PreCraft steps run before ingredients are moved to machine, PostCraft
+	// steps after craft is submitted, e.g. to start machine by pulse
+	PreCraft  []ActuatorStep `json:"preCraft,omitempty"`
+	PostCraft []ActuatorStep `json:"postCraft,omitempty"`
+}
+
+// MachineConfig is endpoint of single machine in machine group, Name
+// defaults to input inventory or input tank. PreCraft and PostCraft replace
+// group steps when set
+type MachineConfig struct {
+	Name            string         `json:"name,omitempty"`
+	InputInventory  string         `json:"inputInventory,omitempty"`
+	InputTank       string         `json:"inputTank,omitempty"`
+	ResultInventory string         `json:"resultInventory,omitempty"`
+	ResultTank      string         `json:"resultTank,omitempty"`
+	PreCraft        []ActuatorStep `json:"preCraft,omitempty"`
+	PostCraft       []ActuatorStep `json:"postCraft,omitempty"`
+}
+
+func (m *MachineConfig) GetName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	if m.InputInventory != "" {
+		return m.InputInventory
+	}
+	return m.InputTank
+}
+
+// MachineGroupWorkerConfig serves one craft queue by pool of identical
+// machines, inventories of embedded processing crafter config are replaced
+// by machine endpoints
+type MachineGroupWorkerConfig struct {
+	ProcessingCrafterWorkerConfig
+	Machines []MachineConfig `json:"machines"`
 }
 
 type WorkerConfig struct {
-	ShapedCrafter     *ShapedCrafterWorkerConfig     `json:"shapedCrafter"`
-	ProcessingCrafter *ProcessingCrafterWorkerConfig `json:"processingCrafter"`
-	Importer          *ImporterWorkerConfig          `json:"importer"`
-	Exporter          *ExporterWorkerConfig          `json:"exporter"`
+	ShapedCrafter     *ShapedCrafterWorkerConfig     `json:"shapedCrafter,omitempty"`
+	ProcessingCrafter *ProcessingCrafterWorkerConfig `json:"processingCrafter,omitempty"`
+	Importer          *ImporterWorkerConfig          `json:"importer,omitempty"`
+	Exporter          *ExporterWorkerConfig          `json:"exporter,omitempty"`
+	FluidImporter     *FluidImporterWorkerConfig     `json:"fluidImporter,omitempty"`
+	MachineGroup      *MachineGroupWorkerConfig      `json:"machineGroup,omitempty"`
+	Actuator          *ActuatorWorkerConfig          `json:"actuator,omitempty"`
+}
+
+// CraftType returns recipe type served by worker, empty for non crafting
+// workers
+func (c *WorkerConfig) CraftType() string {
+	if c.ProcessingCrafter != nil {
+		return c.ProcessingCrafter.CraftType
+	}
+	if c.MachineGroup != nil {
+		return c.MachineGroup.CraftType
+	}
+	return ""
 }
 
 type Worker struct {
-	Key     string
-	Type    string
-	Enabled bool
-	Config  WorkerConfig
+	Key     string       `json:"key"`
+	Type    string       `json:"type"`
+	Enabled bool         `json:"enabled"`
+	Config  WorkerConfig `json:"config"`
 }
 
 type WorkersDao struct {
@@ -82,6 +263,10 @@ func NewWorkersDao(db *sql.DB) (*WorkersDao, error) {
 		type string NOT NULL,
 		enabled bool NOT NULL,
 		config string NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS worker_migration (
+		name string NOT NULL PRIMARY KEY,
+		applied_at datetime NOT NULL
 	);`
 
 	_, err := db.Exec(sqlStmt)
@@ -172,20 +357,14 @@ func (w *WorkersDao) GetWorker(key string) (*Worker, error) {
 	return worker, tx.Commit()
 }
 
-func (w *WorkersDao) CreateWorker(worker *Worker) error {
+func createWorker(tx *sql.Tx, worker *Worker) error {
 	config, err := json.Marshal(worker.Config)
 	if err != nil {
 		return err
 	}
 
-	tx, err := w.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	if worker.Config.ProcessingCrafter != nil {
-		succ, err := SetWorkerForRecipeType(tx, worker.Config.ProcessingCrafter.CraftType, worker.Key)
+	if craftType := worker.Config.CraftType(); craftType != "" {
+		succ, err := SetWorkerForRecipeType(tx, craftType, worker.Key)
 		if err != nil {
 			return err
 		}
@@ -195,31 +374,36 @@ func (w *WorkersDao) CreateWorker(worker *Worker) error {
 	}
 
 	_, err = tx.Exec("INSERT INTO worker (key, type, enabled, config) VALUES (?, ?, ?, ?)", worker.Key, worker.Type, worker.Enabled, string(config))
+	return err
+}
+
+func (w *WorkersDao) CreateWorker(worker *Worker) error {
+	tx, err := w.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return tx.Commit()
-}
-
-func (w *WorkersDao) UpdateWorker(key string, worker *Worker) error {
-	config, err := json.Marshal(worker.Config)
+	err = createWorker(tx, worker)
 	if err != nil {
 		return err
 	}
 
-	tx, err := w.db.Begin()
+	return tx.Commit()
+}
+
+func updateWorker(tx *sql.Tx, key string, worker *Worker) error {
+	config, err := json.Marshal(worker.Config)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
 	prev, err := getWorker(tx, key)
 	if err != nil {
 		return err
 	}
-	if prev.Config.ProcessingCrafter != nil {
-		succ, err := UnSetWorkerForRecipeType(tx, prev.Config.ProcessingCrafter.CraftType, prev.Key)
+	if craftType := prev.Config.CraftType(); craftType != "" {
+		succ, err := UnSetWorkerForRecipeType(tx, craftType, prev.Key)
 		if err != nil {
 			return err
 		}
@@ -228,8 +412,8 @@ func (w *WorkersDao) UpdateWorker(key string, worker *Worker) error {
 		}
 	}
 
-	if worker.Config.ProcessingCrafter != nil {
-		succ, err := SetWorkerForRecipeType(tx, worker.Config.ProcessingCrafter.CraftType, worker.Key)
+	if craftType := worker.Config.CraftType(); craftType != "" {
+		succ, err := SetWorkerForRecipeType(tx, craftType, worker.Key)
 		if err != nil {
 			return err
 		}
@@ -239,6 +423,17 @@ func (w *WorkersDao) UpdateWorker(key string, worker *Worker) error {
 	}
 
 	_, err = tx.Exec("UPDATE worker SET key = ?, type = ?, enabled = ?, config = ? WHERE key = ?", worker.Key, worker.Type, worker.Enabled, string(config), key)
+	return err
+}
+
+func (w *WorkersDao) UpdateWorker(key string, worker *Worker) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateWorker(tx, key, worker)
 	if err != nil {
 		return err
 	}
@@ -246,32 +441,142 @@ func (w *WorkersDao) UpdateWorker(key string, worker *Worker) error {
 	return tx.Commit()
 }
 
+func deleteWorker(tx *sql.Tx, key string) error {
+	worker, err := getWorker(tx, key)
+	if err != nil {
+		return err
+	}
+
+	if craftType := worker.Config.CraftType(); craftType != "" {
+		succ, err := UnSetWorkerForRecipeType(tx, craftType, worker.Key)
+		if err != nil {
+			return err
+		}
+		if !succ {
+			return errors.New("can't unset recipe_type")
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM worker WHERE key = ?", key)
+	return err
+}
+
 func (w *WorkersDao) DeleteWorker(key string) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	worker, err := w.GetWorker(key)
+	err = deleteWorker(tx, key)
 	if err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
+// ApplyWorkers removes, updates and creates workers in single transaction,
+// existing workers from upserts are updated, others are created
+func (w *WorkersDao) ApplyWorkers(upserts []Worker, removes []string) error {
 	tx, err := w.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if worker.Config.ProcessingCrafter != nil {
-		succ, err := UnSetWorkerForRecipeType(tx, worker.Config.ProcessingCrafter.CraftType, worker.Key)
+	for _, key := range removes {
+		err = deleteWorker(tx, key)
+		if err != nil {
+			return fmt.Errorf("remove worker '%s': %w", key, err)
+		}
+	}
+
+	for i := range upserts {
+		worker := &upserts[i]
+		exists, err := workerExists(tx, worker.Key)
 		if err != nil {
 			return err
 		}
-		if !succ {
-			return errors.New("can't unset recipe_type")
+		if exists {
+			err = updateWorker(tx, worker.Key, worker)
+		} else {
+			err = createWorker(tx, worker)
+		}
+		if err != nil {
+			return fmt.Errorf("save worker '%s': %w", worker.Key, err)
 		}
 	}
 
-	_, err = tx.Exec("DELETE FROM worker WHERE key = ?", key)
+	return tx.Commit()
+}
+
+func workerExists(tx *sql.Tx, key string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM worker WHERE key = ?", key).Scan(&count)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return tx.Commit()
+	return count > 0, nil
+}
+
+// MigrateWorkers creates workers once for migration with given name, workers
+// with already existing keys or with recipe type already served by other
+// worker are skipped, their keys are returned. Returns false if migration was
+// already applied
+func (w *WorkersDao) MigrateWorkers(name string, workers []Worker) ([]string, bool, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	err = tx.QueryRow("SELECT COUNT(*) FROM worker_migration WHERE name = ?", name).Scan(&applied)
+	if err != nil {
+		return nil, false, err
+	}
+	if applied > 0 {
+		return nil, false, nil
+	}
+
+	var skipped []string
+	for i := range workers {
+		worker := &workers[i]
+		exists, err := workerExists(tx, worker.Key)
+		if err != nil {
+			return nil, false, err
+		}
+		if exists {
+			log.Printf("[WARN] Skip migration of worker '%s', key already exists", worker.Key)
+			skipped = append(skipped, worker.Key)
+			continue
+		}
+		if craftType := worker.Config.CraftType(); craftType != "" {
+			err = ensureRecipeType(tx, craftType)
+			if err != nil {
+				return nil, false, err
+			}
+			current, err := recipeTypeWorker(tx, craftType)
+			if err != nil {
+				return nil, false, err
+			}
+			if current != "" {
+				log.Printf("[WARN] Skip migration of worker '%s', recipe type '%s' is served by worker '%s'", worker.Key, craftType, current)
+				skipped = append(skipped, worker.Key)
+				continue
+			}
+		}
+		err = createWorker(tx, worker)
+		if err != nil {
+			return nil, false, fmt.Errorf("migrate worker '%s': %w", worker.Key, err)
+		}
+		log.Printf("[INFO] Migrated worker '%s'", worker.Key)
+	}
+
+	_, err = tx.Exec("INSERT INTO worker_migration (name, applied_at) VALUES (?, ?)", name, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+
+	return skipped, true, tx.Commit()
 }
@@ -7,12 +7,14 @@ import (
 	"math"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/a-h/templ"
 	"github.com/asek-ll/aecc-server/internal/common"
 	"github.com/asek-ll/aecc-server/internal/dao"
 	"github.com/asek-ll/aecc-server/internal/server/handlers"
 	"github.com/asek-ll/aecc-server/internal/services/crafter"
+	"github.com/asek-ll/aecc-server/internal/services/worker"
 )
 
 func getItem(ctx context.Context, uid string) *dao.Item {
@@ -113,6 +115,13 @@ func RecipeItemsToParams(items []dao.RecipeItem) url.Values {
 	return params
 }
 
+func chanceValue(chance *float64) string {
+	if chance == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*chance, 'f', -1, 64)
+}
+
 func RecipeToURL(recipe *dao.Recipe) string {
 	params := url.Values(make(map[string][]string))
 	params.Set("name", recipe.Name)
@@ -246,3 +255,72 @@ func craftPlanCreated(plan *crafter.Plan) []templ.Component {
 	}
 	return result
 }
+
+func findWorkerStatus(statuses []worker.WorkerStatus, key string) *worker.WorkerStatus {
+	for i := range statuses {
+		if statuses[i].Key == key {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+func diffLineStyle(op string) string {
+	switch op {
+	case "+":
+		return "color: green;"
+	case "-":
+		return "color: red;"
+	}
+	return ""
+}
+
+func recipeFilterValue(recipeID int) string {
+	if recipeID == 0 {
+		return ""
+	}
+	return strconv.Itoa(recipeID)
+}
+
+func layoutSummary(layout *dao.RecipeLayout) string {
+	if layout == nil {
+		return "No layout"
+	}
+	if layout.HasGrid() {
+		return fmt.Sprintf("Grid %dx%d", layout.Columns, layout.Rows)
+	}
+	return fmt.Sprintf("%d slots", len(layout.Slots))
+}
+
+func layoutColumnsValue(layout *dao.RecipeLayout) string {
+	if layout == nil || layout.Columns == 0 {
+		return ""
+	}
+	return strconv.Itoa(layout.Columns)
+}
+
+func layoutRowsValue(layout *dao.RecipeLayout) string {
+	if layout == nil || layout.Rows == 0 {
+		return ""
+	}
+	return strconv.Itoa(layout.Rows)
+}
+
+// layoutSlots formats named slots as lines of "<slot> <name> [fluid]"
+func layoutSlots(layout *dao.RecipeLayout) string {
+	if layout == nil {
+		return ""
+	}
+	var lines []string
+	for _, slot := range layout.Slots {
+		line := strconv.Itoa(slot.Slot)
+		if slot.Name != "" {
+			line += " " + slot.Name
+		}
+		if slot.Fluid {
+			line += " fluid"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -70,8 +70,10 @@
         label.appendChild(diag);
 
         fieldSet.appendChild(label);
-        fieldSet.appendChild(createInput("Slot", "slot"));
-        fieldSet.appendChild(createInput("Amount", "amount", "64"));
+        fieldSet.appendChild(createInput("Or tag", "tag"));
+        fieldSet.appendChild(createInput("Slots", "slot"));
+        fieldSet.appendChild(createInput("Keep amount", "amount", "64"));
+        fieldSet.appendChild(createInput("Min in storage", "min", "0"));
 
         const removeBtn = document.createElement("button");
         removeBtn.innerHTML = "Del";
@@ -112,6 +114,10 @@
 
         fieldSet.appendChild(createInput("Storage for import", "storage"));
         fieldSet.appendChild(createInput("Slot", "slot"));
+        fieldSet.appendChild(createInput("Allow (uid, #tag, @mod)", "allow"));
+        fieldSet.appendChild(createInput("Deny", "deny"));
+        fieldSet.appendChild(createInput("Leave in source", "leave", "0"));
+        fieldSet.appendChild(createInput("Max per tick", "max", "0"));
 
         const removeBtn = document.createElement("button");
         removeBtn.innerHTML = "Del";
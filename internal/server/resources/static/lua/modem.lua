@@ -1,9 +1,35 @@
 local m = peripheral.find 'modem' --[[@as ccTweaked.peripherals.WiredModem]]
 
+-- redstone relay or integrator by name, computer's own sides by default
+local function redstone_target(name)
+    if name == nil or name == '' then
+        return redstone
+    end
+    local target = peripheral.wrap(name)
+    if target == nil then
+        error('no redstone peripheral ' .. name)
+    end
+    return target
+end
+
 return function(methods, _, _)
     for n, f in pairs(m) do
         methods[n] = f
     end
 
+    methods['callPeripheral'] = function(params)
+        local value = m.callRemote(params.name, params.method, table.unpack(params.args or {}))
+        return { value = value }
+    end
+
+    methods['setRedstone'] = function(params)
+        redstone_target(params.name).setAnalogOutput(params.side, params.power)
+        return true
+    end
+
+    methods['getRedstone'] = function(params)
+        return redstone_target(params.name).getAnalogOutput(params.side)
+    end
+
     return {}
 end
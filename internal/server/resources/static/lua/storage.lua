@@ -62,14 +62,44 @@ local function get_items(storage_prefixes)
     return json_list(storages)
 end
 
+local function get_items_compact(storage_prefixes)
+    local dict = {}
+    local dict_index = {}
+    local inventories = {}
+
+    for _, storage in pairs(get_items(storage_prefixes)) do
+        local slots = {}
+        for _, cache_item in pairs(storage.items) do
+            local item = cache_item.item
+            local key = item.name .. '|' .. (item.nbt or '')
+            local idx = dict_index[key]
+            if idx == nil then
+                table.insert(dict, { name = item.name, nbt = item.nbt })
+                idx = #dict
+                dict_index[key] = idx
+            end
+            table.insert(slots, cache_item.slot)
+            table.insert(slots, idx)
+            table.insert(slots, item.count)
+        end
+        table.insert(inventories, { name = storage.name, size = storage.size, slots = json_list(slots) })
+    end
+
+    return { dict = json_list(dict), inventories = json_list(inventories) }
+end
+
 local function move_stack(params)
+    local to_slot = params['to']['slot']
+    if to_slot == 0 then
+        to_slot = nil
+    end
     return m.callRemote(
         params['from']['inventoryName'],
         'pushItems',
         params['to']['inventoryName'],
         params['from']['slot'],
         params['amount'],
-        params['to']['slot']
+        to_slot
     )
 end
 
@@ -142,9 +172,11 @@ local function measure_time(func)
         return result
     end
 end
-return function(methods, _, _)
+
+local function setup(methods, _, _)
     local cfg_load = loadfile 'config.lua'
     methods['getItems'] = measure_time(get_items)
+    methods['getItemsCompact'] = measure_time(get_items_compact)
     methods['moveStack'] = measure_time(move_stack)
     methods['getItemDetail'] = measure_time(get_item_detail)
     methods['getInventoryItems'] = measure_time(get_inventory_items)
@@ -157,3 +189,14 @@ return function(methods, _, _)
     end
     return config
 end
+
+-- older clients call module as function, V4 client reads setup and
+-- encodings fields
+return setmetatable({
+    setup = setup,
+    encodings = { 'compact' },
+}, {
+    __call = function(_, ...)
+        return setup(...)
+    end,
+})
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net"
@@ -24,9 +25,11 @@ import (
 	"github.com/asek-ll/aecc-server/internal/server/handlers"
 	"github.com/asek-ll/aecc-server/internal/server/resources/components"
 	"github.com/asek-ll/aecc-server/internal/services/clientscripts"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
 	"github.com/asek-ll/aecc-server/internal/services/crafter"
 	"github.com/asek-ll/aecc-server/internal/services/item"
 	"github.com/asek-ll/aecc-server/internal/services/recipe"
+	"github.com/asek-ll/aecc-server/internal/services/worker"
 	"github.com/asek-ll/aecc-server/internal/ws"
 	"github.com/asek-ll/aecc-server/pkg/template"
 	"github.com/fatih/color"
@@ -59,6 +62,14 @@ func handleFuncWithError(mux *MiddlewaresGroup, pattern string, handler func(w h
 	})
 }
 
+func currentUserName(r *http.Request) string {
+	user, err := token.GetUserInfo(r)
+	if err != nil {
+		return "unknown"
+	}
+	return user.Name
+}
+
 type rwWithStatus struct {
 	http.ResponseWriter
 	status int
@@ -168,8 +179,82 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		if err != nil {
 			return err
 		}
+		enrollments, err := app.ClientsService.GetActiveEnrollments()
+		if err != nil {
+			return err
+		}
 		code := fmt.Sprintf("wget %s/lua/v3/client/ startup", app.ConfigLoader.Config.WebServer.Url)
-		return components.GenClientsPage(clients, code).Render(r.Context(), w)
+		return components.GenClientsPage(clients, code, app.ClientsManager.GetQueueStats(), enrollments, app.ConfigLoader.Config.WebServer.Url).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "GET /rpc-records/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		filter := parseRpcRecordFilter(r)
+		var records []dao.RpcRecord
+		if app.Recorder != nil {
+			var err error
+			records, err = app.Recorder.Query(filter)
+			if err != nil {
+				return err
+			}
+		}
+		return components.RpcRecordsPage(records, filter, app.Recorder != nil).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "GET /craft-yields/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		filter := parseCraftYieldFilter(r)
+		yields, err := app.Daos.CraftYields.Query(filter)
+		if err != nil {
+			return err
+		}
+		stats, err := app.Daos.CraftYields.GetRecipeStats()
+		if err != nil {
+			return err
+		}
+		return components.CraftYieldsPage(yields, stats, filter).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /craft-yields/clear/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := app.Daos.CraftYields.Clear()
+		if err != nil {
+			return err
+		}
+		w.Header().Add("HX-Location", "/craft-yields/")
+		return nil
+	})
+
+	handleFuncWithError(common, "GET /conditions/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		conditions, err := getConditionStatuses(app)
+		if err != nil {
+			return err
+		}
+		return components.ConditionsPage(conditions).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /conditions/eval/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := r.ParseForm()
+		if err != nil {
+			return err
+		}
+		expr := r.FormValue("expr")
+		return components.ConditionValue(app.CondService.Status("", expr)).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /clients/enrollments/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := app.ClientsService.CreateEnrollment(currentUserName(r))
+		if err != nil {
+			return err
+		}
+		w.Header().Add("HX-Location", "/clients/")
+		return nil
+	})
+
+	handleFuncWithError(common, "POST /clients/enrollments/{code}/delete/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := app.ClientsService.DeleteEnrollment(r.PathValue("code"))
+		if err != nil {
+			return err
+		}
+		w.Header().Add("HX-Location", "/clients/")
+		return nil
 	})
 
 	handleFuncWithError(common, "GET /clients/{clientID}/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -181,7 +266,11 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		if client == nil {
 			return fmt.Errorf("client not found")
 		}
-		return components.GenClientPage(client).Render(r.Context(), w)
+		audit, err := app.ClientsService.GetAudit(client.ID)
+		if err != nil {
+			return err
+		}
+		return components.GenClientPage(client, audit).Render(r.Context(), w)
 	})
 
 	handleFuncWithError(common, "POST /clients/{clientID}/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -223,15 +312,17 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 			return err
 		}
 
-		client, err := app.Daos.Clients.GetClientByID(clientID)
+		return app.ClientsService.Authorize(clientID, currentUserName(r))
+	})
+
+	handleFuncWithError(common, "POST /clients/{clientID}/revoke/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		clientID := r.PathValue("clientID")
+		err := app.ClientsService.Revoke(clientID, currentUserName(r))
 		if err != nil {
 			return err
 		}
-		if client == nil {
-			return fmt.Errorf("client not found")
-		}
-
-		return app.Daos.Clients.AuthorizeClient(client)
+		w.Header().Add("HX-Location", "/clients/")
+		return nil
 	})
 
 	handleFuncWithError(common, "POST /clients/{clientID}/delete/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -359,7 +450,7 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 			return err
 		}
 
-		ctx, err := app.Daos.Items.NewDeferedLoader().FromRecipes(item.Recipes).FromRecipes(item.ImportedRecipes).ToContext(r.Context())
+		ctx, err := app.Daos.Items.NewDeferedLoader().FromRecipes(item.Recipes).FromRecipes(item.Usages).FromRecipes(item.ImportedRecipes).ToContext(r.Context())
 		if err != nil {
 			return err
 		}
@@ -373,6 +464,48 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return components.ItemPage(item, createUrl, itemCount).Render(ctx, w)
 	})
 
+	handleFuncWithError(common, "GET /items/{itemUid}/graph/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		graph, err := app.Planner.GetItemGraph(r.PathValue("itemUid"))
+		if err != nil {
+			return err
+		}
+
+		loader := app.Daos.Items.NewDeferedLoader()
+		for uid := range graph.Nodes {
+			loader.AddUid(uid)
+		}
+		ctx, err := loader.ToContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return components.ItemGraphChildren(graph).Render(ctx, w)
+	})
+
+	handleFuncWithError(common, "GET /items/{itemUid}/cost/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		amount, err := parseCostAmount(r)
+		if err != nil {
+			return components.ErrorMessage(err.Error()).Render(r.Context(), w)
+		}
+		cost, err := app.Planner.GetItemCost(r.PathValue("itemUid"), amount)
+		if err != nil {
+			return components.ErrorMessage(err.Error()).Render(r.Context(), w)
+		}
+
+		loader := app.Daos.Items.NewDeferedLoader()
+		for _, stacks := range [][]crafter.CostStack{cost.Resources, cost.Catalysts, cost.Leftovers} {
+			for _, stack := range stacks {
+				loader.AddUid(stack.ItemUID)
+			}
+		}
+		ctx, err := loader.ToContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return components.ItemCost(cost).Render(ctx, w)
+	})
+
 	handleFuncWithError(common, "GET /lua/client/{role}", func(w http.ResponseWriter, r *http.Request) error {
 		role := r.PathValue("role")
 
@@ -400,7 +533,19 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 
 	anon.HandleFunc("GET /lua/v3/client/", func(w http.ResponseWriter, r *http.Request) {
 		secret := r.URL.Query().Get("secret")
-		if secret == "" {
+		code := r.URL.Query().Get("code")
+		if code != "" {
+			enrolledSecret, err := app.ClientsService.Enroll(code)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			secret = enrolledSecret
+		} else if secret == "" {
+			if app.ClientsService.RequireEnrollment() {
+				http.Error(w, "Enrollment code is required", http.StatusForbidden)
+				return
+			}
 			secret = uuid.NewString()
 		}
 		params := map[string]any{
@@ -446,6 +591,14 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return components.RecipesPage(filter, recipes).Render(ctx, w)
 	})
 
+	handleFuncWithError(common, "GET /recipes/lint/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		issues, err := app.RecipeManager.LintRecipes()
+		if err != nil {
+			return err
+		}
+		return components.RecipesLint(issues).Render(r.Context(), w)
+	})
+
 	handleFuncWithError(common, "GET /craft-plans/new/{$}", func(w http.ResponseWriter, r *http.Request) error {
 
 		items, err := recipe.ParseItemsParams(r.URL.Query())
@@ -493,6 +646,47 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return components.Page(fmt.Sprintf("Recipe for %s", recipe.Name), components.CreateRecipeForm(recipe, recipeTypes)).Render(ctx, w)
 	})
 
+	// layout inputs of recipe form for selected type, ingredients placed in
+	// slots are kept
+	handleFuncWithError(common, "GET /recipes/layout/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		query := r.URL.Query()
+		items, err := recipe.ParseItemsParams(query)
+		if err != nil {
+			return err
+		}
+
+		var ingredients []dao.RecipeItem
+		for _, item := range items {
+			if item.Role == dao.INGREDIENT_ROLE && item.Slot != nil {
+				ingredients = append(ingredients, dao.RecipeItem{
+					ItemUID: item.ItemUID,
+					Amount:  item.Amount,
+					Role:    item.Role,
+					Slot:    item.Slot,
+				})
+			}
+		}
+
+		layout, err := app.Daos.RecipeTypes.GetRecipeLayout(query.Get("type"))
+		if err != nil {
+			return err
+		}
+		if layout == nil {
+			layout = &dao.SHAPED_CRAFT_LAYOUT
+		}
+
+		loader := app.Daos.Items.NewDeferedLoader()
+		for _, ing := range ingredients {
+			loader.AddUid(ing.ItemUID)
+		}
+		ctx, err := loader.ToContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return components.RecipeLayoutInputs(layout, ingredients).Render(ctx, w)
+	})
+
 	handleFuncWithError(common, "GET /recipes/{recipeId}/{$}", func(w http.ResponseWriter, r *http.Request) error {
 		rawRecipeId := r.PathValue("recipeId")
 		recipeId, err := strconv.Atoi(rawRecipeId)
@@ -573,6 +767,22 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return nil
 	})
 
+	handleFuncWithError(common, "GET /recipes-import/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		return components.RecipesImportPage().Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /recipes-import/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		sources, err := readUploadedImportSources(r)
+		if err != nil {
+			return components.ErrorMessage(err.Error()).Render(r.Context(), w)
+		}
+		report, err := app.RecipeManager.ImportRecipeBook(sources, app.ConfigLoader.Config.RecipeImport.Types)
+		if err != nil {
+			return err
+		}
+		return components.RecipesImportReport(report).Render(r.Context(), w)
+	})
+
 	handleFuncWithError(common, "DELETE /recipes/{recipeId}/{$}", func(w http.ResponseWriter, r *http.Request) error {
 		rawRecipeId := r.PathValue("recipeId")
 		recipeId, err := strconv.Atoi(rawRecipeId)
@@ -745,7 +955,7 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 			toTransfer = append(toTransfer, &crafter.Stack{ItemID: goal.ItemUID, Count: goal.Amount})
 		}
 
-		err = app.PlayerManager.SendItems(toTransfer)
+		err = app.PlayerManager.SendItems(r.Context(), toTransfer)
 		if err != nil {
 			if r.URL.Query().Get("force") != "true" {
 				return err
@@ -993,7 +1203,7 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 				Count:  ing.Amount,
 			})
 		}
-		err = app.PlayerManager.SendItems(stacks)
+		err = app.PlayerManager.SendItems(r.Context(), stacks)
 		if err != nil {
 			return err
 		}
@@ -1070,6 +1280,24 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return nil
 	})
 
+	handleFuncWithError(common, "POST /recipe-types/{name}/layout/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := r.ParseForm()
+		if err != nil {
+			return err
+		}
+
+		layout, err := recipe.ParseRecipeLayoutParams(r.PostForm)
+		if err == nil {
+			err = app.Daos.RecipeTypes.SetRecipeLayout(r.PathValue("name"), layout)
+		}
+		if err != nil {
+			return components.ErrorMessage(err.Error()).Render(r.Context(), w)
+		}
+
+		w.Header().Add("HX-Location", "/recipe-types")
+		return nil
+	})
+
 	handleFuncWithError(common, "POST /items/{itemUid}/sendToPlayer/{$}", func(w http.ResponseWriter, r *http.Request) error {
 		uid := r.PathValue("itemUid")
 
@@ -1084,7 +1312,7 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 			return err
 		}
 
-		return app.PlayerManager.SendItems([]*crafter.Stack{{ItemID: uid, Count: amount}})
+		return app.PlayerManager.SendItems(r.Context(), []*crafter.Stack{{ItemID: uid, Count: amount}})
 	})
 
 	handleFuncWithError(common, "POST /items/{itemUid}/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -1116,7 +1344,16 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		if err != nil {
 			return err
 		}
-		return components.WorkersPage(workers).Render(r.Context(), w)
+		return components.WorkersPage(workers, app.WorkerManager.GetWorkerStatuses()).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /workers/{key}/{action}/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := workerAction(app, r.PathValue("key"), r.PathValue("action"))
+		if err != nil {
+			return err
+		}
+		w.Header().Add("HX-Location", "/workers/")
+		return nil
 	})
 
 	handleFuncWithError(common, "GET /workers/{key}/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -1136,7 +1373,15 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 			return err
 		}
 
-		return components.EditWorkerPage(params).Render(ctx, w)
+		if worker.Config.MachineGroup != nil {
+			machines, err := app.WorkerManager.GetMachineStats(key)
+			if err != nil {
+				return err
+			}
+			return components.EditWorkerPage(params, machines).Render(ctx, w)
+		}
+
+		return components.EditWorkerPage(params, nil).Render(ctx, w)
 	})
 
 	handleFuncWithError(common, "GET /workers-new/{$}", func(w http.ResponseWriter, r *http.Request) error {
@@ -1220,6 +1465,31 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return nil
 	})
 
+	handleFuncWithError(common, "GET /workers-import/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		return components.WorkersImportPage().Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /workers-import/preview/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		data := r.FormValue("data")
+		plan, err := app.WorkerManager.PlanWorkersImport([]byte(data), r.FormValue("prune") == "true")
+		if err != nil {
+			return components.WorkersImportError(err.Error()).Render(r.Context(), w)
+		}
+		return components.WorkersImportPreview(plan, data).Render(r.Context(), w)
+	})
+
+	handleFuncWithError(common, "POST /workers-import/apply/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		plan, err := app.WorkerManager.PlanWorkersImport([]byte(r.FormValue("data")), r.FormValue("prune") == "true")
+		if err == nil {
+			err = app.WorkerManager.ApplyWorkersImport(plan)
+		}
+		if err != nil {
+			return components.WorkersImportError(err.Error()).Render(r.Context(), w)
+		}
+		w.Header().Add("HX-Location", "/workers/")
+		return nil
+	})
+
 	handleFuncWithError(common, "GET /remotes/{$}", func(w http.ResponseWriter, r *http.Request) error {
 		peripherals, err := app.ModemManager.GetPeripherals()
 		if err != nil {
@@ -1444,6 +1714,261 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 		return encoder.Encode(result)
 	})
 
+	handleFuncWithError(common, "GET /api/v1/clients/queues/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(app.ClientsManager.GetQueueStats())
+	})
+
+	handleFuncWithError(common, "GET /api/v1/events/streams/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(app.ClientsManager.Events().GetStreams())
+	})
+
+	handleFuncWithError(common, "POST /api/v1/workers/{key}/trigger/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		if !app.WorkerManager.TriggerWorker(r.PathValue("key")) {
+			http.Error(w, "Worker is not running", http.StatusNotFound)
+			return nil
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	handleFuncWithError(common, "GET /api/v1/workers/{key}/machines/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		stats, err := app.WorkerManager.GetMachineStats(r.PathValue("key"))
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(stats)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/workers/status/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(app.WorkerManager.GetWorkerStatuses())
+	})
+
+	handleFuncWithError(common, "GET /api/v1/workers/export/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = worker.WORKERS_FORMAT_JSON
+		}
+		data, err := app.WorkerManager.ExportWorkers(format)
+		if err != nil {
+			return err
+		}
+		w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"workers.%s\"", format))
+		_, err = w.Write(data)
+		return err
+	})
+
+	// import of workers set, changes are only planned unless apply=true
+	handleFuncWithError(common, "POST /api/v1/workers/import/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		query := r.URL.Query()
+		plan, err := app.WorkerManager.PlanWorkersImport(data, query.Get("prune") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if len(plan.Errors) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else if query.Get("apply") == "true" {
+			err = app.WorkerManager.ApplyWorkersImport(plan)
+			if err != nil {
+				return err
+			}
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(plan)
+	})
+
+	handleFuncWithError(common, "POST /api/v1/workers/{key}/{action}/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		err := workerAction(app, r.PathValue("key"), r.PathValue("action"))
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	handleFuncWithError(common, "GET /api/v1/rpc-records/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		var records []dao.RpcRecord
+		if app.Recorder != nil {
+			var err error
+			records, err = app.Recorder.Query(parseRpcRecordFilter(r))
+			if err != nil {
+				return err
+			}
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(records)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/craft-yields/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		yields, err := app.Daos.CraftYields.Query(parseCraftYieldFilter(r))
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(yields)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/craft-yields/stats/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		stats, err := app.Daos.CraftYields.GetRecipeStats()
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(stats)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/transactions/locks/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(app.TransferTransactionManager.GetLockedSubjects())
+	})
+
+	// body is JSON document or datapack archive, name is used for datapack
+	// recipe names
+	handleFuncWithError(common, "POST /api/v1/recipes/import/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = "body"
+		}
+		sources, err := recipe.ReadImportSources(name, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		report, err := app.RecipeManager.ImportRecipeBook(sources, app.ConfigLoader.Config.RecipeImport.Types)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(report)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/items/{itemUid}/graph/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		graph, err := app.Planner.GetItemGraph(r.PathValue("itemUid"))
+		if err != nil {
+			return err
+		}
+		if r.URL.Query().Get("format") == "dot" {
+			w.Header().Add("Content-Type", "text/vnd.graphviz")
+			return graph.WriteDOT(w)
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(graph)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/items/{itemUid}/cost/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		amount, err := parseCostAmount(r)
+		if err != nil {
+			return err
+		}
+		cost, err := app.Planner.GetItemCost(r.PathValue("itemUid"), amount)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(cost)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/items/{itemUid}/usages/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		usages, err := app.RecipeManager.GetItemUsages(r.PathValue("itemUid"))
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(usages)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/recipe-types/{name}/layout/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		layout, err := app.Daos.RecipeTypes.GetRecipeLayout(r.PathValue("name"))
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(layout)
+	})
+
+	// replaces layout of recipe type, null body removes it
+	handleFuncWithError(common, "PUT /api/v1/recipe-types/{name}/layout/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		var layout *dao.RecipeLayout
+		err := json.NewDecoder(r.Body).Decode(&layout)
+		if err != nil {
+			return err
+		}
+		return app.Daos.RecipeTypes.SetRecipeLayout(r.PathValue("name"), layout)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/recipes/lint/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		issues, err := app.RecipeManager.LintRecipes()
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(issues)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/recipes/bundle/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		query := r.URL.Query()
+		filter := recipe.RecipeBundleFilter{
+			Name: query.Get("name"),
+		}
+		if query.Has("type") {
+			recipeType := query.Get("type")
+			filter.Type = &recipeType
+		}
+		bundle, err := app.RecipeManager.ExportRecipeBundle(filter)
+		if err != nil {
+			return err
+		}
+		w.Header().Add("Content-Disposition", "attachment; filename=\"recipes.json\"")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(bundle)
+	})
+
+	// import of recipe bundle, changes are only planned unless apply=true
+	handleFuncWithError(common, "POST /api/v1/recipes/bundle/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		query := r.URL.Query()
+		plan, err := app.RecipeManager.PlanRecipeBundleImport(data, query.Get("conflict"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if len(plan.Errors) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else if query.Get("apply") == "true" {
+			err = app.RecipeManager.ApplyRecipeBundleImport(plan)
+			if err != nil {
+				return err
+			}
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(plan)
+	})
+
+	handleFuncWithError(common, "GET /api/v1/conditions/{$}", func(w http.ResponseWriter, r *http.Request) error {
+		conditions, err := getConditionStatuses(app)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(conditions)
+	})
+
 	handleFuncWithError(common, "/", func(w http.ResponseWriter, r *http.Request) error {
 		w.WriteHeader(http.StatusNotFound)
 		return components.Page("Not found").Render(r.Context(), w)
@@ -1451,3 +1976,110 @@ func CreateMux(app *app.App, wsServer *ws.Server) (http.Handler, error) {
 
 	return mux, nil
 }
+
+func parseRpcRecordFilter(r *http.Request) dao.RpcRecordFilter {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 200
+	}
+	return dao.RpcRecordFilter{
+		ClientID: query.Get("client"),
+		Method:   query.Get("method"),
+		Limit:    limit,
+	}
+}
+
+func parseCraftYieldFilter(r *http.Request) dao.CraftYieldFilter {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 200
+	}
+	recipeID, _ := strconv.Atoi(query.Get("recipe"))
+	return dao.CraftYieldFilter{
+		WorkerKey:      query.Get("worker"),
+		RecipeID:       recipeID,
+		OnlyDeviations: query.Get("deviations") != "",
+		Limit:          limit,
+	}
+}
+
+// parseCostAmount returns amount param, default is one item
+func parseCostAmount(r *http.Request) (int, error) {
+	amountStr := r.URL.Query().Get("amount")
+	if amountStr == "" {
+		return 1, nil
+	}
+	amount, err := strconv.Atoi(amountStr)
+	if err != nil {
+		return 0, err
+	}
+	if amount <= 0 {
+		return 0, fmt.Errorf("amount should be positive, got %d", amount)
+	}
+	return amount, nil
+}
+
+func workerAction(app *app.App, key string, action string) error {
+	switch action {
+	case "pause":
+		return app.WorkerManager.PauseWorker(key)
+	case "resume":
+		return app.WorkerManager.ResumeWorker(key)
+	case "run":
+		return app.WorkerManager.RunWorkerNow(key)
+	case "reset":
+		return app.WorkerManager.ResetWorker(key)
+	}
+	return fmt.Errorf("unknown worker action: %s", action)
+}
+
+// getConditionStatuses evaluates conditions of workers and inventory cleanup
+func getConditionStatuses(app *app.App) ([]cond.ConditionStatus, error) {
+	var result []cond.ConditionStatus
+	conditions, err := app.WorkerManager.GetConditions()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range conditions {
+		source := fmt.Sprintf("worker %s (%s)", c.Key, c.Type)
+		result = append(result, app.CondService.Status(source, c.Expr))
+	}
+	cleanup, err := app.Daos.Configs.GetConfig("cleanup-inventory-condition")
+	if err != nil {
+		return nil, err
+	}
+	if cleanup != "" {
+		result = append(result, app.CondService.Status("config cleanup-inventory-condition", cleanup))
+	}
+	return result, nil
+}
+
+func readUploadedImportSources(r *http.Request) ([]recipe.ImportSource, error) {
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		return nil, err
+	}
+	var sources []recipe.ImportSource
+	for _, header := range r.MultipartForm.File["files"] {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		fileSources, err := recipe.ReadImportSources(header.Filename, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Filename, err)
+		}
+		sources = append(sources, fileSources...)
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no files uploaded")
+	}
+	return sources, nil
+}
@@ -1,26 +1,64 @@
 package clients
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/google/uuid"
 )
 
+const ENROLLMENT_TTL = time.Minute * 15
+
+var ErrUnknownClient = errors.New("unknown client")
+var ErrInvalidEnrollment = errors.New("enrollment code is invalid or expired")
+
 type ClientsService struct {
-	clientsDao *dao.ClientsDao
+	clientsDao        *dao.ClientsDao
+	clientAuthDao     *dao.ClientAuthDao
+	requireEnrollment bool
+	onRevoke          func(client *dao.Client) error
 }
 
-func NewClientsService(clientsDao *dao.ClientsDao) *ClientsService {
+func NewClientsService(clientsDao *dao.ClientsDao, clientAuthDao *dao.ClientAuthDao, requireEnrollment bool) *ClientsService {
 	return &ClientsService{
-		clientsDao: clientsDao,
+		clientsDao:        clientsDao,
+		clientAuthDao:     clientAuthDao,
+		requireEnrollment: requireEnrollment,
 	}
 }
 
-func (s *ClientsService) GetClientBySecret(secret string) (*dao.Client, error) {
+func (s *ClientsService) SetOnRevoke(handler func(client *dao.Client) error) {
+	s.onRevoke = handler
+}
+
+func (s *ClientsService) RequireEnrollment() bool {
+	return s.requireEnrollment
+}
+
+func hashSecret(secret string) string {
 	hash := sha256.Sum256([]byte(secret))
-	clientID := hex.EncodeToString(hash[:])
+	return hex.EncodeToString(hash[:])
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+func (s *ClientsService) GetClientBySecret(secret string) (*dao.Client, error) {
+	clientID := hashSecret(secret)
 
 	client, err := s.clientsDao.GetClientByID(clientID)
 	if err != nil {
@@ -31,6 +69,10 @@ func (s *ClientsService) GetClientBySecret(secret string) (*dao.Client, error) {
 		return client, nil
 	}
 
+	if s.requireEnrollment {
+		return nil, ErrUnknownClient
+	}
+
 	client = &dao.Client{
 		ID: clientID,
 	}
@@ -42,3 +84,137 @@ func (s *ClientsService) GetClientBySecret(secret string) (*dao.Client, error) {
 
 	return client, nil
 }
+
+func (s *ClientsService) CreateEnrollment(actor string) (*dao.ClientEnrollment, error) {
+	code, err := randomString(5)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	enrollment := &dao.ClientEnrollment{
+		Code:      code,
+		CreatedBy: actor,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ENROLLMENT_TTL),
+	}
+	err = s.clientAuthDao.CreateEnrollment(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (s *ClientsService) GetActiveEnrollments() ([]*dao.ClientEnrollment, error) {
+	return s.clientAuthDao.GetActiveEnrollments(time.Now())
+}
+
+func (s *ClientsService) DeleteEnrollment(code string) error {
+	return s.clientAuthDao.DeleteEnrollment(code)
+}
+
+// Enroll consumes one-time code and returns secret for new authorized client
+func (s *ClientsService) Enroll(code string) (string, error) {
+	secret := uuid.NewString()
+	client := &dao.Client{
+		ID:        hashSecret(secret),
+		LastLogin: time.Now(),
+	}
+
+	enrollment, err := s.clientAuthDao.EnrollClient(code, client, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if enrollment == nil {
+		return "", ErrInvalidEnrollment
+	}
+	log.Printf("[INFO] Client %s enrolled with code created by %s", client.ID, enrollment.CreatedBy)
+
+	return secret, nil
+}
+
+func hashEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// CheckToken accepts current token or pending one, pending token is confirmed
+// when client presents it, as client stored it. Client without any token can
+// login once after enrollment or authorization
+func (s *ClientsService) CheckToken(clientID string, token string) (bool, error) {
+	hash, err := s.clientAuthDao.GetTokenHash(clientID)
+	if err != nil {
+		return false, err
+	}
+	pendingHash, err := s.clientAuthDao.GetPendingTokenHash(clientID)
+	if err != nil {
+		return false, err
+	}
+	tokenHash := hashSecret(token)
+	if pendingHash != "" && hashEqual(pendingHash, tokenHash) {
+		return true, s.clientAuthDao.ConfirmPendingToken(clientID)
+	}
+	if hash != "" {
+		return hashEqual(hash, tokenHash), nil
+	}
+	if pendingHash != "" {
+		return false, nil
+	}
+	// token is not issued yet, first login after enrollment
+	first, err := s.clientAuthDao.UseFirstLogin(clientID)
+	if err != nil {
+		return false, err
+	}
+	if first {
+		log.Printf("[INFO] Client %s logged in without token for first time", clientID)
+	}
+	return first, nil
+}
+
+func (s *ClientsService) NewToken() (string, error) {
+	return randomString(20)
+}
+
+// SavePendingToken stores token before it is sent to client, so token stored
+// by client is accepted even when confirmation is lost
+func (s *ClientsService) SavePendingToken(clientID string, token string) error {
+	return s.clientAuthDao.SetPendingTokenHash(clientID, hashSecret(token))
+}
+
+// ConfirmToken makes pending token current, previous token is dropped
+func (s *ClientsService) ConfirmToken(clientID string) error {
+	return s.clientAuthDao.ConfirmPendingToken(clientID)
+}
+
+func (s *ClientsService) Authorize(clientID string, actor string) error {
+	client, err := s.clientsDao.GetClientByID(clientID)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return ErrUnknownClient
+	}
+	return s.clientAuthDao.AuthorizeClient(client.ID, actor)
+}
+
+func (s *ClientsService) Revoke(clientID string, actor string) error {
+	client, err := s.clientsDao.GetClientByID(clientID)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return ErrUnknownClient
+	}
+
+	err = s.clientAuthDao.RevokeClient(client.ID, actor)
+	if err != nil {
+		return err
+	}
+
+	if s.onRevoke != nil {
+		return s.onRevoke(client)
+	}
+	return nil
+}
+
+func (s *ClientsService) GetAudit(clientID string) ([]dao.ClientAuditRecord, error) {
+	return s.clientAuthDao.GetAuditRecords(clientID)
+}
@@ -2,21 +2,52 @@ package cond
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/storage"
 	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
+// WorkerStateProvider returns state of worker by key
+type WorkerStateProvider = func(key string) (string, bool)
+
+// ConditionStatus is current value of condition used by some component
+type ConditionStatus struct {
+	Source string
+	Expr   string
+	Value  string
+	Error  string
+}
+
 type CondService struct {
 	clients *wsmethods.ClientsManager
+	storage *storage.Storage
+	daos    *dao.DaoProvider
+
+	workerState WorkerStateProvider
+	mu          sync.Mutex
 }
 
-func NewCondService(clients *wsmethods.ClientsManager) *CondService {
+func NewCondService(clients *wsmethods.ClientsManager, storage *storage.Storage, daos *dao.DaoProvider) *CondService {
 	return &CondService{
 		clients: clients,
+		storage: storage,
+		daos:    daos,
 	}
 }
 
+func (s *CondService) SetWorkerStateProvider(provider WorkerStateProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workerState = provider
+}
+
 func (s *CondService) getClientForCheck(check string) *wsmethods.GenericClient {
 	clients := s.clients.GetClients()
 	for _, client := range clients {
@@ -28,6 +59,7 @@ func (s *CondService) getClientForCheck(check string) *wsmethods.GenericClient {
 	return nil
 }
 
+// Check sends check to remote client with role equal to check name
 func (s *CondService) Check(check string, params any) (bool, error) {
 
 	client := s.getClientForCheck(check)
@@ -47,3 +79,172 @@ func (s *CondService) Check(check string, params any) (bool, error) {
 	}
 	return res, nil
 }
+
+// Validate checks expression syntax
+func (s *CondService) Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// Eval evaluates condition expression, result must be boolean
+func (s *CondService) Eval(expr string) (bool, error) {
+	node, err := Parse(expr)
+	if err != nil {
+		return false, err
+	}
+	return evalBool(node, s)
+}
+
+// Status evaluates expression for display, any value type is allowed
+func (s *CondService) Status(source string, expr string) ConditionStatus {
+	status := ConditionStatus{
+		Source: source,
+		Expr:   expr,
+	}
+	node, err := Parse(expr)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	value, err := node.Eval(s)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Value = FormatValue(value)
+	return status
+}
+
+func (s *CondService) Ident(name string) (Value, error) {
+	return s.Check(name, nil)
+}
+
+func stringArg(name string, args []Value, idx int) (string, error) {
+	if idx >= len(args) {
+		return "", fmt.Errorf("%s: missing argument %d", name, idx+1)
+	}
+	value, ok := args[idx].(string)
+	if !ok {
+		return "", fmt.Errorf("%s: argument %d should be string", name, idx+1)
+	}
+	return value, nil
+}
+
+func expectArgs(name string, args []Value, count int) error {
+	if len(args) != count {
+		return fmt.Errorf("%s expects %d arguments, got %d", name, count, len(args))
+	}
+	return nil
+}
+
+// parseClock parses HH:MM to minutes from midnight
+func parseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("invalid hours in '%s'", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid minutes in '%s'", value)
+	}
+	return hours*60 + minutes, nil
+}
+
+// timeBetween checks if now is in [from, to) window, window can wrap midnight
+func timeBetween(now time.Time, from string, to string) (bool, error) {
+	start, err := parseClock(from)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return false, err
+	}
+	current := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return current >= start && current < end, nil
+	}
+	return current >= start || current < end, nil
+}
+
+// Call evaluates functions available in conditions:
+// count(uid), config(key), flag(key), time_between(from, to), hour(),
+// worker_state(key) and remote(role)
+func (s *CondService) Call(name string, args []Value) (Value, error) {
+	switch name {
+	case "count":
+		uid, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		count, err := s.storage.GetItemCount(uid)
+		if err != nil {
+			return nil, err
+		}
+		return float64(count), nil
+	case "config", "flag":
+		key, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		value, err := s.daos.Configs.GetConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		if name == "flag" {
+			return value == "true", nil
+		}
+		return value, nil
+	case "time_between":
+		err := expectArgs(name, args, 2)
+		if err != nil {
+			return nil, err
+		}
+		from, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		to, err := stringArg(name, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return timeBetween(time.Now(), from, to)
+	case "hour":
+		err := expectArgs(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return float64(time.Now().Hour()), nil
+	case "worker_state":
+		key, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		provider := s.workerState
+		s.mu.Unlock()
+		if provider == nil {
+			return nil, errors.New("worker states are not available")
+		}
+		state, e := provider(key)
+		if !e {
+			return nil, fmt.Errorf("worker '%s' not found", key)
+		}
+		return state, nil
+	case "remote":
+		role, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var params any
+		if len(args) > 1 {
+			params = args[1:]
+		}
+		return s.Check(role, params)
+	}
+	return nil, fmt.Errorf("unknown function '%s'", name)
+}
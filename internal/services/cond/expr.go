@@ -0,0 +1,459 @@
+package cond
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Value is result of expression evaluation, one of bool, float64 or string
+type Value any
+
+// Env resolves functions and bare identifiers of expression
+type Env interface {
+	Call(name string, args []Value) (Value, error)
+	Ident(name string) (Value, error)
+}
+
+type Node interface {
+	Eval(env Env) (Value, error)
+	String() string
+}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenCompare
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isIdentPart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_:.-", r)
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i += 1
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i += 1
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i += 1
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i += 1
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, fmt.Errorf("unexpected '%c' at %d", r, i)
+			}
+			kind := tokenAnd
+			if r == '|' {
+				kind = tokenOr
+			}
+			tokens = append(tokens, token{kind, string([]rune{r, r}), i})
+			i += 2
+		case strings.ContainsRune("<>=!", r):
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{tokenCompare, string([]rune{r, '='}), i})
+				i += 2
+				continue
+			}
+			switch r {
+			case '!':
+				tokens = append(tokens, token{tokenNot, "!", i})
+			case '=':
+				return nil, fmt.Errorf("unexpected '=' at %d, use '=='", i)
+			default:
+				tokens = append(tokens, token{tokenCompare, string(r), i})
+			}
+			i += 1
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				j += 1
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : j]), i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j += 1
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j]), i})
+			i = j
+		case isIdentStart(r):
+			j := i + 1
+			for j < len(runes) && isIdentPart(runes[j]) {
+				j += 1
+			}
+			text := string(runes[i:j])
+			kind := tokenIdent
+			switch strings.ToLower(text) {
+			case "and":
+				kind = tokenAnd
+			case "or":
+				kind = tokenOr
+			case "not":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind, text, i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at %d", r, i)
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "", len(runes)})
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos += 1
+	}
+	return t
+}
+
+// Parse parses condition expression. Expression combines comparisons and
+// function calls with AND, OR and NOT (or &&, || and !), bare identifier is
+// check of remote client with such role
+func Parse(expr string) (Node, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+	}
+	return node, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (Node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenCompare {
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", t.text, t.pos)
+		}
+		return &literalNode{value: value}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' at %d", closing.pos)
+		}
+		return node, nil
+	case tokenIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+		if p.peek().kind != tokenLParen {
+			return &identNode{name: t.text}, nil
+		}
+		p.next()
+		call := &callNode{name: t.text}
+		if p.peek().kind == tokenRParen {
+			p.next()
+			return call, nil
+		}
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			sep := p.next()
+			if sep.kind == tokenRParen {
+				return call, nil
+			}
+			if sep.kind != tokenComma {
+				return nil, fmt.Errorf("expected ',' or ')' at %d", sep.pos)
+			}
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+}
+
+// FormatValue formats evaluation result for display
+func FormatValue(value Value) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+type literalNode struct {
+	value Value
+}
+
+func (n *literalNode) Eval(env Env) (Value, error) {
+	return n.value, nil
+}
+
+func (n *literalNode) String() string {
+	return FormatValue(n.value)
+}
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) Eval(env Env) (Value, error) {
+	return env.Ident(n.name)
+}
+
+func (n *identNode) String() string {
+	return n.name
+}
+
+type callNode struct {
+	name string
+	args []Node
+}
+
+func (n *callNode) Eval(env Env) (Value, error) {
+	var args []Value
+	for _, arg := range n.args {
+		value, err := arg.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return env.Call(n.name, args)
+}
+
+func (n *callNode) String() string {
+	var args []string
+	for _, arg := range n.args {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("%s(%s)", n.name, strings.Join(args, ", "))
+}
+
+func evalBool(node Node, env Env) (bool, error) {
+	value, err := node.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s is %s, not boolean", node, FormatValue(value))
+	}
+	return b, nil
+}
+
+type notNode struct {
+	operand Node
+}
+
+func (n *notNode) Eval(env Env) (Value, error) {
+	value, err := evalBool(n.operand, env)
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+func (n *notNode) String() string {
+	return fmt.Sprintf("NOT %s", n.operand)
+}
+
+type logicNode struct {
+	and   bool
+	left  Node
+	right Node
+}
+
+func (n *logicNode) Eval(env Env) (Value, error) {
+	left, err := evalBool(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit to avoid needless remote checks
+	if n.and != left {
+		return left, nil
+	}
+	return evalBool(n.right, env)
+}
+
+func (n *logicNode) String() string {
+	op := "OR"
+	if n.and {
+		op = "AND"
+	}
+	return fmt.Sprintf("(%s %s %s)", n.left, op, n.right)
+}
+
+type compareNode struct {
+	op    string
+	left  Node
+	right Node
+}
+
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "==":
+		return cmp == 0
+	}
+	return cmp != 0
+}
+
+func (n *compareNode) Eval(env Env) (Value, error) {
+	left, err := n.left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			cmp := 0
+			if l < r {
+				cmp = -1
+			} else if l > r {
+				cmp = 1
+			}
+			return compareResult(n.op, cmp), nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return compareResult(n.op, strings.Compare(l, r)), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok && (n.op == "==" || n.op == "!=") {
+			return (l == r) == (n.op == "=="), nil
+		}
+	}
+	return nil, fmt.Errorf("can't compare %s %s %s", FormatValue(left), n.op, FormatValue(right))
+}
+
+func (n *compareNode) String() string {
+	return fmt.Sprintf("%s %s %s", n.left, n.op, n.right)
+}
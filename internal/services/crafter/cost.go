@@ -0,0 +1,132 @@
+package crafter
+
+import (
+	"sort"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+)
+
+type CostStack struct {
+	ItemUID string `json:"itemUid"`
+	Amount  int    `json:"amount"`
+}
+
+type CostSteps struct {
+	RecipeType string `json:"recipeType"`
+	Repeats    int    `json:"repeats"`
+}
+
+// ItemCost is amount of base resources needed to craft item from scratch,
+// storage contents are not taken into account
+type ItemCost struct {
+	UID    string `json:"uid"`
+	Amount int    `json:"amount"`
+	// Resources are items without recipe consumed by craft
+	Resources []CostStack `json:"resources"`
+	// Catalysts are required but not consumed, their own cost is not included
+	Catalysts []CostStack `json:"catalysts"`
+	// Leftovers are produced over required amount
+	Leftovers []CostStack `json:"leftovers"`
+	Steps     []CostSteps `json:"steps"`
+}
+
+type costKey struct {
+	uid    string
+	amount int
+}
+
+// GetItemCost returns cached cost, cache is reset on any recipe change
+func (p *Planner) GetItemCost(uid string, amount int) (*ItemCost, error) {
+	revision := p.daoProvider.Recipes.Revision()
+	key := costKey{uid: uid, amount: amount}
+
+	p.costsMu.Lock()
+	if p.costsRevision != revision {
+		p.costs = make(map[costKey]*ItemCost)
+		p.costsRevision = revision
+	}
+	cost, e := p.costs[key]
+	p.costsMu.Unlock()
+	if e {
+		return cost, nil
+	}
+
+	cost, err := p.computeItemCost(uid, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	p.costsMu.Lock()
+	if p.costsRevision == revision {
+		p.costs[key] = cost
+	}
+	p.costsMu.Unlock()
+
+	return cost, nil
+}
+
+// computeItemCost expands recipes in same order as GetPlanForItem, but with
+// empty storage, so every intermediate item is crafted
+func (p *Planner) computeItemCost(uid string, amount int) (*ItemCost, error) {
+	expandState, err := p.expandRecipes([]string{uid})
+	if err != nil {
+		return nil, err
+	}
+
+	state := map[string]int{uid: -amount}
+	catalysts := make(map[string]int)
+	steps := make(map[string]int)
+
+	for _, item := range expandState.Items {
+		if state[item] >= 0 {
+			continue
+		}
+		recipe, e := expandState.Recipes[item]
+		if !e {
+			continue
+		}
+
+		repeats := repeatsFor(-state[item], &recipe.Results[0])
+		for _, ing := range recipe.Ingredients {
+			state[ing.ItemUID] -= ing.Amount * repeats
+		}
+		for _, catalyst := range recipe.Catalysts {
+			catalysts[catalyst.ItemUID] = max(catalysts[catalyst.ItemUID], catalyst.Amount)
+		}
+		for _, result := range recipe.Results {
+			state[result.ItemUID] += expectedAmount(&result, repeats)
+		}
+
+		recipeType := recipe.Type
+		if recipeType == "" {
+			recipeType = "shaped_craft"
+		}
+		steps[recipeType] += repeats
+	}
+
+	cost := &ItemCost{
+		UID:    uid,
+		Amount: amount,
+	}
+	for _, item := range sortedUids(state) {
+		if _, e := expandState.Recipes[item]; !e && state[item] < 0 {
+			cost.Resources = append(cost.Resources, CostStack{ItemUID: item, Amount: -state[item]})
+		} else if state[item] > 0 {
+			cost.Leftovers = append(cost.Leftovers, CostStack{ItemUID: item, Amount: state[item]})
+		}
+	}
+	for _, item := range sortedUids(catalysts) {
+		cost.Catalysts = append(cost.Catalysts, CostStack{ItemUID: item, Amount: catalysts[item]})
+	}
+	for _, recipeType := range sortedUids(steps) {
+		cost.Steps = append(cost.Steps, CostSteps{RecipeType: recipeType, Repeats: steps[recipeType]})
+	}
+
+	return cost, nil
+}
+
+func sortedUids(m map[string]int) []string {
+	keys := common.MapKeys(m)
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package crafter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+)
+
+type GraphEdge struct {
+	ItemUID  string `json:"itemUid"`
+	Amount   int    `json:"amount"`
+	Catalyst bool   `json:"catalyst"`
+}
+
+type GraphNode struct {
+	UID           string `json:"uid"`
+	DisplayName   string `json:"displayName"`
+	StorageAmount int    `json:"storageAmount"`
+	Craftable     bool   `json:"craftable"`
+	RecipeID      int    `json:"recipeId,omitempty"`
+	RecipeName    string `json:"recipeName,omitempty"`
+	// ResultAmount is amount of item produced by one recipe repeat
+	ResultAmount int         `json:"resultAmount,omitempty"`
+	Edges        []GraphEdge `json:"edges"`
+}
+
+// ItemGraph is recipe graph used by planner, nodes are items and edges are
+// recipe ingredients and catalysts, it can contain cycles
+type ItemGraph struct {
+	Root  string                `json:"root"`
+	Nodes map[string]*GraphNode `json:"nodes"`
+}
+
+func (p *Planner) GetItemGraph(uid string) (*ItemGraph, error) {
+	items, _, recipeByResult, err := p.loadRecipes([]string{uid})
+	if err != nil {
+		return nil, err
+	}
+
+	storageCounts, err := p.storage.GetItemsCount()
+	if err != nil {
+		return nil, err
+	}
+
+	uids := common.MapKeys(items)
+	itemInfos, err := p.daoProvider.Items.FindItemsByUids(uids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, item := range itemInfos {
+		names[item.UID] = item.DisplayName
+	}
+
+	graph := &ItemGraph{
+		Root:  uid,
+		Nodes: make(map[string]*GraphNode),
+	}
+	for _, item := range uids {
+		node := &GraphNode{
+			UID:           item,
+			DisplayName:   names[item],
+			StorageAmount: storageCounts[item],
+		}
+		if recipe, e := recipeByResult[item]; e {
+			node.Craftable = true
+			node.RecipeID = recipe.ID
+			node.RecipeName = recipe.Name
+			node.ResultAmount = recipe.Results[0].Amount
+			for _, ing := range recipe.Ingredients {
+				node.addEdge(ing.ItemUID, ing.Amount, false)
+			}
+			for _, catalyst := range recipe.Catalysts {
+				node.addEdge(catalyst.ItemUID, catalyst.Amount, true)
+			}
+		}
+		graph.Nodes[item] = node
+	}
+
+	return graph, nil
+}
+
+// addEdge sums amounts of same ingredient from different slots
+func (n *GraphNode) addEdge(uid string, amount int, catalyst bool) {
+	for i := range n.Edges {
+		if n.Edges[i].ItemUID == uid && n.Edges[i].Catalyst == catalyst {
+			n.Edges[i].Amount += amount
+			return
+		}
+	}
+	n.Edges = append(n.Edges, GraphEdge{
+		ItemUID:  uid,
+		Amount:   amount,
+		Catalyst: catalyst,
+	})
+}
+
+func (n *GraphNode) Label() string {
+	if n.DisplayName != "" {
+		return n.DisplayName
+	}
+	return n.UID
+}
+
+// WriteDOT writes graph in Graphviz format, base resources which are missing
+// in storage are highlighted
+func (g *ItemGraph) WriteDOT(w io.Writer) error {
+	uids := common.MapKeys(g.Nodes)
+	sort.Strings(uids)
+
+	_, err := fmt.Fprintf(w, "digraph %s {\n\trankdir=LR;\n", strconv.Quote(g.Root))
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		node := g.Nodes[uid]
+		label := fmt.Sprintf("%s\n%d in storage", node.Label(), node.StorageAmount)
+		attrs := "shape=ellipse"
+		if node.Craftable {
+			label = fmt.Sprintf("%s\n%s x%d", label, node.RecipeName, node.ResultAmount)
+			attrs = "shape=box"
+		} else if node.StorageAmount == 0 {
+			attrs += " color=red"
+		}
+		_, err = fmt.Fprintf(w, "\t%s [label=%s %s];\n", strconv.Quote(uid), strconv.Quote(label), attrs)
+		if err != nil {
+			return err
+		}
+	}
+	for _, uid := range uids {
+		for _, edge := range g.Nodes[uid].Edges {
+			attrs := fmt.Sprintf("label=%d", edge.Amount)
+			if edge.Catalyst {
+				attrs += " style=dashed"
+			}
+			_, err = fmt.Fprintf(w, "\t%s -> %s [%s];\n", strconv.Quote(uid), strconv.Quote(edge.ItemUID), attrs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
@@ -3,7 +3,9 @@ package crafter
 import (
 	"errors"
 	"log"
+	"math"
 	"sort"
+	"sync"
 
 	"github.com/asek-ll/aecc-server/internal/common"
 	"github.com/asek-ll/aecc-server/internal/dao"
@@ -13,12 +15,17 @@ import (
 type Planner struct {
 	daoProvider *dao.DaoProvider
 	storage     *storage.Storage
+
+	costsMu       sync.Mutex
+	costs         map[costKey]*ItemCost
+	costsRevision int64
 }
 
 func NewPlanner(daoProvider *dao.DaoProvider, storage *storage.Storage) *Planner {
 	return &Planner{
 		daoProvider: daoProvider,
 		storage:     storage,
+		costs:       make(map[costKey]*ItemCost),
 	}
 }
 
@@ -27,7 +34,9 @@ type ExpandState struct {
 	Recipes map[string]*dao.Recipe
 }
 
-func (p *Planner) expandRecipes(itemIds []string) (*ExpandState, error) {
+// loadRecipes loads recipes for items and all their ingredients and catalysts,
+// deps are from recipe result to ingredients and catalysts
+func (p *Planner) loadRecipes(itemIds []string) (map[string]struct{}, map[string][]string, map[string]*dao.Recipe, error) {
 	deps := make(map[string][]string)
 	items := make(map[string]struct{})
 	recipeByResult := make(map[string]*dao.Recipe)
@@ -43,7 +52,7 @@ func (p *Planner) expandRecipes(itemIds []string) (*ExpandState, error) {
 		}
 		recipes, err := p.daoProvider.Recipes.GetRecipesByResults(recipesToLoad)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 
 		nextItems := make(map[string]struct{})
@@ -64,6 +73,14 @@ func (p *Planner) expandRecipes(itemIds []string) (*ExpandState, error) {
 	for _, v := range deps {
 		sort.Strings(v)
 	}
+	return items, deps, recipeByResult, nil
+}
+
+func (p *Planner) expandRecipes(itemIds []string) (*ExpandState, error) {
+	items, deps, recipeByResult, err := p.loadRecipes(itemIds)
+	if err != nil {
+		return nil, err
+	}
 	uids := common.MapKeys(items)
 	sort.Strings(uids)
 	orderedItems := common.TopologicalSort(uids, deps)
@@ -87,6 +104,23 @@ func ceil(x, y int) int {
 	return 1 + ((x - rem) / y)
 }
 
+// repeatsFor returns number of repeats which produce at least amount of
+// result, probabilistic results are accounted by expected value
+func repeatsFor(amount int, result *dao.RecipeItem) int {
+	if result.IsGuaranteed() {
+		return ceil(amount, result.Amount)
+	}
+	return int(math.Ceil(float64(amount) / result.ExpectedPerRepeat()))
+}
+
+// expectedAmount returns expected amount of result produced by repeats
+func expectedAmount(result *dao.RecipeItem, repeats int) int {
+	if result.IsGuaranteed() {
+		return result.Amount * repeats
+	}
+	return int(math.Floor(result.ExpectedPerRepeat() * float64(repeats)))
+}
+
 func (p *Planner) GetPlanForItem(goals []Stack) (*Plan, error) {
 	uids := make([]string, len(goals))
 	for i, goal := range goals {
@@ -134,7 +168,7 @@ func (p *Planner) GetPlanForItem(goals []Stack) (*Plan, error) {
 			continue
 		}
 
-		repeats := ceil(toCraft, recipe.Results[0].Amount)
+		repeats := repeatsFor(toCraft, &recipe.Results[0])
 
 		for _, ing := range recipe.Ingredients {
 			ingredientCount := ing.Amount * repeats
@@ -171,7 +205,7 @@ func (p *Planner) GetPlanForItem(goals []Stack) (*Plan, error) {
 		}
 
 		for _, ing := range recipe.Results {
-			ingredientCount := ing.Amount * repeats
+			ingredientCount := expectedAmount(&ing, repeats)
 			state[ing.ItemUID] += ingredientCount
 
 			r, e := related[ing.ItemUID]
@@ -1,6 +1,8 @@
 package crafter
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -15,8 +17,12 @@ type CraftWorker struct {
 	workerId string
 	client   *wsmethods.CrafterClient
 	storage  *storage.Storage
+	tm       *storage.TransferTransactionManager
 	daos     *dao.DaoProvider
 
+	inputStorage  string
+	outputStorage string
+
 	done chan bool
 	ping chan bool
 
@@ -24,18 +30,34 @@ type CraftWorker struct {
 	stopped   bool
 }
 
+// NewCraftWorker creates worker for crafting client, ingredients are exported
+// to inputStorage and results are imported from outputStorage. Empty
+// inputStorage means client buffer and empty outputStorage means inputStorage
 func NewCraftWorker(
 	workerId string,
 	client *wsmethods.CrafterClient,
 	storage *storage.Storage,
+	tm *storage.TransferTransactionManager,
 	daos *dao.DaoProvider,
+	inputStorage string,
+	outputStorage string,
 ) *CraftWorker {
+	if inputStorage == "" {
+		inputStorage = client.BufferName()
+	}
+	if outputStorage == "" {
+		outputStorage = inputStorage
+	}
 	return &CraftWorker{
 		workerId: workerId,
 		storage:  storage,
+		tm:       tm,
 		daos:     daos,
 		client:   client,
 
+		inputStorage:  inputStorage,
+		outputStorage: outputStorage,
+
 		done: make(chan bool),
 		ping: make(chan bool, 1),
 	}
@@ -70,13 +92,17 @@ func (c *CraftWorker) GetLastTypes() []string {
 	return c.lastTypes
 }
 
+func (c *CraftWorker) ClientID() string {
+	return c.client.ID
+}
+
 func (c *CraftWorker) cycle() {
 	for {
 		if c.stopped {
 			<-c.done
 			return
 		}
-		err := c.process()
+		_, err := c.Process()
 		if err != nil {
 			log.Printf("[ERROR] Can't process craft for worker '%s', error: %v", c.workerId, err)
 		}
@@ -110,6 +136,10 @@ func (c *CraftWorker) Restore(craft *dao.Craft) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+		err = c.importResults()
+		if err != nil {
+			return false, err
+		}
 	}
 	return done, nil
 }
@@ -154,12 +184,7 @@ func (c *CraftWorker) Craft(craft *dao.Craft) (bool, error) {
 	}
 	repeats := min(craft.Repeats, maxRepeats)
 
-	err = c.trasferItems(recipe, repeats)
-	if err != nil {
-		return false, err
-	}
-
-	err = c.daos.Crafts.CommitCraft(craft, recipe, repeats)
+	err = c.trasferItems(craft, recipe, repeats)
 	if err != nil {
 		return false, err
 	}
@@ -169,33 +194,41 @@ func (c *CraftWorker) Craft(craft *dao.Craft) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+		err = c.importResults()
+		if err != nil {
+			return false, err
+		}
 	}
 	return done, nil
 }
 
-func (c *CraftWorker) process() error {
+// Process restores current craft and performs pending crafts of supported
+// types, returns number of completed crafts
+func (c *CraftWorker) Process() (int, error) {
+	completed := 0
 	current, err := c.daos.Crafts.FindCurrent(c.workerId)
 	if err != nil {
-		return fmt.Errorf("Can't find current craft: %w", err)
+		return completed, fmt.Errorf("Can't find current craft: %w", err)
 	}
 	if current != nil {
 		done, err := c.Restore(current)
 		if err != nil {
-			return fmt.Errorf("Can't restore current craft: %w", err)
+			return completed, fmt.Errorf("Can't restore current craft: %w", err)
 		}
 		if !done {
-			return nil
+			return completed, nil
 		}
+		completed += 1
 	}
 
 	types, err := c.client.GetSupportTypes()
 	if err != nil {
-		return err
+		return completed, err
 	}
 	c.lastTypes = types
 
 	if len(types) == 0 {
-		return nil
+		return completed, nil
 	}
 
 	active := true
@@ -204,7 +237,7 @@ func (c *CraftWorker) process() error {
 
 		nexts, err := c.daos.Crafts.FindNextByTypes(types, c.workerId)
 		if err != nil {
-			return err
+			return completed, err
 		}
 
 		log.Println(types, nexts)
@@ -212,54 +245,78 @@ func (c *CraftWorker) process() error {
 		for _, craft := range nexts {
 			assigned, err := c.daos.Crafts.AssignCraftToWorker(craft, c.workerId)
 			if err != nil {
-				return err
+				return completed, err
 			}
 			if assigned {
 				done, err := c.Craft(craft)
 				if err != nil {
-					return err
+					return completed, err
 				}
 				if !done {
-					return nil
+					return completed, nil
 				}
+				completed += 1
 				active = true
 			}
 		}
 	}
 
-	return nil
+	return completed, nil
 
 }
-func (c *CraftWorker) trasferItems(recipe *dao.Recipe, repeats int) error {
+
+// trasferItems moves ingredients to input storage and commits craft in same
+// transfer transaction
+func (c *CraftWorker) trasferItems(craft *dao.Craft, recipe *dao.Recipe, repeats int) error {
 	log.Printf("[INFO] Transfer items for '%s' and recipe: %v", c.workerId, recipe)
-	usedSlots := make([]bool, 20)
+	layout, err := c.daos.RecipeTypes.GetRecipeLayout(recipe.Type)
+	if err != nil {
+		return err
+	}
+	ingredientSlots, catalystSlots, err := layout.AssignSlots(recipe)
+	if err != nil {
+		return err
+	}
+
+	var req storage.ExportRequest
 	for i, ing := range recipe.Ingredients {
-		slot := i + 1
-		if ing.Slot != nil {
-			slot = *ing.Slot
-		}
-		usedSlots[slot] = true
-		_, err := c.storage.ExportStack(ing.ItemUID, c.client.BufferName(), slot, ing.Amount*repeats)
-		if err != nil {
-			return err
-		}
+		req.RequestItems = append(req.RequestItems, storage.ExportRequestItems{
+			TargetStorage: c.inputStorage,
+			Uid:           ing.ItemUID,
+			ToSlot:        ingredientSlots[i],
+			Amount:        ing.Amount * repeats,
+		})
 	}
-	fromSlot := 1
-	for _, ing := range recipe.Catalysts {
-		for fromSlot < len(usedSlots) && usedSlots[fromSlot] {
-			fromSlot += 1
-		}
-		if fromSlot < len(usedSlots) {
-			usedSlots[fromSlot] = true
-		}
-		_, err := c.storage.ExportStack(ing.ItemUID, c.client.BufferName(), fromSlot, ing.Amount*repeats)
-		if err != nil {
-			return err
-		}
+	for i, ing := range recipe.Catalysts {
+		req.RequestItems = append(req.RequestItems, storage.ExportRequestItems{
+			TargetStorage: c.inputStorage,
+			Uid:           ing.ItemUID,
+			ToSlot:        catalystSlots[i],
+			Amount:        ing.Amount * repeats,
+		})
 	}
-	return nil
+	_, err = c.tm.Transfer(context.Background(), req, func(tx *sql.Tx) error {
+		return dao.CommitCraftInOuterTx(tx, craft, recipe, repeats)
+	})
+	return err
+}
+
+// importResults imports craft results from separate output storage, results
+// in input storage are imported on next cleanup
+func (c *CraftWorker) importResults() error {
+	if c.outputStorage == c.inputStorage {
+		return nil
+	}
+	return c.storage.ImportAll(c.outputStorage)
 }
 
 func (c *CraftWorker) cleanupBuffer() error {
-	return c.storage.ImportAll(c.client.BufferName())
+	err := c.storage.ImportAll(c.inputStorage)
+	if err != nil {
+		return err
+	}
+	if c.outputStorage != c.inputStorage {
+		return c.storage.ImportAll(c.outputStorage)
+	}
+	return nil
 }
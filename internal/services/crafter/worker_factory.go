@@ -1,6 +1,7 @@
 package crafter
 
 import (
+	"log"
 	"sync"
 
 	"github.com/asek-ll/aecc-server/internal/dao"
@@ -10,23 +11,54 @@ import (
 
 type WorkerFactory struct {
 	storage *storage.Storage
+	tm      *storage.TransferTransactionManager
 	daos    *dao.DaoProvider
 
 	workers map[string]*CraftWorker
 	mu      sync.RWMutex
+
+	clientFilter  func(clientID string) bool
+	pingListeners []func(recipeType string)
 }
 
-func NewWorkerFactory(storage *storage.Storage, daos *dao.DaoProvider) *WorkerFactory {
+func NewWorkerFactory(storage *storage.Storage, tm *storage.TransferTransactionManager, daos *dao.DaoProvider) *WorkerFactory {
 	return &WorkerFactory{
 		storage: storage,
+		tm:      tm,
 		daos:    daos,
 
 		workers: make(map[string]*CraftWorker),
 	}
 }
 
+// SetClientFilter sets check for clients which are served by configured
+// workers, factory does not create workers for them
+func (f *WorkerFactory) SetClientFilter(filter func(clientID string) bool) {
+	f.clientFilter = filter
+}
+
+// AddPingListener adds listener notified about new crafts of recipe type
+func (f *WorkerFactory) AddPingListener(listener func(recipeType string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pingListeners = append(f.pingListeners, listener)
+}
+
+// ReleaseClient stops worker created for client, so it can be served by
+// configured worker
+func (f *WorkerFactory) ReleaseClient(clientID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, worker := range f.workers {
+		if worker.ClientID() == clientID {
+			worker.Stop()
+			delete(f.workers, id)
+		}
+	}
+}
+
 func (f *WorkerFactory) NewWorker(id string, client *wsmethods.CrafterClient) *CraftWorker {
-	worker := NewCraftWorker(id, client, f.storage, f.daos)
+	worker := NewCraftWorker(id, client, f.storage, f.tm, f.daos, "", "")
 
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -44,6 +76,10 @@ func (f *WorkerFactory) NewWorker(id string, client *wsmethods.CrafterClient) *C
 func (f *WorkerFactory) HandleClientConnected(client wsmethods.Client) {
 	crafterClient, e := client.(*wsmethods.CrafterClient)
 	if e {
+		if f.clientFilter != nil && f.clientFilter(crafterClient.ID) {
+			log.Printf("[INFO] Crafter client '%s' is served by configured worker", crafterClient.ID)
+			return
+		}
 		f.NewWorker(crafterClient.Role, crafterClient)
 	}
 }
@@ -51,9 +87,11 @@ func (f *WorkerFactory) HandleClientConnected(client wsmethods.Client) {
 func (f *WorkerFactory) HandleClientDisconnected(client wsmethods.Client) {
 	crafterClient, e := client.(*wsmethods.CrafterClient)
 	if e {
-		f.workers[crafterClient.Role].Stop()
 		f.mu.Lock()
-		delete(f.workers, crafterClient.Role)
+		if worker, e := f.workers[crafterClient.Role]; e && worker.ClientID() == crafterClient.ID {
+			worker.Stop()
+			delete(f.workers, crafterClient.Role)
+		}
 		f.mu.Unlock()
 	}
 }
@@ -61,6 +99,9 @@ func (f *WorkerFactory) HandleClientDisconnected(client wsmethods.Client) {
 func (f *WorkerFactory) Ping(recipeType string) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
+	for _, listener := range f.pingListeners {
+		listener(recipeType)
+	}
 	for _, worker := range f.workers {
 		for _, tp := range worker.GetLastTypes() {
 			if tp == recipeType {
@@ -44,5 +44,9 @@ func (m *ItemManager) UpdateItem(uid string, params *ItemParams) (*dao.Item, err
 		return nil, err
 	}
 
+	if uid != item.UID {
+		m.daos.Recipes.Touch()
+	}
+
 	return item, nil
 }
@@ -1,6 +1,10 @@
 package modem
 
-import "github.com/asek-ll/aecc-server/internal/wsmethods"
+import (
+	"context"
+
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
+)
 
 type ModemManager struct {
 	modemAdapter *wsmethods.ModemAdapter
@@ -16,3 +20,33 @@ func NewModemManager(clientsManager *wsmethods.ClientsManager) *ModemManager {
 func (m *ModemManager) GetPeripherals() ([]string, error) {
 	return m.modemAdapter.GetNamesRemote()
 }
+
+func (m *ModemManager) GetMethods(ctx context.Context, name string) ([]string, error) {
+	return m.modemAdapter.GetMethodsRemote(ctx, name)
+}
+
+// CallPeripheral calls method of remote peripheral and returns its first value
+func (m *ModemManager) CallPeripheral(ctx context.Context, name string, method string, args []any) (any, error) {
+	if args == nil {
+		args = []any{}
+	}
+	return m.modemAdapter.CallPeripheral(ctx, wsmethods.CallPeripheralParams{
+		Name:   name,
+		Method: method,
+		Args:   args,
+	})
+}
+
+// SetRedstone sets analog output of side, name is redstone peripheral or
+// empty for sides of modem computer
+func (m *ModemManager) SetRedstone(ctx context.Context, name string, side string, power int) error {
+	return m.modemAdapter.SetRedstone(ctx, wsmethods.RedstoneParams{
+		Name:  name,
+		Side:  side,
+		Power: power,
+	})
+}
+
+func (m *ModemManager) GetRedstone(ctx context.Context, name string, side string) (int, error) {
+	return m.modemAdapter.GetRedstone(ctx, name, side)
+}
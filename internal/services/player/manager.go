@@ -1,27 +1,34 @@
 package player
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
 	"github.com/asek-ll/aecc-server/internal/services/crafter"
 	"github.com/asek-ll/aecc-server/internal/services/storage"
 	"github.com/asek-ll/aecc-server/internal/wsmethods"
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
 )
 
 type PlayerManager struct {
 	daoProvider    *dao.DaoProvider
 	clientsManager *wsmethods.ClientsManager
 	storage        *storage.Storage
+	tm             *storage.TransferTransactionManager
+	condService    *cond.CondService
 }
 
-func NewPlayerManager(daoProvider *dao.DaoProvider, clientsManager *wsmethods.ClientsManager, storage *storage.Storage) *PlayerManager {
+func NewPlayerManager(daoProvider *dao.DaoProvider, clientsManager *wsmethods.ClientsManager, storage *storage.Storage, tm *storage.TransferTransactionManager, condService *cond.CondService) *PlayerManager {
 	pm := &PlayerManager{
 		daoProvider:    daoProvider,
 		clientsManager: clientsManager,
 		storage:        storage,
+		tm:             tm,
+		condService:    condService,
 	}
 	go func() {
 		var slots []int
@@ -34,7 +41,7 @@ func NewPlayerManager(daoProvider *dao.DaoProvider, clientsManager *wsmethods.Cl
 			log.Printf("[DEBUG] Read cleanup: %s", enabled)
 			if err != nil {
 				log.Println("[ERROR] Can't load config 'do-cleanup-inventory")
-			} else if enabled == "true" {
+			} else if enabled == "true" && pm.cleanupAllowed() {
 				log.Println("[INFO] Do cleanup player iventory")
 				pm.RemoveItems(slots)
 			}
@@ -43,6 +50,24 @@ func NewPlayerManager(daoProvider *dao.DaoProvider, clientsManager *wsmethods.Cl
 	return pm
 }
 
+// cleanupAllowed checks optional condition of inventory cleanup
+func (s *PlayerManager) cleanupAllowed() bool {
+	condition, err := s.daoProvider.Configs.GetConfig("cleanup-inventory-condition")
+	if err != nil {
+		log.Printf("[ERROR] Can't load config 'cleanup-inventory-condition': %v", err)
+		return false
+	}
+	if condition == "" {
+		return true
+	}
+	allowed, err := s.condService.Eval(condition)
+	if err != nil {
+		log.Printf("[WARN] Can't evaluate cleanup condition '%s': %v", condition, err)
+		return false
+	}
+	return allowed
+}
+
 func (s *PlayerManager) GetItems() (map[int]*crafter.Stack, error) {
 	playerClient, err := wsmethods.GetClientForType[*wsmethods.PlayerClient](s.clientsManager)
 	if err != nil {
@@ -113,7 +138,8 @@ func (s *PlayerManager) RemoveItems(slots []int) error {
 	return s.storage.ImportAll(bufferName)
 }
 
-func (s *PlayerManager) SendItems(items []*crafter.Stack) error {
+// SendItems moves items to player inventory, storage calls are interactive
+func (s *PlayerManager) SendItems(ctx context.Context, items []*crafter.Stack) error {
 	playerClient, err := wsmethods.GetClientForType[*wsmethods.PlayerClient](s.clientsManager)
 	if err != nil {
 		return err
@@ -130,20 +156,37 @@ func (s *PlayerManager) SendItems(items []*crafter.Stack) error {
 		return err
 	}
 
+	req := storage.ExportRequest{
+		AllowPartial: true,
+	}
 	slot := 1
 	var targetSlots []int
 	for _, item := range items {
+		maxSize, err := s.storage.GetMaxStackSize(item.ItemID)
+		if err != nil {
+			return err
+		}
 		for item.Count > 0 {
-			toTransfer := min(item.Count, 64)
+			toTransfer := min(item.Count, maxSize)
 			item.Count -= toTransfer
-			_, err := s.storage.ExportStack(item.ItemID, bufferName, slot, toTransfer)
-			if err != nil {
-				return err
-			}
+			req.RequestItems = append(req.RequestItems, storage.ExportRequestItems{
+				TargetStorage: bufferName,
+				Uid:           item.ItemID,
+				ToSlot:        slot,
+				Amount:        toTransfer,
+			})
 			targetSlots = append(targetSlots, slot+17)
 			slot += 1
 		}
 	}
+	if len(req.RequestItems) == 0 {
+		return nil
+	}
+
+	_, err = s.tm.Transfer(wsrpc.WithPriority(ctx, wsrpc.PRIORITY_INTERACTIVE), req, nil)
+	if err != nil {
+		return err
+	}
 
 	return playerClient.AddItems(targetSlots)
 }
@@ -0,0 +1,774 @@
+package recipe
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+// ImportSource is single JSON document of recipe book, Name is used for
+// datapack recipe names and in report
+type ImportSource struct {
+	Name string
+	Data []byte
+}
+
+type RecipeImportSkip struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// RecipeImportReport counts imported entries, Existing are entries skipped as
+// already imported
+type RecipeImportReport struct {
+	Items           int                `json:"items"`
+	Tags            int                `json:"tags"`
+	Recipes         int                `json:"recipes"`
+	ImportedRecipes int                `json:"importedRecipes"`
+	Existing        int                `json:"existing"`
+	Skipped         []RecipeImportSkip `json:"skipped"`
+}
+
+func (r *RecipeImportReport) skip(source string, format string, args ...any) {
+	r.Skipped = append(r.Skipped, RecipeImportSkip{
+		Source: source,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+var defaultRecipeTypes = map[string]string{
+	"minecraft:crafting_shaped":    "",
+	"minecraft:crafting_shapeless": "",
+	"minecraft:smelting":           "smelting",
+}
+
+// ReadImportSources returns JSON documents of file, zip archives (datapacks)
+// are expanded to contained JSON files
+func ReadImportSources(name string, data []byte) ([]ImportSource, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return []ImportSource{{Name: name, Data: data}}, nil
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var sources []ImportSource
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, ImportSource{Name: file.Name, Data: content})
+	}
+	return sources, nil
+}
+
+type recipeBookImport struct {
+	daos   *dao.DaoProvider
+	types  map[string]string
+	report *RecipeImportReport
+
+	knownItems map[string]bool
+	names      map[string]struct{}
+	signatures map[string]struct{}
+	layouts    map[string]*dao.RecipeLayout
+}
+
+// ImportRecipeBook imports items and recipes from items dumps, JEI/EMI recipe
+// dumps, datapack recipes and recipes maps. Import is incremental: existing
+// items, recipes with same name and same imported recipes are kept, entries
+// which can't be imported are listed in report
+func (m *RecipeManager) ImportRecipeBook(sources []ImportSource, types map[string]string) (*RecipeImportReport, error) {
+	imp := &recipeBookImport{
+		daos:       m.daoProvider,
+		types:      make(map[string]string),
+		report:     &RecipeImportReport{},
+		knownItems: make(map[string]bool),
+		names:      make(map[string]struct{}),
+	}
+	for k, v := range defaultRecipeTypes {
+		imp.types[k] = v
+	}
+	for k, v := range types {
+		imp.types[k] = v
+	}
+
+	names, err := m.daoProvider.Recipes.GetRecipeNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		imp.names[name] = struct{}{}
+	}
+	imp.signatures, err = m.daoProvider.ImporetedRecipes.GetRecipeSignatures()
+	if err != nil {
+		return nil, err
+	}
+	imp.layouts, err = m.daoProvider.RecipeTypes.GetRecipeLayouts()
+	if err != nil {
+		return nil, err
+	}
+
+	var recipeSources []ImportSource
+	var recipeDocs []any
+	// items go first, so recipes of the same import can use them
+	for _, source := range sources {
+		var doc any
+		err := json.Unmarshal(source.Data, &doc)
+		if err != nil {
+			imp.report.skip(source.Name, "invalid JSON: %v", err)
+			continue
+		}
+		if isItemsDump(doc) {
+			err = imp.importItems(source.Name, doc.([]any))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		recipeSources = append(recipeSources, source)
+		recipeDocs = append(recipeDocs, doc)
+	}
+
+	for i, source := range recipeSources {
+		var err error
+		switch doc := recipeDocs[i].(type) {
+		case []any:
+			err = imp.importRecipesDump(source)
+		case map[string]any:
+			if _, ok := doc["type"].(string); ok {
+				err = imp.importDatapackRecipe(source.Name, doc)
+			} else {
+				err = imp.importRecipesMap(source.Name, doc)
+			}
+		default:
+			imp.report.skip(source.Name, "unsupported format")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return imp.report, nil
+}
+
+func isItemsDump(doc any) bool {
+	entries, ok := doc.([]any)
+	if !ok || len(entries) == 0 {
+		return false
+	}
+	entry, ok := entries[0].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, hasName := entry["displayName"]
+	_, hasRecipes := entry["recipes"]
+	return hasName && !hasRecipes
+}
+
+func (imp *recipeBookImport) isKnownItem(uid string) (bool, error) {
+	if common.IsFluid(uid) {
+		return true, nil
+	}
+	known, e := imp.knownItems[uid]
+	if e {
+		return known, nil
+	}
+	items, err := imp.daos.Items.FindItemsByUids([]string{uid})
+	if err != nil {
+		return false, err
+	}
+	imp.knownItems[uid] = len(items) > 0
+	return len(items) > 0, nil
+}
+
+func (imp *recipeBookImport) importItems(source string, entries []any) error {
+	for i, entry := range entries {
+		data, ok := entry.(map[string]any)
+		if !ok {
+			imp.report.skip(source, "item %d: not an object", i)
+			continue
+		}
+
+		var item dao.Item
+		item.ID, _ = data["name"].(string)
+		if id, ok := data["id"].(string); ok {
+			item.ID = id
+		}
+		item.DisplayName, _ = data["displayName"].(string)
+		if item.ID == "" || item.DisplayName == "" {
+			imp.report.skip(source, "item %d: name and displayName are required", i)
+			continue
+		}
+		if nbt, ok := data["nbt"].(string); ok {
+			item.NBT = &nbt
+		}
+		if meta, ok := data["meta"].(float64); ok {
+			converted := int(meta)
+			item.Meta = &converted
+		}
+		if icon, ok := data["icon"].(string); ok {
+			decoded, err := base64.StdEncoding.DecodeString(icon)
+			if err != nil {
+				imp.report.skip(source, "item %s: invalid icon: %v", item.ID, err)
+				continue
+			}
+			item.Icon = decoded
+		}
+		item.UID = common.MakeUid(item.ID, item.NBT)
+
+		known, err := imp.isKnownItem(item.UID)
+		if err != nil {
+			return err
+		}
+		if known {
+			imp.report.Existing += 1
+		} else {
+			err = imp.daos.Items.InsertItems([]*dao.Item{&item})
+			if err != nil {
+				return err
+			}
+			imp.knownItems[item.UID] = true
+			imp.report.Items += 1
+		}
+
+		tags, _ := data["tags"].([]any)
+		for _, tag := range tags {
+			name, ok := tag.(string)
+			if !ok {
+				continue
+			}
+			inserted, err := imp.daos.ImporetedRecipes.InsertTagIfMissing(name, item.UID)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				imp.report.Tags += 1
+			}
+		}
+	}
+	return nil
+}
+
+type dumpIngredient struct {
+	Item  *string `json:"item"`
+	Tag   *string `json:"tag"`
+	Count *int    `json:"count"`
+	NBT   *string `json:"nbt"`
+}
+
+type dumpResult struct {
+	Item  string  `json:"item"`
+	Count *int    `json:"count"`
+	NBT   *string `json:"nbt"`
+}
+
+type dumpRecipe struct {
+	Ingredients []json.RawMessage `json:"ingredients"`
+	Result      json.RawMessage   `json:"result"`
+	Width       *int              `json:"w"`
+	Height      *int              `json:"h"`
+}
+
+type dumpRecipeType struct {
+	Title   string       `json:"title"`
+	Mod     string       `json:"mod"`
+	Recipes []dumpRecipe `json:"recipes"`
+}
+
+// unmarshalOneOrMany reads value which is either single object or list of
+// alternatives
+func unmarshalOneOrMany[T any](raw json.RawMessage) ([]T, error) {
+	var values []T
+	err := json.Unmarshal(raw, &values)
+	if err == nil {
+		return values, nil
+	}
+	var value T
+	err = json.Unmarshal(raw, &value)
+	if err != nil {
+		return nil, err
+	}
+	return []T{value}, nil
+}
+
+// importRecipesDump imports JEI/EMI recipe dump to recipe book, recipes are
+// configured as craftable recipes later
+func (imp *recipeBookImport) importRecipesDump(source ImportSource) error {
+	var data []dumpRecipeType
+	err := json.Unmarshal(source.Data, &data)
+	if err != nil {
+		imp.report.skip(source.Name, "invalid recipes dump: %v", err)
+		return nil
+	}
+
+	for _, rt := range data {
+		for i, r := range rt.Recipes {
+			entry := fmt.Sprintf("%s: %s recipe %d", source.Name, rt.Title, i+1)
+			recipe, reason := convertDumpRecipe(r)
+			if reason != "" {
+				imp.report.skip(entry, "%s", reason)
+				continue
+			}
+
+			signature := recipe.Signature()
+			if _, e := imp.signatures[signature]; e {
+				imp.report.Existing += 1
+				continue
+			}
+			err = imp.daos.ImporetedRecipes.InsertRecipe(*recipe)
+			if err != nil {
+				return err
+			}
+			imp.signatures[signature] = struct{}{}
+			imp.report.ImportedRecipes += 1
+		}
+	}
+	return nil
+}
+
+func convertDumpRecipe(r dumpRecipe) (*dao.ImportedRecipe, string) {
+	w := 3
+	if r.Width != nil {
+		w = *r.Width
+	}
+	h := 3
+	if r.Height != nil {
+		h = *r.Height
+	}
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Sprintf("invalid grid size %dx%d", w, h)
+	}
+
+	var ingredients []dao.ImportedIngredient
+	for slot, raw := range r.Ingredients {
+		ings, err := unmarshalOneOrMany[dumpIngredient](raw)
+		if err != nil {
+			return nil, fmt.Sprintf("invalid ingredient in slot %d: %v", slot+1, err)
+		}
+		row := slot / w
+		column := slot % w
+		if row >= h {
+			return nil, fmt.Sprintf("ingredient slot %d is out of %dx%d grid", slot+1, w, h)
+		}
+		if row >= dao.SHAPED_CRAFT_LAYOUT.Rows || column >= dao.SHAPED_CRAFT_LAYOUT.Columns {
+			return nil, fmt.Sprintf("ingredient slot %d exceeds crafting grid", slot+1)
+		}
+		for _, ing := range ings {
+			if ing.Item == nil && ing.Tag == nil {
+				return nil, fmt.Sprintf("ingredient in slot %d has no item or tag", slot+1)
+			}
+			ingredients = append(ingredients, dao.ImportedIngredient{
+				Slot:    dao.SHAPED_CRAFT_LAYOUT.GridSlot(row, column),
+				Item:    ing.Item,
+				ItemTag: ing.Tag,
+				Count:   ing.Count,
+				NBT:     ing.NBT,
+			})
+		}
+	}
+
+	results, err := unmarshalOneOrMany[dumpResult](r.Result)
+	if err != nil || len(results) == 0 || results[0].Item == "" {
+		return nil, "invalid result"
+	}
+
+	return &dao.ImportedRecipe{
+		ResultID:    results[0].Item,
+		ResultCount: results[0].Count,
+		ResultNBT:   results[0].NBT,
+		Ingredients: ingredients,
+	}, ""
+}
+
+// datapackRecipeName returns namespaced id of recipe file in datapack
+// (data/<namespace>/recipes/<path>.json), empty for other paths
+func datapackRecipeName(source string) string {
+	parts := strings.Split(path.Clean(strings.ReplaceAll(source, "\\", "/")), "/")
+	for i := len(parts) - 4; i >= 0; i-- {
+		if parts[i] == "data" && (parts[i+2] == "recipes" || parts[i+2] == "recipe") {
+			return parts[i+1] + ":" + strings.TrimSuffix(strings.Join(parts[i+3:], "/"), ".json")
+		}
+	}
+	return ""
+}
+
+func (imp *recipeBookImport) addRecipe(source string, recipe *dao.Recipe) error {
+	if _, e := imp.names[recipe.Name]; e {
+		imp.report.Existing += 1
+		return nil
+	}
+	for _, items := range [][]dao.RecipeItem{recipe.Results, recipe.Ingredients} {
+		for _, item := range items {
+			known, err := imp.isKnownItem(item.ItemUID)
+			if err != nil {
+				return err
+			}
+			if !known {
+				imp.report.skip(source, "unknown item '%s'", item.ItemUID)
+				return nil
+			}
+		}
+	}
+	err := imp.daos.Recipes.InsertRecipe(recipe)
+	if err != nil {
+		return err
+	}
+	imp.names[recipe.Name] = struct{}{}
+	imp.report.Recipes += 1
+	return nil
+}
+
+func (imp *recipeBookImport) importDatapackRecipe(source string, doc map[string]any) error {
+	datapackType := doc["type"].(string)
+	recipeType, e := imp.types[datapackType]
+	if !e {
+		imp.report.skip(source, "unsupported recipe type '%s'", datapackType)
+		return nil
+	}
+
+	var recipe *dao.Recipe
+	var reason string
+	if _, shaped := doc["pattern"]; shaped {
+		recipe, reason = imp.parseShaped(doc, recipeType)
+	} else {
+		recipe, reason = imp.parseGeneric(doc)
+		if reason == "" && recipeType == "" {
+			spreadToGridSlots(recipe)
+		}
+	}
+	if reason == "" && len(recipe.Results) == 0 {
+		reason = "no results"
+	}
+	if reason == "" && len(recipe.Ingredients) == 0 {
+		reason = "no ingredients"
+	}
+	if layout, e := imp.layouts[recipeType]; e && reason == "" {
+		_, _, err := layout.AssignSlots(recipe)
+		if err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		imp.report.skip(source, "%s", reason)
+		return nil
+	}
+
+	recipe.Type = recipeType
+	recipe.Name = datapackRecipeName(source)
+	if recipe.Name == "" {
+		recipe.Name = recipe.Results[0].ItemUID
+	}
+	return imp.addRecipe(source, recipe)
+}
+
+func (imp *recipeBookImport) parseShaped(doc map[string]any, recipeType string) (*dao.Recipe, string) {
+	rawPattern, _ := doc["pattern"].([]any)
+	keys, _ := doc["key"].(map[string]any)
+	width := 0
+	var pattern []string
+	for _, row := range rawPattern {
+		line, ok := row.(string)
+		if !ok {
+			return nil, "invalid pattern"
+		}
+		pattern = append(pattern, line)
+		width = max(width, len(line))
+	}
+	if len(pattern) == 0 {
+		return nil, "empty pattern"
+	}
+	// slots are numbered by grid of type layout, types without grid keep
+	// pattern width
+	if layout, e := imp.layouts[recipeType]; e && layout.HasGrid() {
+		if width > layout.Columns || len(pattern) > layout.Rows {
+			return nil, fmt.Sprintf("pattern %dx%d exceeds %dx%d grid", width, len(pattern), layout.Columns, layout.Rows)
+		}
+		width = layout.Columns
+	}
+
+	recipe := &dao.Recipe{}
+	for row, line := range pattern {
+		for column, key := range line {
+			if key == ' ' {
+				continue
+			}
+			raw, e := keys[string(key)]
+			if !e {
+				return nil, fmt.Sprintf("pattern key '%c' is not defined", key)
+			}
+			uid, amount, reason := imp.parseIngredient(raw)
+			if reason != "" {
+				return nil, reason
+			}
+			slot := row*width + column + 1
+			recipe.Ingredients = append(recipe.Ingredients, dao.RecipeItem{
+				ItemUID: uid,
+				Amount:  amount,
+				Role:    dao.INGREDIENT_ROLE,
+				Slot:    &slot,
+			})
+		}
+	}
+
+	result, reason := imp.parseResult(doc["result"])
+	if reason != "" {
+		return nil, reason
+	}
+	recipe.Results = append(recipe.Results, *result)
+	return recipe, ""
+}
+
+// parseGeneric reads shapeless recipes and custom types with ingredient or
+// ingredients and result or results fields, same ingredients are merged
+func (imp *recipeBookImport) parseGeneric(doc map[string]any) (*dao.Recipe, string) {
+	var rawIngredients []any
+	if list, ok := doc["ingredients"].([]any); ok {
+		rawIngredients = list
+	} else if single, e := doc["ingredient"]; e {
+		rawIngredients = []any{single}
+	}
+
+	recipe := &dao.Recipe{}
+	amounts := make(map[string]int)
+	for _, raw := range rawIngredients {
+		uid, amount, reason := imp.parseIngredient(raw)
+		if reason != "" {
+			return nil, reason
+		}
+		if _, e := amounts[uid]; !e {
+			recipe.Ingredients = append(recipe.Ingredients, dao.RecipeItem{
+				ItemUID: uid,
+				Role:    dao.INGREDIENT_ROLE,
+			})
+		}
+		amounts[uid] += amount
+	}
+	for i := range recipe.Ingredients {
+		recipe.Ingredients[i].Amount = amounts[recipe.Ingredients[i].ItemUID]
+	}
+
+	var rawResults []any
+	if list, ok := doc["results"].([]any); ok {
+		rawResults = list
+	} else if single, e := doc["result"]; e {
+		rawResults = []any{single}
+	}
+	// chance is stored per result item, so only guaranteed results of same
+	// item can be merged
+	resultIdx := make(map[string]int)
+	for _, raw := range rawResults {
+		result, reason := imp.parseResult(raw)
+		if reason != "" {
+			return nil, reason
+		}
+		idx, e := resultIdx[result.ItemUID]
+		if !e {
+			resultIdx[result.ItemUID] = len(recipe.Results)
+			recipe.Results = append(recipe.Results, *result)
+			continue
+		}
+		if !result.IsGuaranteed() || !recipe.Results[idx].IsGuaranteed() {
+			return nil, fmt.Sprintf("result '%s' is listed twice with chance", result.ItemUID)
+		}
+		recipe.Results[idx].Amount += result.Amount
+	}
+	return recipe, ""
+}
+
+// spreadToGridSlots places each ingredient item of shapeless recipe to own
+// slot of crafting grid, as crafting table takes single item per slot
+func spreadToGridSlots(recipe *dao.Recipe) {
+	var ingredients []dao.RecipeItem
+	for _, ing := range recipe.Ingredients {
+		for range ing.Amount {
+			slot := len(ingredients) + 1
+			item := ing
+			item.Amount = 1
+			item.Slot = &slot
+			ingredients = append(ingredients, item)
+		}
+	}
+	recipe.Ingredients = ingredients
+}
+
+func readCount(data map[string]any, keys ...string) int {
+	for _, key := range keys {
+		if count, ok := data[key].(float64); ok && count > 0 {
+			return int(count)
+		}
+	}
+	return 1
+}
+
+// parseIngredient reads item, tag or fluid ingredient, first resolvable
+// alternative is used, tag is resolved to first tagged item
+func (imp *recipeBookImport) parseIngredient(raw any) (string, int, string) {
+	switch value := raw.(type) {
+	case string:
+		if strings.HasPrefix(value, "#") {
+			return imp.resolveTag(strings.TrimPrefix(value, "#"), 1)
+		}
+		return value, 1, ""
+	case []any:
+		reason := "empty ingredient alternatives"
+		for _, alternative := range value {
+			var uid string
+			var amount int
+			uid, amount, reason = imp.parseIngredient(alternative)
+			if reason == "" {
+				return uid, amount, ""
+			}
+		}
+		return "", 0, reason
+	case map[string]any:
+		if item, ok := value["item"].(string); ok {
+			return item, readCount(value, "count"), ""
+		}
+		if tag, ok := value["tag"].(string); ok {
+			return imp.resolveTag(tag, readCount(value, "count"))
+		}
+		if fluid, ok := value["fluid"].(string); ok {
+			return "fluid:" + fluid, readCount(value, "amount"), ""
+		}
+	}
+	return "", 0, fmt.Sprintf("unsupported ingredient %v", raw)
+}
+
+func (imp *recipeBookImport) resolveTag(tag string, amount int) (string, int, string) {
+	uids, err := imp.daos.ImporetedRecipes.FindItemsByTag(tag)
+	if err != nil {
+		return "", 0, err.Error()
+	}
+	if len(uids) == 0 {
+		return "", 0, fmt.Sprintf("unresolved tag '%s'", tag)
+	}
+	sort.Strings(uids)
+	return uids[0], amount, ""
+}
+
+func (imp *recipeBookImport) parseResult(raw any) (*dao.RecipeItem, string) {
+	result := &dao.RecipeItem{
+		Role:   dao.RESULT_ROLE,
+		Amount: 1,
+	}
+	switch value := raw.(type) {
+	case string:
+		result.ItemUID = value
+	case map[string]any:
+		if item, ok := value["item"].(string); ok {
+			result.ItemUID = item
+			result.Amount = readCount(value, "count")
+		} else if id, ok := value["id"].(string); ok {
+			result.ItemUID = id
+			result.Amount = readCount(value, "count")
+		} else if fluid, ok := value["fluid"].(string); ok {
+			result.ItemUID = "fluid:" + fluid
+			result.Amount = readCount(value, "amount")
+		}
+		if chance, ok := value["chance"].(float64); ok && chance < 1 {
+			result.Chance = &chance
+		}
+	}
+	if result.ItemUID == "" {
+		return nil, fmt.Sprintf("unsupported result %v", raw)
+	}
+	return result, ""
+}
+
+// importRecipesMap imports recipes map of result to inputs and outputs, recipe
+// without type is shaped craft with ingredients listed by slot
+func (imp *recipeBookImport) importRecipesMap(source string, doc map[string]any) error {
+	for name, raw := range doc {
+		entry := fmt.Sprintf("%s: %s", source, name)
+		data, ok := raw.(map[string]any)
+		if !ok {
+			imp.report.skip(entry, "unsupported format")
+			continue
+		}
+		recipeType, _ := data["type"].(string)
+		recipe := &dao.Recipe{
+			Name: name,
+			Type: recipeType,
+		}
+
+		outputs, _ := data["output"].([]any)
+		inputs, _ := data["input"].([]any)
+		reason := ""
+		for _, output := range outputs {
+			item, r := toMapRecipeItem(output)
+			if r != "" {
+				reason = r
+				break
+			}
+			if item != nil {
+				item.Role = dao.RESULT_ROLE
+				recipe.Results = append(recipe.Results, *item)
+			}
+		}
+		for i, input := range inputs {
+			if reason != "" {
+				break
+			}
+			item, r := toMapRecipeItem(input)
+			if r != "" {
+				reason = r
+				break
+			}
+			if item == nil {
+				continue
+			}
+			item.Role = dao.INGREDIENT_ROLE
+			if recipeType == "" {
+				slot := i + 1
+				item.Slot = &slot
+			}
+			recipe.Ingredients = append(recipe.Ingredients, *item)
+		}
+		if reason == "" && (len(recipe.Results) == 0 || len(recipe.Ingredients) == 0) {
+			reason = "recipe should have input and output"
+		}
+		if reason != "" {
+			imp.report.skip(entry, "%s", reason)
+			continue
+		}
+
+		err := imp.addRecipe(entry, recipe)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toMapRecipeItem(item any) (*dao.RecipeItem, string) {
+	switch value := item.(type) {
+	case nil:
+		return nil, ""
+	case string:
+		return &dao.RecipeItem{ItemUID: value, Amount: 1}, ""
+	case map[string]any:
+		uid, ok := value["item"].(string)
+		if ok {
+			return &dao.RecipeItem{ItemUID: uid, Amount: readCount(value, "count")}, ""
+		}
+	}
+	return nil, fmt.Sprintf("invalid item %v", item)
+}
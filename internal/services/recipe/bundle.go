@@ -0,0 +1,480 @@
+package recipe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+const RECIPE_BUNDLE_VERSION = 1
+
+// conflict modes for recipes with same name and different content
+const RECIPE_CONFLICT_SKIP = "skip"
+const RECIPE_CONFLICT_OVERWRITE = "overwrite"
+const RECIPE_CONFLICT_RENAME = "rename"
+
+const RECIPE_CHANGE_ADD = "add"
+const RECIPE_CHANGE_UPDATE = "update"
+const RECIPE_CHANGE_RENAME = "rename"
+const RECIPE_CHANGE_SKIP = "skip"
+const RECIPE_CHANGE_KEEP = "keep"
+
+// RecipeBundle is portable set of recipes with items they reference and used
+// recipe types, workers of recipe types are not included
+type RecipeBundle struct {
+	Version     int            `json:"version"`
+	RecipeTypes []string       `json:"recipeTypes"`
+	Items       []BundleItem   `json:"items"`
+	Recipes     []BundleRecipe `json:"recipes"`
+}
+
+type BundleItem struct {
+	UID         string  `json:"uid"`
+	ID          string  `json:"id"`
+	DisplayName string  `json:"displayName"`
+	NBT         *string `json:"nbt,omitempty"`
+	Meta        *int    `json:"meta,omitempty"`
+	Icon        []byte  `json:"icon,omitempty"`
+}
+
+type BundleRecipe struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	MaxRepeats  *int               `json:"maxRepeats,omitempty"`
+	Results     []BundleRecipeItem `json:"results"`
+	Ingredients []BundleRecipeItem `json:"ingredients"`
+	Catalysts   []BundleRecipeItem `json:"catalysts,omitempty"`
+}
+
+type BundleRecipeItem struct {
+	Item   string   `json:"item"`
+	Amount int      `json:"amount"`
+	Slot   *int     `json:"slot,omitempty"`
+	Chance *float64 `json:"chance,omitempty"`
+}
+
+// RecipeBundleFilter selects exported recipes, nil Type matches any type
+type RecipeBundleFilter struct {
+	Type *string
+	Name string
+}
+
+type RecipeChange struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName,omitempty"`
+	Action  string `json:"action"`
+}
+
+// RecipeBundleImportPlan describes changes which import of bundle makes,
+// Items and RecipeTypes are missing ones added by import
+type RecipeBundleImportPlan struct {
+	Bundle      *RecipeBundle  `json:"-"`
+	Conflict    string         `json:"conflict"`
+	Changes     []RecipeChange `json:"changes"`
+	Items       []string       `json:"items"`
+	RecipeTypes []string       `json:"recipeTypes"`
+	Errors      []string       `json:"errors"`
+}
+
+func toBundleItems(items []dao.RecipeItem) []BundleRecipeItem {
+	var result []BundleRecipeItem
+	for _, item := range items {
+		result = append(result, BundleRecipeItem{
+			Item:   item.ItemUID,
+			Amount: item.Amount,
+			Slot:   item.Slot,
+			Chance: item.Chance,
+		})
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		slotA, slotB := 0, 0
+		if result[a].Slot != nil {
+			slotA = *result[a].Slot
+		}
+		if result[b].Slot != nil {
+			slotB = *result[b].Slot
+		}
+		if slotA != slotB {
+			return slotA < slotB
+		}
+		return result[a].Item < result[b].Item
+	})
+	return result
+}
+
+func toBundleRecipe(recipe *dao.Recipe) BundleRecipe {
+	return BundleRecipe{
+		Name:        recipe.Name,
+		Type:        recipe.Type,
+		MaxRepeats:  recipe.MaxRepeats,
+		Results:     toBundleItems(recipe.Results),
+		Ingredients: toBundleItems(recipe.Ingredients),
+		Catalysts:   toBundleItems(recipe.Catalysts),
+	}
+}
+
+func fromBundleItems(items []BundleRecipeItem, role string) []dao.RecipeItem {
+	var result []dao.RecipeItem
+	for _, item := range items {
+		result = append(result, dao.RecipeItem{
+			ItemUID: item.Item,
+			Amount:  item.Amount,
+			Role:    role,
+			Slot:    item.Slot,
+			Chance:  item.Chance,
+		})
+	}
+	return result
+}
+
+func fromBundleRecipe(recipe *BundleRecipe) *dao.Recipe {
+	return &dao.Recipe{
+		Name:        recipe.Name,
+		Type:        recipe.Type,
+		MaxRepeats:  recipe.MaxRepeats,
+		Results:     fromBundleItems(recipe.Results, dao.RESULT_ROLE),
+		Ingredients: fromBundleItems(recipe.Ingredients, dao.INGREDIENT_ROLE),
+		Catalysts:   fromBundleItems(recipe.Catalysts, dao.CATALYST_ROLE),
+	}
+}
+
+func (r *BundleRecipe) uids() []string {
+	var uids []string
+	for _, items := range [][]BundleRecipeItem{r.Results, r.Ingredients, r.Catalysts} {
+		for _, item := range items {
+			uids = append(uids, item.Item)
+		}
+	}
+	return uids
+}
+
+// ExportRecipeBundle returns recipes matched by filter with referenced items
+// and recipe types
+func (m *RecipeManager) ExportRecipeBundle(filter RecipeBundleFilter) (*RecipeBundle, error) {
+	recipes, err := m.daoProvider.Recipes.FindRecipes(filter.Type, filter.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &RecipeBundle{
+		Version:     RECIPE_BUNDLE_VERSION,
+		RecipeTypes: []string{},
+		Items:       []BundleItem{},
+		Recipes:     []BundleRecipe{},
+	}
+	uids := make(map[string]struct{})
+	types := make(map[string]struct{})
+	for _, recipe := range recipes {
+		bundleRecipe := toBundleRecipe(recipe)
+		bundle.Recipes = append(bundle.Recipes, bundleRecipe)
+		for _, uid := range bundleRecipe.uids() {
+			uids[uid] = struct{}{}
+		}
+		if recipe.Type != "" {
+			types[recipe.Type] = struct{}{}
+		}
+	}
+	sort.SliceStable(bundle.Recipes, func(a, b int) bool {
+		return bundle.Recipes[a].Name < bundle.Recipes[b].Name
+	})
+
+	if filter.Type == nil && filter.Name == "" {
+		recipeTypes, err := m.daoProvider.RecipeTypes.GetRecipeTypes()
+		if err != nil {
+			return nil, err
+		}
+		for _, rt := range recipeTypes {
+			types[rt.Name] = struct{}{}
+		}
+	}
+	bundle.RecipeTypes = append(bundle.RecipeTypes, common.MapKeys(types)...)
+	sort.Strings(bundle.RecipeTypes)
+
+	items, err := m.daoProvider.Items.FindItemsByUids(common.MapKeys(uids))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		bundle.Items = append(bundle.Items, BundleItem{
+			UID:         item.UID,
+			ID:          item.ID,
+			DisplayName: item.DisplayName,
+			NBT:         item.NBT,
+			Meta:        item.Meta,
+			Icon:        item.Icon,
+		})
+	}
+	sort.Slice(bundle.Items, func(a, b int) bool {
+		return bundle.Items[a].UID < bundle.Items[b].UID
+	})
+
+	return bundle, nil
+}
+
+func parseRecipeBundle(data []byte) (*RecipeBundle, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var bundle RecipeBundle
+	err := decoder.Decode(&bundle)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Version != RECIPE_BUNDLE_VERSION {
+		return nil, fmt.Errorf("unsupported version %d", bundle.Version)
+	}
+	return &bundle, nil
+}
+
+// recipeKey returns content of recipe without name, items are normalized as
+// stored ones
+func recipeKey(recipe BundleRecipe) string {
+	normalized := toBundleRecipe(fromBundleRecipe(&recipe))
+	normalized.Name = ""
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// PlanRecipeBundleImport parses and validates bundle and compares its recipes
+// with current recipes of the same name
+func (m *RecipeManager) PlanRecipeBundleImport(data []byte, conflict string) (*RecipeBundleImportPlan, error) {
+	if conflict == "" {
+		conflict = RECIPE_CONFLICT_SKIP
+	}
+	if !slices.Contains([]string{RECIPE_CONFLICT_SKIP, RECIPE_CONFLICT_OVERWRITE, RECIPE_CONFLICT_RENAME}, conflict) {
+		return nil, fmt.Errorf("unknown conflict mode: %s", conflict)
+	}
+	bundle, err := parseRecipeBundle(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipe bundle: %w", err)
+	}
+
+	plan := &RecipeBundleImportPlan{
+		Bundle:   bundle,
+		Conflict: conflict,
+	}
+
+	bundleItems := make(map[string]struct{})
+	var itemUids []string
+	for _, item := range bundle.Items {
+		if common.MakeUid(item.ID, item.NBT) != item.UID {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("item '%s': uid does not match id and nbt", item.UID))
+		}
+		bundleItems[item.UID] = struct{}{}
+		itemUids = append(itemUids, item.UID)
+	}
+
+	var names []string
+	for _, recipe := range bundle.Recipes {
+		names = append(names, recipe.Name)
+		itemUids = append(itemUids, recipe.uids()...)
+	}
+	existingItems, err := m.daoProvider.Items.FindItemsByUids(itemUids)
+	if err != nil {
+		return nil, err
+	}
+	knownItems := make(map[string]struct{})
+	for _, item := range existingItems {
+		knownItems[item.UID] = struct{}{}
+	}
+	for _, item := range bundle.Items {
+		if _, e := knownItems[item.UID]; !e {
+			plan.Items = append(plan.Items, item.UID)
+		}
+	}
+
+	recipeTypes, err := m.daoProvider.RecipeTypes.GetRecipeTypes()
+	if err != nil {
+		return nil, err
+	}
+	knownTypes := make(map[string]struct{})
+	layouts := map[string]*dao.RecipeLayout{"": &dao.SHAPED_CRAFT_LAYOUT}
+	for _, rt := range recipeTypes {
+		knownTypes[rt.Name] = struct{}{}
+		if rt.Layout != nil {
+			layouts[rt.Name] = rt.Layout
+		}
+	}
+	for _, name := range bundle.RecipeTypes {
+		if _, e := knownTypes[name]; !e && name != "" {
+			knownTypes[name] = struct{}{}
+			plan.RecipeTypes = append(plan.RecipeTypes, name)
+		}
+	}
+
+	current, err := m.daoProvider.Recipes.GetRecipesByNames(names)
+	if err != nil {
+		return nil, err
+	}
+	currentByName := make(map[string]*dao.Recipe)
+	for _, recipe := range current {
+		if _, e := currentByName[recipe.Name]; !e {
+			currentByName[recipe.Name] = recipe
+		}
+	}
+	usedNames, err := m.daoProvider.Recipes.GetRecipeNames()
+	if err != nil {
+		return nil, err
+	}
+	usedNames = append(usedNames, names...)
+
+	imported := make(map[string]struct{})
+	for _, recipe := range bundle.Recipes {
+		if _, e := imported[recipe.Name]; e {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("recipe '%s': duplicate name", recipe.Name))
+			continue
+		}
+		imported[recipe.Name] = struct{}{}
+
+		err := validateBundleRecipe(&recipe, knownItems, bundleItems, layouts[recipe.Type])
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("recipe '%s': %v", recipe.Name, err))
+			continue
+		}
+		if _, e := knownTypes[recipe.Type]; !e && recipe.Type != "" {
+			knownTypes[recipe.Type] = struct{}{}
+			plan.RecipeTypes = append(plan.RecipeTypes, recipe.Type)
+		}
+
+		prev, e := currentByName[recipe.Name]
+		change := RecipeChange{Name: recipe.Name}
+		switch {
+		case !e:
+			change.Action = RECIPE_CHANGE_ADD
+		case recipeKey(toBundleRecipe(prev)) == recipeKey(recipe):
+			change.Action = RECIPE_CHANGE_KEEP
+		case conflict == RECIPE_CONFLICT_OVERWRITE:
+			change.Action = RECIPE_CHANGE_UPDATE
+		case conflict == RECIPE_CONFLICT_RENAME:
+			change.Action = RECIPE_CHANGE_RENAME
+			change.NewName = uniqueName(recipe.Name, usedNames)
+			usedNames = append(usedNames, change.NewName)
+		default:
+			change.Action = RECIPE_CHANGE_SKIP
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	sort.SliceStable(plan.Changes, func(a, b int) bool {
+		return plan.Changes[a].Name < plan.Changes[b].Name
+	})
+
+	return plan, nil
+}
+
+func uniqueName(name string, used []string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		if !slices.Contains(used, candidate) {
+			return candidate
+		}
+	}
+}
+
+func validateBundleRecipe(recipe *BundleRecipe, knownItems map[string]struct{}, bundleItems map[string]struct{}, layout *dao.RecipeLayout) error {
+	if strings.TrimSpace(recipe.Name) == "" {
+		return fmt.Errorf("name can't be empty")
+	}
+	if len(recipe.Results) == 0 {
+		return fmt.Errorf("recipe has no results")
+	}
+	for _, uid := range recipe.uids() {
+		_, known := knownItems[uid]
+		_, inBundle := bundleItems[uid]
+		if !known && !inBundle && !common.IsFluid(uid) {
+			return fmt.Errorf("item '%s' is not defined", uid)
+		}
+	}
+	for _, items := range [][]BundleRecipeItem{recipe.Results, recipe.Ingredients, recipe.Catalysts} {
+		for _, item := range items {
+			if item.Amount <= 0 {
+				return fmt.Errorf("amount of '%s' should be positive", item.Item)
+			}
+		}
+	}
+	for _, item := range append(slices.Clone(recipe.Ingredients), recipe.Catalysts...) {
+		if item.Chance != nil {
+			return fmt.Errorf("chance can be set only for results, got %s", item.Item)
+		}
+	}
+	_, _, err := layout.AssignSlots(fromBundleRecipe(recipe))
+	return err
+}
+
+// ApplyRecipeBundleImport adds missing items and recipe types and saves
+// planned recipe changes
+func (m *RecipeManager) ApplyRecipeBundleImport(plan *RecipeBundleImportPlan) error {
+	if len(plan.Errors) > 0 {
+		return fmt.Errorf("invalid recipe bundle: %s", strings.Join(plan.Errors, "; "))
+	}
+
+	var items []*dao.Item
+	for _, item := range plan.Bundle.Items {
+		if slices.Contains(plan.Items, item.UID) {
+			items = append(items, &dao.Item{
+				ID:          item.ID,
+				DisplayName: item.DisplayName,
+				NBT:         item.NBT,
+				Meta:        item.Meta,
+				Icon:        item.Icon,
+			})
+		}
+	}
+	if len(items) > 0 {
+		err := m.daoProvider.Items.InsertItems(items)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := m.daoProvider.RecipeTypes.EnsureRecipeTypes(plan.RecipeTypes)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[string]RecipeChange, len(plan.Changes))
+	var names []string
+	for _, change := range plan.Changes {
+		changes[change.Name] = change
+		if change.Action == RECIPE_CHANGE_UPDATE {
+			names = append(names, change.Name)
+		}
+	}
+	current, err := m.daoProvider.Recipes.GetRecipesByNames(names)
+	if err != nil {
+		return err
+	}
+	idByName := make(map[string]int)
+	for _, recipe := range current {
+		if _, e := idByName[recipe.Name]; !e {
+			idByName[recipe.Name] = recipe.ID
+		}
+	}
+
+	var inserts []*dao.Recipe
+	var updates []*dao.Recipe
+	for i := range plan.Bundle.Recipes {
+		change := changes[plan.Bundle.Recipes[i].Name]
+		recipe := fromBundleRecipe(&plan.Bundle.Recipes[i])
+		switch change.Action {
+		case RECIPE_CHANGE_ADD:
+			inserts = append(inserts, recipe)
+		case RECIPE_CHANGE_RENAME:
+			recipe.Name = change.NewName
+			inserts = append(inserts, recipe)
+		case RECIPE_CHANGE_UPDATE:
+			recipe.ID = idByName[recipe.Name]
+			updates = append(updates, recipe)
+		}
+	}
+
+	return m.daoProvider.Recipes.ApplyRecipes(inserts, updates)
+}
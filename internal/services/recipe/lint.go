@@ -0,0 +1,280 @@
+package recipe
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/common"
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+const LINT_ERROR = "error"
+const LINT_WARNING = "warning"
+
+type RecipeLintIssue struct {
+	RecipeID   int    `json:"recipeId"`
+	RecipeName string `json:"recipeName"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Link       string `json:"link"`
+}
+
+type recipeLint struct {
+	issues []RecipeLintIssue
+}
+
+func (l *recipeLint) add(recipe *dao.Recipe, severity string, format string, args ...any) {
+	l.issues = append(l.issues, RecipeLintIssue{
+		RecipeID:   recipe.ID,
+		RecipeName: recipe.Name,
+		Severity:   severity,
+		Message:    fmt.Sprintf(format, args...),
+		Link:       fmt.Sprintf("/recipes/%d/", recipe.ID),
+	})
+}
+
+// LintRecipes checks all recipes, errors make recipe unusable by planner or
+// crafters, warnings are likely mistakes
+func (m *RecipeManager) LintRecipes() ([]RecipeLintIssue, error) {
+	recipes, err := m.daoProvider.Recipes.FindRecipes(nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	uids := make(map[string]struct{})
+	for _, recipe := range recipes {
+		for _, items := range [][]dao.RecipeItem{recipe.Results, recipe.Ingredients, recipe.Catalysts} {
+			for _, item := range items {
+				uids[item.ItemUID] = struct{}{}
+			}
+		}
+	}
+	items, err := m.daoProvider.Items.FindItemsByUids(common.MapKeys(uids))
+	if err != nil {
+		return nil, err
+	}
+	knownItems := make(map[string]struct{})
+	for _, item := range items {
+		knownItems[item.UID] = struct{}{}
+	}
+
+	recipeTypes, err := m.daoProvider.RecipeTypes.GetRecipeTypes()
+	if err != nil {
+		return nil, err
+	}
+	workerByType := make(map[string]string)
+	layouts := map[string]*dao.RecipeLayout{"": &dao.SHAPED_CRAFT_LAYOUT}
+	for _, rt := range recipeTypes {
+		workerByType[rt.Name] = rt.WorkerID
+		if rt.Layout != nil {
+			layouts[rt.Name] = rt.Layout
+		}
+	}
+
+	lint := &recipeLint{}
+	for _, recipe := range recipes {
+		lintRecipe(lint, recipe, knownItems, workerByType, layouts[recipe.Type])
+	}
+	lintDuplicates(lint, recipes)
+	lintLoops(lint, recipes)
+
+	sort.SliceStable(lint.issues, func(a, b int) bool {
+		if lint.issues[a].Severity != lint.issues[b].Severity {
+			return lint.issues[a].Severity == LINT_ERROR
+		}
+		return lint.issues[a].RecipeID < lint.issues[b].RecipeID
+	})
+	return lint.issues, nil
+}
+
+func lintRecipe(lint *recipeLint, recipe *dao.Recipe, knownItems map[string]struct{}, workerByType map[string]string, layout *dao.RecipeLayout) {
+	if len(recipe.Results) == 0 {
+		lint.add(recipe, LINT_ERROR, "recipe has no results")
+	}
+	if len(recipe.Ingredients) == 0 {
+		lint.add(recipe, LINT_WARNING, "recipe has no ingredients")
+	}
+
+	for _, items := range [][]dao.RecipeItem{recipe.Results, recipe.Ingredients, recipe.Catalysts} {
+		for _, item := range items {
+			if item.Amount <= 0 {
+				lint.add(recipe, LINT_ERROR, "%s '%s' has amount %d", item.Role, item.ItemUID, item.Amount)
+			}
+			if _, e := knownItems[item.ItemUID]; !e && !common.IsFluid(item.ItemUID) {
+				lint.add(recipe, LINT_ERROR, "%s '%s' is unknown item", item.Role, item.ItemUID)
+			}
+			if item.Chance != nil && (*item.Chance <= 0 || *item.Chance > 1) {
+				lint.add(recipe, LINT_ERROR, "chance of '%s' should be in (0, 1]", item.ItemUID)
+			}
+		}
+	}
+
+	withSlot := 0
+	for _, ing := range recipe.Ingredients {
+		if ing.Slot != nil {
+			withSlot += 1
+		}
+	}
+	if withSlot > 0 && withSlot < len(recipe.Ingredients) {
+		lint.add(recipe, LINT_ERROR, "ingredients mix slotted and shapeless items")
+	}
+	if recipe.Type == "" && withSlot == 0 && len(recipe.Ingredients) > 0 {
+		lint.add(recipe, LINT_ERROR, "shaped recipe has no ingredient slots")
+	}
+	// slots are checked by layout of type, as workers do
+	_, _, err := layout.AssignSlots(recipe)
+	if err != nil {
+		lint.add(recipe, LINT_ERROR, "%v", err)
+	}
+
+	if recipe.Type != "" {
+		worker, e := workerByType[recipe.Type]
+		if !e {
+			lint.add(recipe, LINT_WARNING, "recipe type '%s' is not registered", recipe.Type)
+		} else if worker == "" {
+			lint.add(recipe, LINT_WARNING, "recipe type '%s' has no worker", recipe.Type)
+		}
+	}
+}
+
+// lintDuplicates reports recipes with same content as earlier recipe
+func lintDuplicates(lint *recipeLint, recipes []*dao.Recipe) {
+	byKey := make(map[string]*dao.Recipe)
+	byName := make(map[string]*dao.Recipe)
+	for _, recipe := range recipes {
+		key := recipeKey(toBundleRecipe(recipe))
+		if other, e := byKey[key]; e {
+			lint.add(recipe, LINT_WARNING, "duplicate of recipe '%s' (%d)", other.Name, other.ID)
+		} else {
+			byKey[key] = recipe
+		}
+		if other, e := byName[recipe.Name]; e {
+			lint.add(recipe, LINT_WARNING, "name is used by recipe %d", other.ID)
+		} else {
+			byName[recipe.Name] = recipe
+		}
+	}
+}
+
+// lintLoops reports recipes which ingredients depend on recipe result, planner
+// can't order such items. Dependencies are built as in planner: from first
+// result to ingredients and catalysts
+func lintLoops(lint *recipeLint, recipes []*dao.Recipe) {
+	deps := make(map[string][]string)
+	for _, recipe := range recipes {
+		if len(recipe.Results) == 0 {
+			continue
+		}
+		result := recipe.Results[0].ItemUID
+		for _, items := range [][]dao.RecipeItem{recipe.Ingredients, recipe.Catalysts} {
+			for _, item := range items {
+				if !slices.Contains(deps[result], item.ItemUID) {
+					deps[result] = append(deps[result], item.ItemUID)
+				}
+			}
+		}
+	}
+	for _, v := range deps {
+		sort.Strings(v)
+	}
+
+	component := stronglyConnected(deps)
+	for _, recipe := range recipes {
+		if len(recipe.Results) == 0 {
+			continue
+		}
+		result := recipe.Results[0].ItemUID
+		reported := make(map[string]struct{})
+		for _, items := range [][]dao.RecipeItem{recipe.Ingredients, recipe.Catalysts} {
+			for _, item := range items {
+				if item.ItemUID != result && component[item.ItemUID] != component[result] {
+					continue
+				}
+				if _, e := reported[item.ItemUID]; e {
+					continue
+				}
+				reported[item.ItemUID] = struct{}{}
+				path := findPath(deps, item.ItemUID, result)
+				lint.add(recipe, LINT_ERROR, "ingredient loop: %s -> %s", result, strings.Join(path, " -> "))
+			}
+		}
+	}
+}
+
+// stronglyConnected returns component index of each item in dependency graph
+func stronglyConnected(deps map[string][]string) map[string]int {
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	component := make(map[string]int)
+	var stack []string
+	counter := 0
+	components := 0
+
+	var visit func(item string)
+	visit = func(item string) {
+		index[item] = counter
+		low[item] = counter
+		counter += 1
+		stack = append(stack, item)
+		onStack[item] = true
+
+		for _, dep := range deps[item] {
+			if _, visited := index[dep]; !visited {
+				visit(dep)
+				low[item] = min(low[item], low[dep])
+			} else if onStack[dep] {
+				low[item] = min(low[item], index[dep])
+			}
+		}
+
+		if low[item] == index[item] {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component[top] = components
+				if top == item {
+					break
+				}
+			}
+			components += 1
+		}
+	}
+
+	items := common.MapKeys(deps)
+	sort.Strings(items)
+	for _, item := range items {
+		if _, visited := index[item]; !visited {
+			visit(item)
+		}
+	}
+	return component
+}
+
+// findPath returns shortest dependency path from item to target
+func findPath(deps map[string][]string, from string, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		if item == to {
+			var path []string
+			for ; item != ""; item = prev[item] {
+				path = append(path, item)
+			}
+			slices.Reverse(path)
+			return path
+		}
+		for _, dep := range deps[item] {
+			if _, e := prev[dep]; !e {
+				prev[dep] = item
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return []string{from}
+}
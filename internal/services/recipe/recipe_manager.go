@@ -25,6 +25,7 @@ type CreateRecipeItemParams struct {
 	Amount  int
 	Slot    *int
 	Role    string
+	Chance  *float64
 }
 
 type CreateRecipeParams struct {
@@ -36,7 +37,7 @@ type CreateRecipeParams struct {
 
 func ParseItemsParams(params url.Values) ([]CreateRecipeItemParams, error) {
 	items := make(map[string]map[string]string)
-	props := []string{"item", "slot", "amount", "role"}
+	props := []string{"item", "slot", "amount", "role", "chance"}
 outer:
 	for k, v := range params {
 		for _, prop := range props {
@@ -76,7 +77,7 @@ func parseCreateRecipeParams(params url.Values) (*CreateRecipeParams, error) {
 	recipeType := params.Get("type")
 
 	items := make(map[string]map[string]string)
-	props := []string{"item", "slot", "amount", "role"}
+	props := []string{"item", "slot", "amount", "role", "chance"}
 outer:
 	for k, v := range params {
 		for _, prop := range props {
@@ -157,6 +158,20 @@ func parseItemParams(item map[string]string) (CreateRecipeItemParams, error) {
 		return params, errors.New("Role must be scepcified")
 	}
 
+	chanceStr := strings.TrimSpace(item["chance"])
+	if chanceStr != "" {
+		chance, err := strconv.ParseFloat(chanceStr, 64)
+		if err != nil {
+			return params, fmt.Errorf("Invalid chance '%s'", chanceStr)
+		}
+		if chance <= 0 || chance > 1 {
+			return params, fmt.Errorf("Chance of '%s' must be in (0, 1]", itemUID)
+		}
+		if chance < 1 {
+			params.Chance = &chance
+		}
+	}
+
 	return params, nil
 }
 
@@ -211,6 +226,11 @@ func (m *RecipeManager) validateCreateParams(params *CreateRecipeParams) (*dao.R
 			Amount:  item.Amount,
 			Role:    item.Role,
 			Slot:    item.Slot,
+			Chance:  item.Chance,
+		}
+
+		if item.Chance != nil && item.Role != dao.RESULT_ROLE {
+			return nil, fmt.Errorf("Chance can be set only for results, got '%s' for %s", item.Role, item.ItemUID)
 		}
 
 		switch item.Role {
@@ -250,6 +270,15 @@ func (m *RecipeManager) validateCreateParams(params *CreateRecipeParams) (*dao.R
 		MaxRepeats:  params.MaxRepeats,
 	}
 
+	layout, err := m.daoProvider.RecipeTypes.GetRecipeLayout(recipe.Type)
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = layout.AssignSlots(&recipe)
+	if err != nil {
+		return nil, err
+	}
+
 	return &recipe, nil
 }
 
@@ -284,3 +313,45 @@ func (m *RecipeManager) UpdateRecipe(recipeId int, params *CreateRecipeParams) (
 	}
 	return recipe, nil
 }
+
+// ParseRecipeLayoutParams reads layout form, slots are lines of
+// "<slot> <name> [fluid]", empty form removes layout
+func ParseRecipeLayoutParams(values url.Values) (*dao.RecipeLayout, error) {
+	layout := &dao.RecipeLayout{}
+	var err error
+	if value := strings.TrimSpace(values.Get("columns")); value != "" {
+		layout.Columns, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid columns: %w", err)
+		}
+	}
+	if value := strings.TrimSpace(values.Get("rows")); value != "" {
+		layout.Rows, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rows: %w", err)
+		}
+	}
+	for _, line := range strings.Split(values.Get("slots"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		slot := dao.RecipeLayoutSlot{}
+		slot.Slot, err = strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid slot '%s': %w", fields[0], err)
+		}
+		fields = fields[1:]
+		if len(fields) > 0 && fields[len(fields)-1] == "fluid" {
+			slot.Fluid = true
+			fields = fields[:len(fields)-1]
+		}
+		slot.Name = strings.Join(fields, " ")
+		layout.Slots = append(layout.Slots, slot)
+	}
+
+	if layout.Columns == 0 && layout.Rows == 0 && len(layout.Slots) == 0 {
+		return nil, nil
+	}
+	return layout, layout.Validate()
+}
@@ -0,0 +1,94 @@
+package recipe
+
+type ItemUsageRecipe struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Amount of item used by one repeat
+	Amount   int  `json:"amount"`
+	Catalyst bool `json:"catalyst"`
+}
+
+type ItemUsageCraft struct {
+	ID       int    `json:"id"`
+	PlanID   int    `json:"planId"`
+	RecipeID int    `json:"recipeId"`
+	Status   string `json:"status"`
+	Repeats  int    `json:"repeats"`
+}
+
+type ItemUsagePlan struct {
+	PlanID         int    `json:"planId"`
+	Status         string `json:"status"`
+	Amount         int    `json:"amount"`
+	RequiredAmount int    `json:"requiredAmount"`
+}
+
+// ItemUsages describes what is affected when item is out of stock
+type ItemUsages struct {
+	UID     string            `json:"uid"`
+	Recipes []ItemUsageRecipe `json:"recipes"`
+	// Crafts are not completed crafts of recipes using item
+	Crafts []ItemUsageCraft `json:"crafts"`
+	// Plans are plans which still require item
+	Plans []ItemUsagePlan `json:"plans"`
+}
+
+func (m *RecipeManager) GetItemUsages(uid string) (*ItemUsages, error) {
+	recipes, err := m.daoProvider.Recipes.GetRecipesByUsages([]string{uid})
+	if err != nil {
+		return nil, err
+	}
+
+	usages := &ItemUsages{UID: uid}
+	var recipeIds []int
+	for _, recipe := range recipes {
+		recipeIds = append(recipeIds, recipe.ID)
+		usage := ItemUsageRecipe{
+			ID:   recipe.ID,
+			Name: recipe.Name,
+			Type: recipe.Type,
+		}
+		for _, ing := range recipe.Ingredients {
+			if ing.ItemUID == uid {
+				usage.Amount += ing.Amount
+			}
+		}
+		for _, catalyst := range recipe.Catalysts {
+			if catalyst.ItemUID == uid {
+				usage.Amount += catalyst.Amount
+				usage.Catalyst = true
+			}
+		}
+		usages.Recipes = append(usages.Recipes, usage)
+	}
+
+	crafts, err := m.daoProvider.Crafts.FindByRecipes(recipeIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, craft := range crafts {
+		usages.Crafts = append(usages.Crafts, ItemUsageCraft{
+			ID:       craft.ID,
+			PlanID:   craft.PlanID,
+			RecipeID: craft.RecipeID,
+			Status:   craft.Status,
+			Repeats:  craft.Repeats,
+		})
+	}
+
+	requirements, err := m.daoProvider.Plans.FindPlansRequiringItem(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range requirements {
+		usages.Plans = append(usages.Plans, ItemUsagePlan{
+			PlanID:         r.PlanID,
+			Status:         r.PlanStatus,
+			Amount:         r.Item.Amount,
+			RequiredAmount: r.Item.RequiredAmount,
+		})
+	}
+
+	return usages, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"log"
 	"strings"
 	"sync"
@@ -9,6 +10,8 @@ import (
 	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
+const DEFAULT_MAX_STACK_SIZE = 64
+
 type CombinedStore struct {
 	coldStorage  *SemiManagedStore
 	warmStorage  *MultipleChestsStore
@@ -102,16 +105,16 @@ func (s *CombinedStore) ImportStack(uid string, fromInventory string, fromSlot i
 	return movedCold, nil
 }
 
-func (s *CombinedStore) ExportStack(uid string, toInventory string, toSlot int, amount int) (int, error) {
+func (s *CombinedStore) ExportStack(ctx context.Context, uid string, toInventory string, toSlot int, amount int) (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	movedCold, err := s.coldStorage.ExportStack(uid, toInventory, toSlot, amount)
+	movedCold, err := s.coldStorage.ExportStack(ctx, uid, toInventory, toSlot, amount)
 	if err != nil {
 		return 0, err
 	}
 	if movedCold < amount {
-		movedWarm, err := s.warmStorage.ExportStack(uid, toInventory, toSlot, amount-movedCold)
+		movedWarm, err := s.warmStorage.ExportStack(ctx, uid, toInventory, toSlot, amount-movedCold)
 		if err != nil {
 			return 0, err
 		}
@@ -121,6 +124,22 @@ func (s *CombinedStore) ExportStack(uid string, toInventory string, toSlot int,
 	return movedCold, nil
 }
 
+// GetMaxStackSize returns max stack size of item, unknown items are assumed
+// to stack by DEFAULT_MAX_STACK_SIZE
+func (s *CombinedStore) GetMaxStackSize(uid string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size, found, err := s.warmStorage.GetMaxStackSize(uid, s.coldStorage.GetItemStacks(uid))
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return DEFAULT_MAX_STACK_SIZE, nil
+	}
+	return size, nil
+}
+
 func (s *CombinedStore) GetItemsCount() (map[string]int, error) {
 	count, err := s.coldStorage.GetItemsCount()
 	if err != nil {
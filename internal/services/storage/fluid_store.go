@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"sync"
 
 	"github.com/asek-ll/aecc-server/internal/common"
@@ -75,7 +76,7 @@ func (s *MultipleTanksStore) ImportFluid(uid string, fromContainer string, amoun
 
 	remain := amount
 	for container, count := range stacks {
-		moved, err := s.storageAdapter.MoveFluid(fromContainer, container, remain, uid)
+		moved, err := s.storageAdapter.MoveFluid(context.Background(), fromContainer, container, remain, uid)
 		if err != nil {
 			return 0, err
 		}
@@ -90,7 +91,7 @@ func (s *MultipleTanksStore) ImportFluid(uid string, fromContainer string, amoun
 	return amount - remain, nil
 }
 
-func (s *MultipleTanksStore) ExportFluid(uid string, toContainer string, amount int) (int, error) {
+func (s *MultipleTanksStore) ExportFluid(ctx context.Context, uid string, toContainer string, amount int) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -99,7 +100,7 @@ func (s *MultipleTanksStore) ExportFluid(uid string, toContainer string, amount
 	remain := amount
 	for container, count := range stacks {
 		for remain > 0 && stacks[container] > 0 {
-			moved, err := s.storageAdapter.MoveFluid(container, toContainer, remain, uid)
+			moved, err := s.storageAdapter.MoveFluid(ctx, container, toContainer, remain, uid)
 			if err != nil {
 				return 0, err
 			}
@@ -1,9 +1,16 @@
 package storage
 
+// ExportRequest is transfer from storage to targets, AllowPartial transfer
+// moves what is available and fits to targets, rest is returned to storage
 type ExportRequest struct {
 	RequestItems  []ExportRequestItems
 	RequestFluids []ExportRequestFluids
+	AllowPartial  bool
 }
+
+// ExportRequestItems is staged in slots by max stack size of item, ToSlot 0
+// means any slot of target, explicit ToSlot of full transfer should fit
+// requested Amount
 type ExportRequestItems struct {
 	TargetStorage string
 	Uid           string
@@ -17,11 +24,20 @@ type ExportRequestFluids struct {
 	Amount         int
 }
 
+const TRANSFER_PHASE_STAGING = "staging"
+const TRANSFER_PHASE_COMMITTED = "committed"
+
+// ExportTransactionData is stored transfer intent, empty Phase of
+// transactions stored by older versions means committed
 type ExportTransactionData struct {
-	ItemStacks  []ExportTransactionStorageSlot
-	FluidStacks []ExportTransactionTank
+	Phase        string
+	AllowPartial bool
+	ItemStacks   []ExportTransactionStorageSlot
+	FluidStacks  []ExportTransactionTank
 }
 
+// ExportTransactionStorageSlot is staging slot mapping, Uid and Amount are
+// expected content of staging slot and are verified before transfer
 type ExportTransactionStorageSlot struct {
 	StorageName   string
 	Slot          int
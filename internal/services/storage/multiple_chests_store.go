@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"sync"
 
 	"github.com/asek-ll/aecc-server/internal/common"
@@ -87,6 +88,30 @@ func (s *MultipleChestsStore) getMaxSize(UID string, inventory string, slot int)
 	return res.MaxCount, nil
 }
 
+// GetMaxStackSize returns max stack size of item, it is read from stored
+// stack or from one of extra slots, found is false for unknown item
+func (s *MultipleChestsStore) GetMaxStackSize(uid string, extra map[SlotRef]int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size, e := s.maxSizeByUID[uid]; e {
+		return size, true, nil
+	}
+	for _, stacks := range []map[SlotRef]int{s.stacksByUID[uid], extra} {
+		for ref, count := range stacks {
+			if count == 0 {
+				continue
+			}
+			size, err := s.getMaxSize(uid, ref.Inventory, ref.Slot)
+			if err != nil {
+				return 0, false, err
+			}
+			return size, size > 0, nil
+		}
+	}
+	return 0, false, nil
+}
+
 func (s *MultipleChestsStore) setStackSize(uid string, ref SlotRef, amount int) {
 	stacks, e := s.stacksByUID[uid]
 	if !e {
@@ -116,7 +141,7 @@ func (s *MultipleChestsStore) importToEmptySlot(uid string, fromInventory string
 		for i := 1; i <= inv.Size; i += 1 {
 			ref.Slot = i
 			if _, e := s.usedSlots[ref]; !e {
-				moved, err := s.storageAdapter.MoveStack(fromInventory, fromSlot, ref.Inventory, ref.Slot, amount)
+				moved, err := s.storageAdapter.MoveStack(context.Background(), fromInventory, fromSlot, ref.Inventory, ref.Slot, amount)
 				if err != nil {
 					return 0, err
 				}
@@ -148,7 +173,7 @@ func (s *MultipleChestsStore) ImportStack(uid string, fromInventory string, from
 	for ref, count := range stacks {
 		toTransfer := min(maxCount-count, remain)
 		if toTransfer > 0 {
-			moved, err := s.storageAdapter.MoveStack(fromInventory, fromSlot, ref.Inventory, ref.Slot, toTransfer)
+			moved, err := s.storageAdapter.MoveStack(context.Background(), fromInventory, fromSlot, ref.Inventory, ref.Slot, toTransfer)
 			if err != nil {
 				return 0, err
 			}
@@ -170,7 +195,7 @@ func (s *MultipleChestsStore) ImportStack(uid string, fromInventory string, from
 	return amount - remain, nil
 }
 
-func (s *MultipleChestsStore) ExportStack(uid string, toInventory string, toSlot int, amount int) (int, error) {
+func (s *MultipleChestsStore) ExportStack(ctx context.Context, uid string, toInventory string, toSlot int, amount int) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -180,7 +205,7 @@ func (s *MultipleChestsStore) ExportStack(uid string, toInventory string, toSlot
 	for ref, count := range stacks {
 		toTransfer := min(count, remain)
 		if toTransfer > 0 {
-			moved, err := s.storageAdapter.MoveStack(ref.Inventory, ref.Slot, toInventory, toSlot, toTransfer)
+			moved, err := s.storageAdapter.MoveStack(ctx, ref.Inventory, ref.Slot, toInventory, toSlot, toTransfer)
 			if err != nil {
 				return 0, err
 			}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"sync"
 
 	"github.com/asek-ll/aecc-server/internal/common"
@@ -44,7 +45,7 @@ func (s *SemiManagedStore) ImportStack(uid string, fromInventory string, fromSlo
 
 	remain := amount
 	for ref, count := range stacks {
-		moved, err := s.storageAdapter.MoveStack(fromInventory, fromSlot, ref.Inventory, ref.Slot, remain)
+		moved, err := s.storageAdapter.MoveStack(context.Background(), fromInventory, fromSlot, ref.Inventory, ref.Slot, remain)
 		if err != nil {
 			return 0, err
 		}
@@ -59,7 +60,7 @@ func (s *SemiManagedStore) ImportStack(uid string, fromInventory string, fromSlo
 	return amount - remain, nil
 }
 
-func (s *SemiManagedStore) ExportStack(uid string, toInventory string, toSlot int, amount int) (int, error) {
+func (s *SemiManagedStore) ExportStack(ctx context.Context, uid string, toInventory string, toSlot int, amount int) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -67,7 +68,7 @@ func (s *SemiManagedStore) ExportStack(uid string, toInventory string, toSlot in
 
 	remain := amount
 	for ref, count := range stacks {
-		moved, err := s.storageAdapter.MoveStack(ref.Inventory, ref.Slot, toInventory, toSlot, remain)
+		moved, err := s.storageAdapter.MoveStack(ctx, ref.Inventory, ref.Slot, toInventory, toSlot, remain)
 		if err != nil {
 			return 0, err
 		}
@@ -95,3 +96,10 @@ func (s *SemiManagedStore) GetStacks() (IndexedInventory, error) {
 
 	return common.CopyMap(s.StacksByUID), nil
 }
+
+func (s *SemiManagedStore) GetItemStacks(uid string) map[SlotRef]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return common.CopyMap(s.StacksByUID[uid])
+}
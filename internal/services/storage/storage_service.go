@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"log"
 	"sort"
@@ -14,7 +15,7 @@ import (
 type ItemStore interface {
 	ImportAll(fromInventory string) error
 	ImportStack(uid string, fromInventory string, fromSlot int, amount int) (int, error)
-	ExportStack(uid string, toInventory string, toSlot int, amount int) (int, error)
+	ExportStack(ctx context.Context, uid string, toInventory string, toSlot int, amount int) (int, error)
 	GetItemsCount() (map[string]int, error)
 }
 
@@ -128,8 +129,10 @@ func (s *Storage) Optimize() error {
 }
 
 type RichItemInfo struct {
-	Item            *dao.Item
-	Recipes         []*dao.Recipe
+	Item    *dao.Item
+	Recipes []*dao.Recipe
+	// Usages are recipes with item as ingredient or catalyst
+	Usages          []*dao.Recipe
 	ImportedRecipes []*dao.Recipe
 }
 
@@ -155,6 +158,11 @@ func (s *Storage) GetItem(uid string) (*RichItemInfo, error) {
 		return nil, err
 	}
 
+	usages, err := s.daoProvider.Recipes.GetRecipesByUsages([]string{uid})
+	if err != nil {
+		return nil, err
+	}
+
 	importedRecipes, err := s.daoProvider.ImporetedRecipes.FindRecipeByResult(uid)
 	if err != nil {
 		return nil, err
@@ -168,6 +176,7 @@ func (s *Storage) GetItem(uid string) (*RichItemInfo, error) {
 	return &RichItemInfo{
 		Item:            &items[0],
 		Recipes:         recipes,
+		Usages:          usages,
 		ImportedRecipes: importedRecipes,
 	}, nil
 }
@@ -176,16 +185,21 @@ func (s *Storage) ImportStack(uid string, fromInventory string, fromSlot int, am
 	return s.combinedStore.ImportStack(uid, fromInventory, fromSlot, amount)
 }
 
-func (s *Storage) ExportStack(uid string, toInventory string, toSlot int, amount int) (int, error) {
-	return s.combinedStore.ExportStack(uid, toInventory, toSlot, amount)
+// ExportStack moves items with priority of ctx
+func (s *Storage) ExportStack(ctx context.Context, uid string, toInventory string, toSlot int, amount int) (int, error) {
+	return s.combinedStore.ExportStack(ctx, uid, toInventory, toSlot, amount)
+}
+
+func (s *Storage) GetMaxStackSize(uid string) (int, error) {
+	return s.combinedStore.GetMaxStackSize(uid)
 }
 
 func (s *Storage) ImportFluid(uid string, fromInventory string, amount int) (int, error) {
 	return s.combinedStore.fluidStorage.ImportFluid(uid, fromInventory, amount)
 }
 
-func (s *Storage) ExportFluid(uid string, toInventory string, amount int) (int, error) {
-	return s.combinedStore.fluidStorage.ExportFluid(uid, toInventory, amount)
+func (s *Storage) ExportFluid(ctx context.Context, uid string, toInventory string, amount int) (int, error) {
+	return s.combinedStore.fluidStorage.ExportFluid(ctx, uid, toInventory, amount)
 }
 
 func (s *Storage) ImportAll(inventoryName string) error {
@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,11 +17,17 @@ import (
 	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
+// TransferTransaction is staged transfer, Commit commits DBTx and moves staged
+// items and fluids to targets, Rollback returns them to storage. Changes done
+// in DBTx are committed atomically with transfer intent
 type TransferTransaction struct {
 	DBTx     *sql.Tx
+	ctx      context.Context
 	stx      *dao.StoredTX
 	tm       *TransferTransactionManager
+	subjects []string
 	complete bool
+	moved    int
 }
 
 func (tx *TransferTransaction) Commit() error {
@@ -25,13 +35,20 @@ func (tx *TransferTransaction) Commit() error {
 		return nil
 	}
 	tx.complete = true
-	defer tx.tm.unlock()
+	defer tx.tm.unlockSubjects(tx.subjects)
 	err := tx.DBTx.Commit()
 	if err != nil {
+		tx.tm.abort(tx.stx)
 		return err
 	}
 
-	return tx.tm.processSTX(tx.stx)
+	tx.moved, err = tx.tm.performSTX(tx.ctx, tx.stx)
+	return err
+}
+
+// Moved returns items moved to targets by commit
+func (tx *TransferTransaction) Moved() int {
+	return tx.moved
 }
 
 func (tx *TransferTransaction) Rollback() error {
@@ -40,17 +57,26 @@ func (tx *TransferTransaction) Rollback() error {
 	}
 	tx.complete = true
 
-	defer tx.tm.unlock()
+	defer tx.tm.unlockSubjects(tx.subjects)
 
-	return tx.DBTx.Rollback()
+	err := tx.DBTx.Rollback()
+
+	return errors.Join(err, tx.tm.rollbackSTX(tx.stx))
 }
 
+// TransferTransactionManager stages transfers in inventories and tanks from
+// staging pool, each staging subject is locked by single transaction, so
+// transactions with different subjects run in parallel
 type TransferTransactionManager struct {
 	configLoader   *config.ConfigLoader
 	exportTxDao    *dao.StoredTXDao
 	storageAdapter *wsmethods.StorageAdapter
 	storage        *Storage
-	mu             sync.Mutex
+
+	mu       sync.Mutex
+	released *sync.Cond
+	locked   map[string]struct{}
+	sizes    map[string]int
 }
 
 func NewTransferTransactionManager(
@@ -64,7 +90,10 @@ func NewTransferTransactionManager(
 		exportTxDao:    exportTxDao,
 		storageAdapter: storageAdapter,
 		storage:        storage,
+		locked:         make(map[string]struct{}),
+		sizes:          make(map[string]int),
 	}
+	manager.released = sync.NewCond(&manager.mu)
 
 	go func() {
 		for {
@@ -85,7 +114,17 @@ func (tm *TransferTransactionManager) pingTransaction() error {
 		return err
 	}
 	for _, tx := range txs {
-		err := tm.processSTX(tx)
+		subjects, err := tm.exportTxDao.GetSubjects(tx.ID)
+		if err != nil {
+			return err
+		}
+		// locked subjects belong to running transaction, it completes
+		// transfer itself
+		if !tm.tryLockSubjects(subjects) {
+			continue
+		}
+		err = tm.processSTX(tx)
+		tm.unlockSubjects(subjects)
 		if err != nil {
 			return err
 		}
@@ -93,24 +132,220 @@ func (tm *TransferTransactionManager) pingTransaction() error {
 	return nil
 }
 
-func (tm *TransferTransactionManager) unlock() {
+func (tm *TransferTransactionManager) isFree(subjects []string) bool {
+	for _, subject := range subjects {
+		if _, e := tm.locked[subject]; e {
+			return false
+		}
+	}
+	return true
+}
+
+func (tm *TransferTransactionManager) tryLockSubjects(subjects []string) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if !tm.isFree(subjects) {
+		return false
+	}
+	for _, subject := range subjects {
+		tm.locked[subject] = struct{}{}
+	}
+	return true
+}
+
+func (tm *TransferTransactionManager) unlockSubjects(subjects []string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, subject := range subjects {
+		delete(tm.locked, subject)
+	}
+	tm.released.Broadcast()
+}
+
+// stagingSizes returns slot count of each connected staging inventory
+func (tm *TransferTransactionManager) stagingSizes(client *wsmethods.StorageClient) (map[string]int, error) {
+	tm.mu.Lock()
+	sizes := make(map[string]int)
+	var unknown []string
+	for _, name := range client.TransactionStorages {
+		if size, e := tm.sizes[name]; e {
+			sizes[name] = size
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
 	tm.mu.Unlock()
+
+	if len(unknown) == 0 {
+		return sizes, nil
+	}
+	inventories, err := tm.storageAdapter.GetItems(unknown)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, inventory := range inventories {
+		if slices.Contains(unknown, inventory.Name) && inventory.Size > 0 {
+			tm.sizes[inventory.Name] = inventory.Size
+			sizes[inventory.Name] = inventory.Size
+		}
+	}
+	return sizes, nil
+}
+
+// pickFree returns free staging inventories with enough slots for stacks and
+// free tank for each requested fluid
+func (tm *TransferTransactionManager) pickFree(client *wsmethods.StorageClient, sizes map[string]int, stacks int, fluids int) ([]string, []string, bool) {
+	var itemStores []string
+	capacity := 0
+	for _, name := range client.TransactionStorages {
+		if capacity >= stacks {
+			break
+		}
+		if sizes[name] > 0 && tm.isFree([]string{name}) {
+			itemStores = append(itemStores, name)
+			capacity += sizes[name]
+		}
+	}
+	if capacity < stacks {
+		return nil, nil, false
+	}
+	var tanks []string
+	for _, name := range client.TransactionTanks {
+		if len(tanks) == fluids {
+			break
+		}
+		if tm.isFree([]string{name}) {
+			tanks = append(tanks, name)
+		}
+	}
+	if len(tanks) < fluids {
+		return nil, nil, false
+	}
+	return itemStores, tanks, true
+}
+
+const stagingWaitTimeout = time.Second * 30
+
+// acquireStaging waits until staging subjects for request are free and locks
+// them, waiting is limited by ctx and stagingWaitTimeout
+func (tm *TransferTransactionManager) acquireStaging(ctx context.Context, client *wsmethods.StorageClient, sizes map[string]int, stacks int, fluids int) ([]string, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, stagingWaitTimeout)
+	defer cancel()
+	// wakes waiter on timeout or cancellation
+	stop := context.AfterFunc(ctx, func() {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+		tm.released.Broadcast()
+	})
+	defer stop()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for {
+		itemStores, tanks, ok := tm.pickFree(client, sizes, stacks, fluids)
+		if ok {
+			for _, subject := range append(slices.Clone(itemStores), tanks...) {
+				tm.locked[subject] = struct{}{}
+			}
+			return itemStores, tanks, nil
+		}
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("No free staging for %d stacks and %d fluids: %w", stacks, fluids, ctx.Err())
+		}
+		tm.released.Wait()
+	}
 }
 
+// GetLockedSubjects returns staging subjects of running transactions
+func (tm *TransferTransactionManager) GetLockedSubjects() []string {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	var result []string
+	for subject := range tm.locked {
+		result = append(result, subject)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// processSTX completes stored transaction after restart, staged but not
+// committed transfer is returned to storage, committed one is replayed
 func (tm *TransferTransactionManager) processSTX(tx *dao.StoredTX) error {
 	var data ExportTransactionData
 	err := json.Unmarshal(tx.Data, &data)
 	if err != nil {
 		return err
 	}
-	err = tm.performTransfer(&data)
+	if data.Phase == TRANSFER_PHASE_STAGING {
+		return tm.returnAndDrop(tx, &data)
+	}
+	_, err = tm.performAndDrop(context.Background(), tx, &data)
+	return err
+}
+
+// performSTX moves staged items and fluids to targets
+func (tm *TransferTransactionManager) performSTX(ctx context.Context, tx *dao.StoredTX) (int, error) {
+	var data ExportTransactionData
+	err := json.Unmarshal(tx.Data, &data)
+	if err != nil {
+		return 0, err
+	}
+	return tm.performAndDrop(ctx, tx, &data)
+}
+
+func (tm *TransferTransactionManager) performAndDrop(ctx context.Context, tx *dao.StoredTX, data *ExportTransactionData) (int, error) {
+	moved, err := tm.performTransfer(ctx, data)
+	if err != nil {
+		return moved, err
+	}
+	return moved, tm.exportTxDao.DropTransaction(tx)
+}
+
+func (tm *TransferTransactionManager) returnAndDrop(tx *dao.StoredTX, data *ExportTransactionData) error {
+	err := tm.returnStaged(data)
+	if err != nil {
+		return err
+	}
+	return tm.exportTxDao.DropTransaction(tx)
+}
+
+// rollbackSTX returns content of staging inventories and tanks to storage
+func (tm *TransferTransactionManager) rollbackSTX(tx *dao.StoredTX) error {
+	var data ExportTransactionData
+	err := json.Unmarshal(tx.Data, &data)
 	if err != nil {
 		return err
 	}
+	return tm.returnAndDrop(tx, &data)
+}
 
-	return tm.exportTxDao.DropTransaction(tx)
+func (tm *TransferTransactionManager) returnStaged(data *ExportTransactionData) error {
+	var storages []string
+	for _, stack := range data.ItemStacks {
+		if !slices.Contains(storages, stack.StorageName) {
+			storages = append(storages, stack.StorageName)
+		}
+	}
+	for _, storage := range storages {
+		err := tm.storage.ImportAll(storage)
+		if err != nil {
+			return err
+		}
+	}
+	for _, stack := range data.FluidStacks {
+		err := tm.storage.ImportAllFluids(stack.TankName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// restoreIfExists completes stored transactions of locked subjects, other
+// subjects of such transaction are locked during replay
 func (tm *TransferTransactionManager) restoreIfExists(subjects []string) error {
 	txs, err := tm.exportTxDao.FindLocks(subjects)
 	if err != nil {
@@ -121,7 +356,21 @@ func (tm *TransferTransactionManager) restoreIfExists(subjects []string) error {
 	}
 
 	for _, tx := range txs {
-		err := tm.processSTX(tx)
+		txSubjects, err := tm.exportTxDao.GetSubjects(tx.ID)
+		if err != nil {
+			return err
+		}
+		var other []string
+		for _, subject := range txSubjects {
+			if !slices.Contains(subjects, subject) {
+				other = append(other, subject)
+			}
+		}
+		if !tm.tryLockSubjects(other) {
+			return fmt.Errorf("stored transaction %d is used by other transaction", tx.ID)
+		}
+		err = tm.processSTX(tx)
+		tm.unlockSubjects(other)
 		if err != nil {
 			return err
 		}
@@ -130,56 +379,139 @@ func (tm *TransferTransactionManager) restoreIfExists(subjects []string) error {
 	return nil
 }
 
-func (tm *TransferTransactionManager) performTransfer(data *ExportTransactionData) error {
+// performTransfer moves staged stacks to targets and returns moved items,
+// leftovers of partial transfer are returned to storage
+func (tm *TransferTransactionManager) performTransfer(ctx context.Context, data *ExportTransactionData) (int, error) {
+	err := tm.verifyStaged(data)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
 	storages := make(map[string]struct{})
 	for _, stack := range data.ItemStacks {
-		_, err := tm.storageAdapter.MoveStack(stack.StorageName, stack.Slot, stack.TargetStorage, stack.ToSlot, stack.Amount)
+		storages[stack.StorageName] = struct{}{}
+		if stack.Amount == 0 {
+			continue
+		}
+		amount, err := tm.storageAdapter.MoveStack(ctx, stack.StorageName, stack.Slot, stack.TargetStorage, stack.ToSlot, stack.Amount)
 		if err != nil {
-			return err
+			return moved, err
 		}
-
-		storages[stack.StorageName] = struct{}{}
+		moved += amount
 	}
 	for storage := range storages {
 		items, err := tm.storageAdapter.ListItems(storage)
 		if err != nil {
-			return err
+			return moved, err
 		}
 		if len(items) > 0 {
-			return fmt.Errorf("Transaction non performed (item)")
+			if !data.AllowPartial {
+				return moved, fmt.Errorf("Transaction non performed (item)")
+			}
+			err = tm.storage.ImportAll(storage)
+			if err != nil {
+				return moved, err
+			}
 		}
 	}
 
 	tanks := make(map[string]struct{})
 	for _, stack := range data.FluidStacks {
-		_, err := tm.storageAdapter.MoveFluid(stack.TankName, stack.TargetTankName, stack.Amount, stack.Uid)
+		_, err := tm.storageAdapter.MoveFluid(ctx, stack.TankName, stack.TargetTankName, stack.Amount, stack.Uid)
 		if err != nil {
-			return err
+			return moved, err
 		}
 		tanks[stack.TankName] = struct{}{}
 	}
 	for tank := range tanks {
 		fluids, err := tm.storageAdapter.GetTanks(tank)
 		if err != nil {
-			return err
+			return moved, err
 		}
 		if len(fluids) > 0 {
-			return fmt.Errorf("Transaction non performed (fluid)")
+			if !data.AllowPartial {
+				return moved, fmt.Errorf("Transaction non performed (fluid)")
+			}
+			err = tm.storage.ImportAllFluids(tank)
+			if err != nil {
+				return moved, err
+			}
 		}
 	}
 
-	return nil
+	return moved, nil
 }
 
-func (tm *TransferTransactionManager) setupTransaction(itemStore string, fluidStores []string, request ExportRequest) (*TransferTransaction, error) {
+// verifyStaged checks staging slots before transfer, slot should contain
+// recorded item and not more than recorded amount. Amounts are updated to
+// actual ones, so replay of interrupted transfer moves only remaining items
+func (tm *TransferTransactionManager) verifyStaged(data *ExportTransactionData) error {
+	contents := make(map[string]map[int]wsmethods.Stack)
+	for i := range data.ItemStacks {
+		stack := &data.ItemStacks[i]
+		slots, e := contents[stack.StorageName]
+		if !e {
+			items, err := tm.storageAdapter.ListItems(stack.StorageName)
+			if err != nil {
+				return err
+			}
+			slots = make(map[int]wsmethods.Stack)
+			for _, item := range items {
+				slots[item.Slot] = item.Item
+			}
+			contents[stack.StorageName] = slots
+		}
 
-	var subjects []string
-	if len(request.RequestItems) > 0 {
-		subjects = append(subjects, itemStore)
+		actual, e := slots[stack.Slot]
+		if !e {
+			stack.Amount = 0
+			continue
+		}
+		// transactions stored by older versions have no uid
+		if stack.Uid != "" && actual.GetUID() != stack.Uid {
+			return fmt.Errorf("Staging slot %s:%d contains %s, expected %s", stack.StorageName, stack.Slot, actual.GetUID(), stack.Uid)
+		}
+		if actual.Count > stack.Amount {
+			return fmt.Errorf("Staging slot %s:%d contains %d items, expected %d", stack.StorageName, stack.Slot, actual.Count, stack.Amount)
+		}
+		stack.Amount = actual.Count
 	}
-	for i := range request.RequestFluids {
-		subjects = append(subjects, fluidStores[i])
+	return nil
+}
+
+// splitStacks splits requested items by max stack size, each part is staged
+// in own slot. Partial transfer to explicit slot moves what fits, rest is
+// returned to storage
+func (tm *TransferTransactionManager) splitStacks(request ExportRequest) ([]ExportRequestItems, error) {
+	var stacks []ExportRequestItems
+	for _, item := range request.RequestItems {
+		maxSize, err := tm.storage.GetMaxStackSize(item.Uid)
+		if err != nil {
+			return nil, err
+		}
+		if !request.AllowPartial && item.ToSlot > 0 && item.Amount > maxSize {
+			return nil, fmt.Errorf("Can't put %d of %s to slot %d, max stack size is %d", item.Amount, item.Uid, item.ToSlot, maxSize)
+		}
+		for remain := item.Amount; remain > 0; remain -= maxSize {
+			stack := item
+			stack.Amount = min(remain, maxSize)
+			stacks = append(stacks, stack)
+		}
 	}
+	return stacks, nil
+}
+
+func (tm *TransferTransactionManager) setupTransaction(
+	ctx context.Context,
+	itemStores []string,
+	sizes map[string]int,
+	fluidStores []string,
+	request ExportRequest,
+	stacks []ExportRequestItems,
+) (*TransferTransaction, error) {
+
+	subjects := append(slices.Clone(itemStores), fluidStores...)
 
 	log.Printf("Restore %v", request)
 	err := tm.restoreIfExists(subjects)
@@ -188,7 +520,7 @@ func (tm *TransferTransactionManager) setupTransaction(itemStore string, fluidSt
 	}
 
 	log.Printf("Dump items before %v", request)
-	if len(request.RequestItems) > 0 {
+	for _, itemStore := range itemStores {
 		err = tm.storage.ImportAll(itemStore)
 		if err != nil {
 			return nil, err
@@ -203,34 +535,26 @@ func (tm *TransferTransactionManager) setupTransaction(itemStore string, fluidSt
 		}
 	}
 	log.Printf("FORM transaction %v", request)
-	data := &ExportTransactionData{}
-	slot := 0
-	for _, item := range request.RequestItems {
+	data := &ExportTransactionData{
+		Phase:        TRANSFER_PHASE_STAGING,
+		AllowPartial: request.AllowPartial,
+	}
+	store, slot := 0, 0
+	for _, item := range stacks {
 		slot += 1
-		amount, err := tm.storage.ExportStack(item.Uid, itemStore, slot, item.Amount)
-		if amount != item.Amount {
-			return nil, fmt.Errorf("Can't move items for stx")
-		}
-		if err != nil {
-			return nil, err
+		if slot > sizes[itemStores[store]] {
+			store, slot = store+1, 1
 		}
 		data.ItemStacks = append(data.ItemStacks, ExportTransactionStorageSlot{
-			StorageName:   itemStore,
+			StorageName:   itemStores[store],
 			Slot:          slot,
 			TargetStorage: item.TargetStorage,
+			Uid:           item.Uid,
 			ToSlot:        item.ToSlot,
 			Amount:        item.Amount,
 		})
 	}
-
 	for i, fluid := range request.RequestFluids {
-		amount, err := tm.storage.ExportFluid(fluid.Uid, fluidStores[i], fluid.Amount)
-		if amount != fluid.Amount {
-			return nil, fmt.Errorf("Can't move fluid for stx, moved %d, but need %d", amount, fluid.Amount)
-		}
-		if err != nil {
-			return nil, err
-		}
 		data.FluidStacks = append(data.FluidStacks, ExportTransactionTank{
 			TankName:       fluidStores[i],
 			TargetTankName: fluid.TargetTankName,
@@ -243,54 +567,198 @@ func (tm *TransferTransactionManager) setupTransaction(itemStore string, fluidSt
 	if err != nil {
 		return nil, err
 	}
-
 	stx := &dao.StoredTX{
 		Data: jsonData,
 	}
 
-	dbTx, err := tm.exportTxDao.InsertTransactionUncommitted(subjects, stx)
+	// intent is recorded before staging, so interrupted staging is returned
+	// to storage on recovery
+	err = tm.exportTxDao.InsertTransaction(subjects, stx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tm.stage(ctx, data)
+	if err != nil {
+		tm.abort(stx)
+		return nil, err
+	}
+
+	committed := *data
+	committed.Phase = TRANSFER_PHASE_COMMITTED
+	committedData, err := json.Marshal(committed)
+	if err != nil {
+		tm.abort(stx)
+		return nil, err
+	}
+
+	dbTx, err := tm.exportTxDao.UpdateTransactionUncommitted(&dao.StoredTX{ID: stx.ID, Data: committedData})
 	if err != nil {
+		tm.abort(stx)
 		return nil, err
 	}
+	stx.Data = committedData
 
 	return &TransferTransaction{
 		DBTx:     dbTx,
+		ctx:      ctx,
 		stx:      stx,
 		tm:       tm,
+		subjects: subjects,
 		complete: false,
 	}, nil
 }
 
-func (tm *TransferTransactionManager) CreateExportTransaction(request ExportRequest) (*TransferTransaction, error) {
+// abort returns staged items, on failure transaction is left for recovery
+func (tm *TransferTransactionManager) abort(stx *dao.StoredTX) {
+	err := tm.rollbackSTX(stx)
+	if err != nil {
+		log.Printf("[ERROR] Can't return staged items of transaction %d: %v", stx.ID, err)
+	}
+}
+
+// stage exports requested items and fluids from storage to staging, partial
+// transfer keeps staged amounts
+func (tm *TransferTransactionManager) stage(ctx context.Context, data *ExportTransactionData) error {
+	for i := range data.ItemStacks {
+		stack := &data.ItemStacks[i]
+		amount, err := tm.storage.ExportStack(ctx, stack.Uid, stack.StorageName, stack.Slot, stack.Amount)
+		if err != nil {
+			return err
+		}
+		if amount != stack.Amount {
+			if !data.AllowPartial {
+				return fmt.Errorf("Can't move items for stx, moved %d of %s, but need %d", amount, stack.Uid, stack.Amount)
+			}
+			stack.Amount = amount
+		}
+	}
+
+	for i := range data.FluidStacks {
+		stack := &data.FluidStacks[i]
+		amount, err := tm.storage.ExportFluid(ctx, stack.Uid, stack.TankName, stack.Amount)
+		if err != nil {
+			return err
+		}
+		if amount != stack.Amount {
+			if !data.AllowPartial {
+				return fmt.Errorf("Can't move fluid for stx, moved %d, but need %d", amount, stack.Amount)
+			}
+			stack.Amount = amount
+		}
+	}
+	return nil
+}
+
+// CreateExportTransaction stages request, storage calls are done with
+// priority of ctx
+func (tm *TransferTransactionManager) CreateExportTransaction(ctx context.Context, request ExportRequest) (*TransferTransaction, error) {
 	if len(request.RequestItems) == 0 && len(request.RequestFluids) == 0 {
 		return nil, fmt.Errorf("Empty transaction")
 	}
-	if len(request.RequestItems) > 27 {
-		return nil, fmt.Errorf("Too huge transaction")
-	}
 	client, err := tm.storageAdapter.GetClient()
 	if err != nil {
 		return nil, err
 	}
-	if len(request.RequestItems) > 0 {
-		if client.TransactionStorage == "" {
-			return nil, fmt.Errorf("No transaction storage specified")
-		}
+	if len(request.RequestItems) > 0 && len(client.TransactionStorages) == 0 {
+		return nil, fmt.Errorf("No transaction storage specified")
 	}
 	if len(request.RequestFluids) > len(client.TransactionTanks) {
-		return nil, fmt.Errorf("No transaction storage specified")
+		return nil, fmt.Errorf("Not enough transaction tanks, need %d", len(request.RequestFluids))
 	}
-	for i := range request.RequestFluids {
-		if client.TransactionTanks[i] == "" {
-			return nil, fmt.Errorf("No transaction storage specified")
-		}
+
+	stacks, err := tm.splitStacks(request)
+	if err != nil {
+		return nil, err
+	}
+	sizes, err := tm.stagingSizes(client)
+	if err != nil {
+		return nil, err
+	}
+	capacity := 0
+	for _, size := range sizes {
+		capacity += size
+	}
+	if len(stacks) > capacity {
+		return nil, fmt.Errorf("Too huge transaction, need %d staging slots, but pool has %d", len(stacks), capacity)
 	}
 
-	tm.mu.Lock()
-	tx, err := tm.setupTransaction(client.TransactionStorage, client.TransactionTanks, request)
+	itemStores, tanks, err := tm.acquireStaging(ctx, client, sizes, len(stacks), len(request.RequestFluids))
 	if err != nil {
-		tm.mu.Unlock()
+		return nil, err
+	}
+	tx, err := tm.setupTransaction(ctx, itemStores, sizes, tanks, request, stacks)
+	if err != nil {
+		tm.unlockSubjects(append(itemStores, tanks...))
 		return nil, err
 	}
 	return tx, nil
 }
+
+// Transfer moves request in single transaction and returns moved items, apply
+// is called with db transaction of transfer to commit related changes
+// atomically with it
+func (tm *TransferTransactionManager) Transfer(ctx context.Context, request ExportRequest, apply func(tx *sql.Tx) error) (int, error) {
+	client, err := tm.storageAdapter.GetClient()
+	if err != nil {
+		return 0, err
+	}
+	if (len(request.RequestItems) > 0 && len(client.TransactionStorages) == 0) ||
+		(len(request.RequestFluids) > 0 && len(client.TransactionTanks) == 0) {
+		if !request.AllowPartial {
+			return 0, fmt.Errorf("Client %s has no staging pool, can't transfer without transaction", client.ID)
+		}
+		log.Printf("[WARN] Client %s has no staging pool, transfer is done directly without transaction", client.ID)
+		return tm.transferDirect(ctx, request, apply)
+	}
+
+	tx, err := tm.CreateExportTransaction(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if apply != nil {
+		err = apply(tx.DBTx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	return tx.Moved(), err
+}
+
+// transferDirect moves request straight from storage to targets, it is used
+// when client has no staging pool and request allows partial transfer.
+// Transfer is not atomic, apply is committed after items are moved
+func (tm *TransferTransactionManager) transferDirect(ctx context.Context, request ExportRequest, apply func(tx *sql.Tx) error) (int, error) {
+	moved := 0
+	for _, item := range request.RequestItems {
+		amount, err := tm.storage.ExportStack(ctx, item.Uid, item.TargetStorage, item.ToSlot, item.Amount)
+		if err != nil {
+			return moved, err
+		}
+		moved += amount
+	}
+	for _, fluid := range request.RequestFluids {
+		_, err := tm.storage.ExportFluid(ctx, fluid.Uid, fluid.TargetTankName, fluid.Amount)
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	if apply == nil {
+		return moved, nil
+	}
+	tx, err := tm.exportTxDao.Begin()
+	if err != nil {
+		return moved, err
+	}
+	defer tx.Rollback()
+	err = apply(tx)
+	if err != nil {
+		return moved, err
+	}
+	return moved, tx.Commit()
+}
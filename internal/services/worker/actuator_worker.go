@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
+	"github.com/asek-ll/aecc-server/internal/services/modem"
+)
+
+const defaultActuatorTimeout = 5 * time.Second
+
+type ActuatorWorker struct {
+	modemManager *modem.ModemManager
+	condService  *cond.CondService
+}
+
+func NewActuatorWorker(modemManager *modem.ModemManager, condService *cond.CondService) *ActuatorWorker {
+	return &ActuatorWorker{
+		modemManager: modemManager,
+		condService:  condService,
+	}
+}
+
+// do returns number of done steps
+func (w *ActuatorWorker) do(config *dao.ActuatorWorkerConfig) (int, error) {
+	if config.Condition != "" {
+		ready, err := w.condService.Eval(config.Condition)
+		if err != nil {
+			return 0, err
+		}
+		if !ready {
+			return 0, nil
+		}
+	}
+	err := w.runSteps(config.Steps)
+	if err != nil {
+		return 0, err
+	}
+	return len(config.Steps), nil
+}
+
+// runSteps runs steps in order, stops on first failed step
+func (w *ActuatorWorker) runSteps(steps []dao.ActuatorStep) error {
+	for i := range steps {
+		err := w.runStep(&steps[i])
+		if err != nil {
+			return fmt.Errorf("actuator step %d (%s): %w", i+1, steps[i].Action, err)
+		}
+	}
+	return nil
+}
+
+func stepTimeout(step *dao.ActuatorStep) time.Duration {
+	if step.TimeoutMs > 0 {
+		return time.Duration(step.TimeoutMs) * time.Millisecond
+	}
+	return defaultActuatorTimeout
+}
+
+func (w *ActuatorWorker) runStep(step *dao.ActuatorStep) error {
+	timeout := stepTimeout(step)
+	if step.Action == dao.ACTUATOR_ACTION_PULSE {
+		// pulse holds output, so its duration is not limited by timeout
+		timeout += time.Duration(step.DurationMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch step.Action {
+	case dao.ACTUATOR_ACTION_CALL:
+		_, err := w.modemManager.CallPeripheral(ctx, step.Peripheral, step.Method, step.Args)
+		if err != nil {
+			return err
+		}
+	case dao.ACTUATOR_ACTION_REDSTONE:
+		err := w.setRedstone(ctx, step, step.Power)
+		if err != nil {
+			return err
+		}
+	case dao.ACTUATOR_ACTION_PULSE:
+		err := w.setRedstone(ctx, step, step.Power)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(step.DurationMs) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err = w.setRedstone(ctx, step, 0)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown action '%s'", step.Action)
+	}
+
+	if step.Verify != nil {
+		return w.verify(ctx, step)
+	}
+	return nil
+}
+
+// setRedstone sets output and reads it back, relay can silently ignore
+// unknown side
+func (w *ActuatorWorker) setRedstone(ctx context.Context, step *dao.ActuatorStep, power int) error {
+	err := w.modemManager.SetRedstone(ctx, step.Peripheral, step.Side, power)
+	if err != nil {
+		return err
+	}
+	actual, err := w.modemManager.GetRedstone(ctx, step.Peripheral, step.Side)
+	if err != nil {
+		return err
+	}
+	if actual != power {
+		return fmt.Errorf("redstone output of %s is %d, expected %d", step.Side, actual, power)
+	}
+	return nil
+}
+
+func (w *ActuatorWorker) verify(ctx context.Context, step *dao.ActuatorStep) error {
+	value, err := w.modemManager.CallPeripheral(ctx, step.Peripheral, step.Verify.Method, step.Verify.Args)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	actual, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	expected, err := json.Marshal(step.Verify.Expect)
+	if err != nil {
+		return err
+	}
+	if string(actual) != string(expected) {
+		return fmt.Errorf("verify: %s returned %s, expected %s", step.Verify.Method, actual, expected)
+	}
+	return nil
+}
+
+func validateActuatorSteps(steps []dao.ActuatorStep) error {
+	for i, step := range steps {
+		var err error
+		switch step.Action {
+		case dao.ACTUATOR_ACTION_CALL:
+			if step.Peripheral == "" || step.Method == "" {
+				err = errors.New("peripheral and method are required")
+			}
+		case dao.ACTUATOR_ACTION_REDSTONE, dao.ACTUATOR_ACTION_PULSE:
+			if step.Side == "" {
+				err = errors.New("side is required")
+			} else if step.Power < 0 || step.Power > 15 {
+				err = errors.New("power should be in 0..15")
+			} else if step.Action == dao.ACTUATOR_ACTION_PULSE && (step.DurationMs <= 0 || step.Power == 0) {
+				err = errors.New("pulse power and duration should be positive")
+			}
+		default:
+			err = fmt.Errorf("unknown action '%s'", step.Action)
+		}
+		if err == nil && step.TimeoutMs < 0 {
+			err = errors.New("timeout should not be negative")
+		}
+		if err == nil && step.Verify != nil && (step.Verify.Method == "" || step.Peripheral == "") {
+			err = errors.New("verify requires peripheral and method")
+		}
+		if err != nil {
+			return fmt.Errorf("actuator step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
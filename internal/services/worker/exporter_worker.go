@@ -1,29 +1,206 @@
 package worker
 
 import (
+	"context"
+	"log"
+	"slices"
+
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
 	"github.com/asek-ll/aecc-server/internal/services/storage"
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
 type ExporterWorker struct {
-	storage storage.Storage
+	storage        storage.Storage
+	daos           *dao.DaoProvider
+	storageAdapter *wsmethods.StorageAdapter
+	condService    *cond.CondService
 }
 
-func NewExporterWorker(storage storage.Storage) *ExporterWorker {
+func NewExporterWorker(storage storage.Storage, daos *dao.DaoProvider, storageAdapter *wsmethods.StorageAdapter, condService *cond.CondService) *ExporterWorker {
 	return &ExporterWorker{
-		storage: storage,
+		storage:        storage,
+		daos:           daos,
+		storageAdapter: storageAdapter,
+		condService:    condService,
 	}
 }
 
-func (w *ExporterWorker) do(config *dao.ExporterWorkerConfig) error {
+type exportState struct {
+	counts      map[string]int
+	reserved    map[string]int
+	inventories map[string][]wsmethods.StackWithSlot
+}
+
+// available returns amount of item which can be exported without touching
+// plan reserves and configured minimum
+func (s *exportState) available(uid string, exportConfig *dao.SingleExportConfig) int {
+	return s.counts[uid] - s.reserved[uid] - exportConfig.Min
+}
 
-	for _, exportConfig := range config.Exports {
-		_, err := w.storage.ExportStack(exportConfig.Storage, exportConfig.Storage, exportConfig.Slot, exportConfig.Amount)
+func (w *ExporterWorker) do(config *dao.ExporterWorkerConfig) (int, error) {
+	if config.Condition != "" {
+		ready, err := w.condService.Eval(config.Condition)
 		if err != nil {
-			return err
+			return 0, err
+		}
+		if !ready {
+			return 0, nil
+		}
+	}
+
+	counts, err := w.storage.GetItemsCount()
+	if err != nil {
+		return 0, err
+	}
+	reserved, err := w.daos.ItemReserves.GetReservedAmounts()
+	if err != nil {
+		return 0, err
+	}
+	state := &exportState{
+		counts:      counts,
+		reserved:    reserved,
+		inventories: make(map[string][]wsmethods.StackWithSlot),
+	}
+
+	total := 0
+	for i := range config.Exports {
+		moved, err := w.export(state, &config.Exports[i])
+		total += moved
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func (w *ExporterWorker) resolveUids(exportConfig *dao.SingleExportConfig) ([]string, error) {
+	var uids []string
+	if exportConfig.Item != "" {
+		uids = append(uids, exportConfig.Item)
+	}
+	if exportConfig.Tag != "" {
+		tagged, err := w.daos.ImporetedRecipes.FindItemsByTag(exportConfig.Tag)
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range tagged {
+			if !slices.Contains(uids, uid) {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	return uids, nil
+}
+
+func (w *ExporterWorker) getStacks(state *exportState, inventory string) ([]wsmethods.StackWithSlot, error) {
+	stacks, e := state.inventories[inventory]
+	if e {
+		return stacks, nil
+	}
+	stacks, err := w.storageAdapter.ListItems(inventory)
+	if err != nil {
+		return nil, err
+	}
+	state.inventories[inventory] = stacks
+	return stacks, nil
+}
+
+func (w *ExporterWorker) exportStack(state *exportState, uid string, exportConfig *dao.SingleExportConfig, slot int, amount int) (int, error) {
+	toExport := min(amount, state.available(uid, exportConfig))
+	if toExport <= 0 {
+		return 0, nil
+	}
+	// single move from storage to target, it needs no staging
+	moved, err := w.storage.ExportStack(context.Background(), uid, exportConfig.Storage, slot, toExport)
+	if err != nil {
+		return 0, err
+	}
+	state.counts[uid] -= moved
+	if moved > 0 {
+		// target inventory content changed
+		delete(state.inventories, exportConfig.Storage)
+	}
+	return moved, nil
+}
+
+func (w *ExporterWorker) export(state *exportState, exportConfig *dao.SingleExportConfig) (int, error) {
+	uids, err := w.resolveUids(exportConfig)
+	if err != nil {
+		return 0, err
+	}
+	if len(uids) == 0 {
+		log.Printf("[WARN] No items to export to %s", exportConfig.Storage)
+		return 0, nil
+	}
+
+	// prefer items with more available amount
+	slices.SortStableFunc(uids, func(a, b string) int {
+		return state.available(b, exportConfig) - state.available(a, exportConfig)
+	})
+
+	stacks, err := w.getStacks(state, exportConfig.Storage)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+
+	slots := exportConfig.TargetSlots()
+	if len(slots) == 0 {
+		current := 0
+		for _, stack := range stacks {
+			if slices.Contains(uids, stack.Item.GetUID()) {
+				current += stack.Item.Count
+			}
 		}
+		missing := exportConfig.Amount - current
+		for _, uid := range uids {
+			if missing <= 0 {
+				break
+			}
+			moved, err := w.exportStack(state, uid, exportConfig, 0, missing)
+			if err != nil {
+				return total, err
+			}
+			total += moved
+			missing -= moved
+		}
+		return total, nil
+	}
 
+	for _, slot := range slots {
+		idx := slices.IndexFunc(stacks, func(stack wsmethods.StackWithSlot) bool {
+			return stack.Slot == slot
+		})
+		if idx >= 0 {
+			stack := stacks[idx].Item
+			uid := stack.GetUID()
+			if !slices.Contains(uids, uid) {
+				log.Printf("[DEBUG] Slot %d of %s is occupied by %s", slot, exportConfig.Storage, uid)
+				continue
+			}
+			moved, err := w.exportStack(state, uid, exportConfig, slot, exportConfig.Amount-stack.Count)
+			if err != nil {
+				return total, err
+			}
+			total += moved
+			continue
+		}
+
+		for _, uid := range uids {
+			moved, err := w.exportStack(state, uid, exportConfig, slot, exportConfig.Amount)
+			if err != nil {
+				return total, err
+			}
+			total += moved
+			if moved > 0 {
+				break
+			}
+		}
 	}
 
-	return nil
+	return total, nil
 }
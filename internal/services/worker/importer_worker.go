@@ -2,34 +2,103 @@ package worker
 
 import (
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
 	"github.com/asek-ll/aecc-server/internal/services/storage"
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
 type ImporterWorker struct {
-	storage storage.Storage
+	storage        storage.Storage
+	daos           *dao.DaoProvider
+	storageAdapter *wsmethods.StorageAdapter
+	condService    *cond.CondService
 }
 
-func NewImporterWorker(storage storage.Storage) *ImporterWorker {
+func NewImporterWorker(storage storage.Storage, daos *dao.DaoProvider, storageAdapter *wsmethods.StorageAdapter, condService *cond.CondService) *ImporterWorker {
 	return &ImporterWorker{
-		storage: storage,
+		storage:        storage,
+		daos:           daos,
+		storageAdapter: storageAdapter,
+		condService:    condService,
 	}
 }
 
-func (w *ImporterWorker) do(config *dao.ImporterWorkerConfig) error {
+func (w *ImporterWorker) do(config *dao.ImporterWorkerConfig) (int, error) {
+	if config.Condition != "" {
+		ready, err := w.condService.Eval(config.Condition)
+		if err != nil {
+			return 0, err
+		}
+		if !ready {
+			return 0, nil
+		}
+	}
+
+	total := 0
+	for i := range config.Imports {
+		moved, err := w.importItems(&config.Imports[i])
+		total += moved
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func (w *ImporterWorker) importItems(importConfig *dao.SingleImportConfig) (int, error) {
+	filter, err := newItemFilter(w.daos, importConfig.Allow, importConfig.Deny)
+	if err != nil {
+		return 0, err
+	}
+
+	stacks, err := w.storageAdapter.ListItems(importConfig.Storage)
+	if err != nil {
+		return 0, err
+	}
+
+	// amount of each item which can be taken from source
+	importable := make(map[string]int)
+	for _, stack := range stacks {
+		if importConfig.Slot != 0 && stack.Slot != importConfig.Slot {
+			continue
+		}
+		uid := stack.Item.GetUID()
+		if !filter.Match(uid) {
+			continue
+		}
+		importable[uid] += stack.Item.Count
+	}
+	for uid, count := range importable {
+		importable[uid] = count - importConfig.Leave
+	}
+
+	total := 0
+	budget := importConfig.MaxPerTick
+	for _, stack := range stacks {
+		if importConfig.MaxPerTick > 0 && budget <= 0 {
+			break
+		}
+		if importConfig.Slot != 0 && stack.Slot != importConfig.Slot {
+			continue
+		}
+		uid := stack.Item.GetUID()
+		toImport := min(stack.Item.Count, importable[uid])
+		if importConfig.MaxPerTick > 0 {
+			toImport = min(toImport, budget)
+		}
+		if toImport <= 0 {
+			continue
+		}
 
-	for _, importConfig := range config.Imports {
-		if importConfig.Slot == 0 {
-			err := w.storage.ImportAll(importConfig.Storage)
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err := w.storage.ImportUnknownStack(importConfig.Storage, importConfig.Slot)
-			if err != nil {
-				return err
-			}
+		moved, err := w.storage.ImportStack(uid, importConfig.Storage, stack.Slot, toImport)
+		if err != nil {
+			return total, err
 		}
+		total += moved
+		importable[uid] -= moved
+		budget -= moved
 	}
 
-	return nil
+	return total, nil
 }
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+// itemPatterns matches item uids by patterns: exact uid, "#tag" or "@mod"
+type itemPatterns struct {
+	uids map[string]struct{}
+	mods map[string]struct{}
+	// configured is set when any pattern given, even if tags resolved to
+	// nothing
+	configured bool
+}
+
+func newItemPatterns(daos *dao.DaoProvider, patterns []string) (*itemPatterns, error) {
+	result := &itemPatterns{
+		uids: make(map[string]struct{}),
+		mods: make(map[string]struct{}),
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		result.configured = true
+		switch {
+		case strings.HasPrefix(pattern, "@"):
+			result.mods[pattern[1:]] = struct{}{}
+		case strings.HasPrefix(pattern, "#"):
+			uids, err := daos.ImporetedRecipes.FindItemsByTag(pattern[1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, uid := range uids {
+				result.uids[uid] = struct{}{}
+			}
+		default:
+			result.uids[pattern] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+func (p *itemPatterns) Empty() bool {
+	return !p.configured
+}
+
+func (p *itemPatterns) Match(uid string) bool {
+	if _, e := p.uids[uid]; e {
+		return true
+	}
+	mod, _, found := strings.Cut(uid, ":")
+	if found {
+		if _, e := p.mods[mod]; e {
+			return true
+		}
+	}
+	return false
+}
+
+type itemFilter struct {
+	allow *itemPatterns
+	deny  *itemPatterns
+}
+
+func newItemFilter(daos *dao.DaoProvider, allow []string, deny []string) (*itemFilter, error) {
+	allowPatterns, err := newItemPatterns(daos, allow)
+	if err != nil {
+		return nil, err
+	}
+	denyPatterns, err := newItemPatterns(daos, deny)
+	if err != nil {
+		return nil, err
+	}
+	return &itemFilter{
+		allow: allowPatterns,
+		deny:  denyPatterns,
+	}, nil
+}
+
+func (f *itemFilter) Match(uid string) bool {
+	if !f.allow.Empty() && !f.allow.Match(uid) {
+		return false
+	}
+	return !f.deny.Match(uid)
+}
@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/asek-ll/aecc-server/internal/config"
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+// MachineStats is utilisation of single machine of machine group, machine is
+// busy on run when it has crafts in progress or can't accept new ones
+type MachineStats struct {
+	Name       string
+	Runs       int
+	BusyRuns   int
+	Busy       bool
+	InFlight   int
+	Crafts     int
+	ItemsMoved int
+	LastCraft  time.Time
+	LastError  string
+}
+
+func (s *MachineStats) Utilisation() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.BusyRuns) / float64(s.Runs)
+}
+
+type machineGroupState struct {
+	// first machine to check on next run, rotated to spread crafts
+	offset   int
+	machines map[string]*MachineStats
+}
+
+type MachineGroupWorker struct {
+	processCrafterWorker *ProcessingCrafterWorker
+
+	groups map[string]*machineGroupState
+	mu     sync.Mutex
+}
+
+func NewMachineGroupWorker(processCrafterWorker *ProcessingCrafterWorker) *MachineGroupWorker {
+	return &MachineGroupWorker{
+		processCrafterWorker: processCrafterWorker,
+		groups:               make(map[string]*machineGroupState),
+	}
+}
+
+// machineWorkerKey is key of worker state of single machine
+func machineWorkerKey(key string, name string) string {
+	return fmt.Sprintf("%s:%s", key, name)
+}
+
+func machineCrafterConfig(key string, cfg *dao.MachineGroupWorkerConfig, machine *dao.MachineConfig) config.ProcessCrafterConfig {
+	result := processCrafterConfig(machineWorkerKey(key, machine.GetName()), &cfg.ProcessingCrafterWorkerConfig)
+	result.InputInventory = machine.InputInventory
+	result.InputTank = machine.InputTank
+	result.ResultInventory = machine.ResultInventory
+	result.ResultTank = machine.ResultTank
+	if len(machine.PreCraft) > 0 {
+		result.PreCraft = machine.PreCraft
+	}
+	if len(machine.PostCraft) > 0 {
+		result.PostCraft = machine.PostCraft
+	}
+	result.MaxCrafts = 1
+	return result
+}
+
+func (w *MachineGroupWorker) nextOffset(key string, machines int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	group := w.getGroup(key)
+	offset := group.offset % machines
+	group.offset = offset + 1
+	return offset
+}
+
+func (w *MachineGroupWorker) getGroup(key string) *machineGroupState {
+	group, e := w.groups[key]
+	if !e {
+		group = &machineGroupState{
+			machines: make(map[string]*MachineStats),
+		}
+		w.groups[key] = group
+	}
+	return group
+}
+
+// do dispatches crafts one by one to idle machines until queue is empty or
+// all machines are busy
+func (w *MachineGroupWorker) do(key string, cfg *dao.MachineGroupWorkerConfig) (int, error) {
+	machines := cfg.Machines
+	if len(machines) == 0 {
+		return 0, errors.New("no machines in group")
+	}
+
+	offset := w.nextOffset(key, len(machines))
+	results := make([]processResult, len(machines))
+	errs := make([]error, len(machines))
+
+	total := 0
+	for round := 0; ; round += 1 {
+		dispatched := false
+		for i := range machines {
+			idx := (offset + i) % len(machines)
+			prev := &results[idx]
+			if errs[idx] != nil || (round > 0 && prev.busy) {
+				continue
+			}
+			machineConfig := machineCrafterConfig(key, cfg, &machines[idx])
+			result, err := w.processCrafterWorker.process(machineConfig)
+			total += result.moved
+			if err != nil {
+				log.Printf("[WARN] Machine %s of %s failed: %v", machines[idx].GetName(), key, err)
+				errs[idx] = fmt.Errorf("machine %s: %w", machines[idx].GetName(), err)
+			}
+			prev.moved += result.moved
+			prev.submitted += result.submitted
+			prev.inFlight = result.inFlight
+			prev.busy = result.busy
+			if result.submitted > 0 {
+				dispatched = true
+			}
+		}
+		if !dispatched {
+			break
+		}
+	}
+
+	w.updateStats(key, machines, results, errs)
+
+	return total, errors.Join(errs...)
+}
+
+func (w *MachineGroupWorker) updateStats(key string, machines []dao.MachineConfig, results []processResult, errs []error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	group := w.getGroup(key)
+	for i := range machines {
+		name := machines[i].GetName()
+		stats, e := group.machines[name]
+		if !e {
+			stats = &MachineStats{Name: name}
+			group.machines[name] = stats
+		}
+		result := results[i]
+		stats.Runs += 1
+		stats.Busy = result.busy || result.inFlight > 0 || result.submitted > 0
+		if stats.Busy {
+			stats.BusyRuns += 1
+		}
+		stats.InFlight = result.inFlight
+		stats.Crafts += result.submitted
+		stats.ItemsMoved += result.moved
+		if result.submitted > 0 {
+			stats.LastCraft = time.Now()
+		}
+		if errs[i] != nil {
+			stats.LastError = errs[i].Error()
+		} else {
+			stats.LastError = ""
+		}
+	}
+}
+
+// getStats returns stats of configured machines in config order
+func (w *MachineGroupWorker) getStats(key string, cfg *dao.MachineGroupWorkerConfig) []MachineStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var result []MachineStats
+	group := w.groups[key]
+	for _, machine := range cfg.Machines {
+		name := machine.GetName()
+		if group != nil {
+			if stats, e := group.machines[name]; e {
+				result = append(result, *stats)
+				continue
+			}
+		}
+		result = append(result, MachineStats{Name: name})
+	}
+	return result
+}
+
+func (w *MachineGroupWorker) resetStats(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.groups, key)
+}
@@ -1,9 +1,11 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"slices"
+	"time"
 
 	"github.com/asek-ll/aecc-server/internal/common"
 	"github.com/asek-ll/aecc-server/internal/config"
@@ -19,6 +21,7 @@ type ProcessingCrafterWorker struct {
 	storageAdapter *wsmethods.StorageAdapter
 	tm             *storage.TransferTransactionManager
 	condService    *cond.CondService
+	actuator       *ActuatorWorker
 }
 
 func NewProcessingCrafterWorker(
@@ -27,6 +30,7 @@ func NewProcessingCrafterWorker(
 	storageAdapter *wsmethods.StorageAdapter,
 	tm *storage.TransferTransactionManager,
 	condService *cond.CondService,
+	actuator *ActuatorWorker,
 ) *ProcessingCrafterWorker {
 	return &ProcessingCrafterWorker{
 		daos:           daos,
@@ -34,6 +38,7 @@ func NewProcessingCrafterWorker(
 		storageAdapter: storageAdapter,
 		tm:             tm,
 		condService:    condService,
+		actuator:       actuator,
 	}
 }
 
@@ -44,7 +49,9 @@ func isMatch[T comparable](values []T, value T) bool {
 	return slices.Contains(values, value)
 }
 
-func (w *ProcessingCrafterWorker) getItemsToPull(config config.ProcessCrafterConfig) ([]wsmethods.StackWithSlot, error) {
+// getItemsToPull returns result inventory stacks to pull, stacks which are
+// not waited results are pulled too to route them to storage
+func (w *ProcessingCrafterWorker) getItemsToPull(config config.ProcessCrafterConfig, waited map[string]struct{}) ([]wsmethods.StackWithSlot, error) {
 	if config.ResultInventory == "" {
 		return nil, nil
 	}
@@ -56,7 +63,10 @@ func (w *ProcessingCrafterWorker) getItemsToPull(config config.ProcessCrafterCon
 	var result []wsmethods.StackWithSlot
 	for _, item := range items {
 		uid := item.Item.GetUID()
-		if isMatch(config.ResultInventorySlots, item.Slot) && isMatch(config.ResultItems, uid) {
+		if !isMatch(config.ResultInventorySlots, item.Slot) {
+			continue
+		}
+		if isMatch(config.ResultItems, uid) || isUnexpected(waited, uid) {
 			result = append(result, item)
 		}
 	}
@@ -64,19 +74,22 @@ func (w *ProcessingCrafterWorker) getItemsToPull(config config.ProcessCrafterCon
 	return result, nil
 }
 
-func (w *ProcessingCrafterWorker) pullItemsResults(config config.ProcessCrafterConfig, items []wsmethods.StackWithSlot) error {
+func (w *ProcessingCrafterWorker) pullItemsResults(config config.ProcessCrafterConfig, items []wsmethods.StackWithSlot, pulled map[string]int) (int, error) {
+	total := 0
 	for _, item := range items {
 		uid := item.Item.GetUID()
-		_, err := w.storage.ImportStack(uid, config.ResultInventory, item.Slot, item.Item.Count)
+		moved, err := w.storage.ImportStack(uid, config.ResultInventory, item.Slot, item.Item.Count)
 		if err != nil {
-			return err
+			return total, err
 		}
+		total += moved
+		pulled[uid] += moved
 	}
 
-	return nil
+	return total, nil
 }
 
-func (w *ProcessingCrafterWorker) getFluidsToPull(config config.ProcessCrafterConfig) ([]wsmethods.FluidTank, error) {
+func (w *ProcessingCrafterWorker) getFluidsToPull(config config.ProcessCrafterConfig, waited map[string]struct{}) ([]wsmethods.FluidTank, error) {
 	if config.ResultTank == "" {
 		return nil, nil
 	}
@@ -90,7 +103,7 @@ func (w *ProcessingCrafterWorker) getFluidsToPull(config config.ProcessCrafterCo
 	for _, fluid := range fluids {
 		uid := fluid.Fluid.Name
 
-		if isMatch(config.ResultFluids, uid) {
+		if isMatch(config.ResultFluids, uid) || isUnexpected(waited, "fluid:"+uid) {
 			result = append(result, fluid)
 		}
 	}
@@ -98,17 +111,20 @@ func (w *ProcessingCrafterWorker) getFluidsToPull(config config.ProcessCrafterCo
 	return result, nil
 }
 
-func (w *ProcessingCrafterWorker) pullFluidResults(config config.ProcessCrafterConfig, fluids []wsmethods.FluidTank) error {
+func (w *ProcessingCrafterWorker) pullFluidResults(config config.ProcessCrafterConfig, fluids []wsmethods.FluidTank, pulled map[string]int) (int, error) {
+	total := 0
 	for _, fluid := range fluids {
 		uid := fluid.Fluid.Name
 
-		_, err := w.storage.ImportFluid(uid, config.ResultTank, fluid.Fluid.Amount)
+		moved, err := w.storage.ImportFluid(uid, config.ResultTank, fluid.Fluid.Amount)
 		if err != nil {
-			return err
+			return total, err
 		}
+		total += moved
+		pulled["fluid:"+uid] += moved
 	}
 
-	return nil
+	return total, nil
 }
 
 func (w *ProcessingCrafterWorker) canProcess(config config.ProcessCrafterConfig, recipe *dao.Recipe) (bool, error) {
@@ -169,51 +185,340 @@ func (w *ProcessingCrafterWorker) canProcess(config config.ProcessCrafterConfig,
 	return true, nil
 }
 
-func (w *ProcessingCrafterWorker) do(config config.ProcessCrafterConfig) error {
+// isUnexpected reports whether item is not result of any waited craft, nil
+// waited means there are no waited crafts to check against
+func isUnexpected(waited map[string]struct{}, uid string) bool {
+	if waited == nil {
+		return false
+	}
+	_, e := waited[uid]
+	return !e
+}
+
+// isPulledResult reports whether recipe result is pulled by worker
+func isPulledResult(config config.ProcessCrafterConfig, uid string) bool {
+	if common.IsFluid(uid) {
+		return config.ResultTank != "" && isMatch(config.ResultFluids, common.FluidUid(uid))
+	}
+	return config.ResultInventory != "" && isMatch(config.ResultItems, uid)
+}
+
+// resultExpectation is expected yield of single recipe result for craft
+type resultExpectation struct {
+	uid        string
+	expected   float64
+	max        int
+	guaranteed bool
+}
+
+// expectedResults returns expected yields of recipe results pulled by
+// worker for given repeats
+func expectedResults(config config.ProcessCrafterConfig, recipe *dao.Recipe, repeats int) []resultExpectation {
+	var result []resultExpectation
+	for _, res := range recipe.Results {
+		if !isPulledResult(config, res.ItemUID) {
+			continue
+		}
+		result = append(result, resultExpectation{
+			uid:        res.ItemUID,
+			expected:   res.ExpectedPerRepeat() * float64(repeats),
+			max:        res.Amount * repeats,
+			guaranteed: res.IsGuaranteed(),
+		})
+	}
+	return result
+}
+
+type craftWait struct {
+	craft        *dao.Craft
+	recipe       *dao.Recipe
+	expectations []resultExpectation
+	received     map[string]int
+	// updatedAt is time of submission or last received result
+	updatedAt time.Time
+}
+
+const defaultWaitTimeout = 10 * time.Minute
+
+func waitTimeout(config config.ProcessCrafterConfig) time.Duration {
+	if config.WaitTimeout <= 0 {
+		return defaultWaitTimeout
+	}
+	return time.Duration(config.WaitTimeout) * time.Second
+}
+
+func (w *ProcessingCrafterWorker) loadWaits(config config.ProcessCrafterConfig, waitCraftIDs []int) ([]*craftWait, error) {
+	var waits []*craftWait
+	for _, craftID := range waitCraftIDs {
+		craft, err := w.daos.Crafts.FindById(craftID)
+		if err != nil {
+			return nil, err
+		}
+		recipe, err := w.daos.Recipes.GetRecipeById(craft.RecipeID)
+		if err != nil {
+			return nil, err
+		}
+		received, err := w.daos.WorkerState.GetWaitReceived(craft.ID)
+		if err != nil {
+			return nil, err
+		}
+		updatedAt, err := w.daos.WorkerState.GetWaitUpdatedAt(craft.ID)
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, &craftWait{
+			craft:        craft,
+			recipe:       recipe,
+			expectations: expectedResults(config, recipe, craft.CommitRepeats),
+			received:     received,
+			updatedAt:    updatedAt,
+		})
+	}
+	return waits, nil
+}
+
+// waitedResults returns uids of all recipe results of waited crafts, nil if
+// there are no waited crafts
+func waitedResults(waits []*craftWait) map[string]struct{} {
+	if len(waits) == 0 {
+		return nil
+	}
+	result := make(map[string]struct{})
+	for _, wait := range waits {
+		for _, res := range wait.recipe.Results {
+			result[res.ItemUID] = struct{}{}
+		}
+	}
+	return result
+}
+
+// isComplete reports whether all guaranteed results are received, craft
+// with only probabilistic results is complete on any received result
+func (c *craftWait) isComplete() bool {
+	hasGuaranteed := false
+	anyReceived := false
+	for _, e := range c.expectations {
+		if c.received[e.uid] > 0 {
+			anyReceived = true
+		}
+		if !e.guaranteed {
+			continue
+		}
+		hasGuaranteed = true
+		if c.received[e.uid] < e.max {
+			return false
+		}
+	}
+	return hasGuaranteed || anyReceived || len(c.expectations) == 0
+}
+
+func (c *craftWait) yields(workerKey string) []dao.CraftYield {
+	var result []dao.CraftYield
+	for _, e := range c.expectations {
+		result = append(result, dao.CraftYield{
+			WorkerKey: workerKey,
+			CraftID:   c.craft.ID,
+			RecipeID:  c.recipe.ID,
+			Repeats:   c.craft.CommitRepeats,
+			ItemUID:   e.uid,
+			Expected:  e.expected,
+			Received:  c.received[e.uid],
+		})
+	}
+	return result
+}
+
+// attributeResults attributes pulled results to waited crafts in order of
+// submission up to maximum possible yield, craft is completed with yields
+// recorded when all guaranteed results are received. Machine processes crafts
+// in order, so craft is also closed with received yield when results of later
+// craft arrive or when no results arrive for wait timeout, shortfall is
+// recorded as yield deficit. Results which can't be attributed are recorded
+// as unexpected. Returns crafts which are still waited
+func (w *ProcessingCrafterWorker) attributeResults(config config.ProcessCrafterConfig, waits []*craftWait, pulled map[string]int) ([]*craftWait, error) {
+	if len(waits) == 0 {
+		return waits, nil
+	}
+	current := waits[0]
+
+	lastChanged := -1
+	changed := make([]bool, len(waits))
+	for i, wait := range waits {
+		for _, e := range wait.expectations {
+			taken := min(pulled[e.uid], max(e.max-wait.received[e.uid], 0))
+			if taken > 0 {
+				wait.received[e.uid] += taken
+				pulled[e.uid] -= taken
+				changed[i] = true
+				lastChanged = i
+			}
+		}
+	}
+
+	timeout := waitTimeout(config)
+	var rest []*craftWait
+	for i, wait := range waits {
+		if !wait.isComplete() {
+			stale := !changed[i] && time.Since(wait.updatedAt) > timeout
+			if i >= lastChanged && !stale {
+				if changed[i] {
+					err := w.daos.WorkerState.SetWaitReceived(wait.craft.ID, wait.received)
+					if err != nil {
+						return waits, err
+					}
+				}
+				rest = append(rest, wait)
+				continue
+			}
+			if stale {
+				log.Printf("[WARN] %s craft %d has no results for %v, closed with received yield", config.CraftType, wait.craft.ID, timeout)
+			} else {
+				log.Printf("[WARN] %s craft %d is closed with received yield, results of later craft arrived", config.CraftType, wait.craft.ID)
+			}
+		}
+
+		yields := wait.yields(config.WorkerKey)
+		for _, y := range yields {
+			if y.Deviation() != 0 {
+				log.Printf("[INFO] %s craft %d yield of %s is %d, expected %.2f", config.CraftType, y.CraftID, y.ItemUID, y.Received, y.Expected)
+			}
+		}
+		err := w.daos.WorkerState.CompleteWorkerWait(config.WorkerKey, wait.craft, yields)
+		if err != nil {
+			return waits, err
+		}
+	}
+
+	var unexpected []dao.CraftYield
+	for uid, amount := range pulled {
+		if amount <= 0 {
+			continue
+		}
+		log.Printf("[WARN] %s unexpected result %s x %d routed to storage", config.CraftType, uid, amount)
+		unexpected = append(unexpected, dao.CraftYield{
+			WorkerKey:  config.WorkerKey,
+			CraftID:    current.craft.ID,
+			RecipeID:   current.recipe.ID,
+			Repeats:    current.craft.CommitRepeats,
+			ItemUID:    uid,
+			Received:   amount,
+			Unexpected: true,
+		})
+	}
+	if len(unexpected) > 0 {
+		err := w.daos.CraftYields.InsertYields(unexpected)
+		if err != nil {
+			return rest, err
+		}
+	}
+
+	return rest, nil
+}
+
+// batchRepeats returns number of craft repeats pushed to machine in single
+// transaction, each item ingredient should fit single slot of machine and
+// single stack
+func (w *ProcessingCrafterWorker) batchRepeats(config config.ProcessCrafterConfig, craft *dao.Craft, recipe *dao.Recipe) (int, error) {
+	repeats := min(craft.Repeats, max(config.BatchSize, 1))
+	if recipe.MaxRepeats != nil && *recipe.MaxRepeats > 0 {
+		repeats = min(repeats, *recipe.MaxRepeats)
+	}
+
+	slotCapacity := config.SlotCapacity
+	if slotCapacity <= 0 {
+		slotCapacity = 64
+	}
+	for _, ing := range recipe.Ingredients {
+		if ing.Amount <= 0 {
+			continue
+		}
+		capacity := slotCapacity
+		if common.IsFluid(ing.ItemUID) {
+			if config.TankCapacity <= 0 {
+				continue
+			}
+			capacity = config.TankCapacity
+		} else {
+			maxSize, err := w.storage.GetMaxStackSize(ing.ItemUID)
+			if err != nil {
+				return 0, err
+			}
+			capacity = min(capacity, maxSize)
+		}
+		if ing.Amount > capacity {
+			return 0, fmt.Errorf("recipe %d needs %d of %s per repeat, but machine holds %d", recipe.ID, ing.Amount, ing.ItemUID, capacity)
+		}
+		repeats = min(repeats, capacity/ing.Amount)
+	}
+
+	return max(repeats, 1), nil
+}
+
+func (w *ProcessingCrafterWorker) do(config config.ProcessCrafterConfig) (int, error) {
+	result, err := w.process(config)
+	return result.moved, err
+}
+
+// processResult is outcome of single run for one machine
+type processResult struct {
+	moved     int
+	submitted int
+	inFlight  int
+	// machine can't accept more crafts right now
+	busy bool
+}
+
+func (w *ProcessingCrafterWorker) process(config config.ProcessCrafterConfig) (processResult, error) {
 
 	var err error
+	var result processResult
 
-	waitCraftID, err := w.daos.WorkerState.GetWaitCraftID(config.WorkerKey)
+	waitCraftIDs, err := w.daos.WorkerState.GetWaitCraftIDs(config.WorkerKey)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	itemsToPull, err := w.getItemsToPull(config)
+	waits, err := w.loadWaits(config, waitCraftIDs)
+	if err != nil {
+		return result, err
+	}
+	waited := waitedResults(waits)
+
+	itemsToPull, err := w.getItemsToPull(config, waited)
 	if err != nil {
 		log.Printf("[WARN] %s Error on get pull items: %v", config.CraftType, err)
-		return err
+		return result, err
 	}
 
-	tanksToPull, err := w.getFluidsToPull(config)
+	tanksToPull, err := w.getFluidsToPull(config, waited)
 	if err != nil {
 		log.Printf("[WARN] %s Error on get pull tanks: %v", config.CraftType, err)
-		return err
-	}
-	if waitCraftID != nil {
-		if len(itemsToPull) > 0 || len(tanksToPull) > 0 {
-			craft, err := w.daos.Crafts.FindById(*waitCraftID)
-			if err != nil {
-				return err
-			}
-			err = w.daos.WorkerState.CompleteWorkerWait(config.WorkerKey, craft)
-			if err != nil {
-				return err
-			}
-		} else if config.WaitResults {
-			return nil
-		}
+		return result, err
 	}
 
-	err = w.pullItemsResults(config, itemsToPull)
+	pulled := make(map[string]int)
+	result.moved, err = w.pullItemsResults(config, itemsToPull, pulled)
 	if err != nil {
 		log.Printf("[WARN] %s Error on pull items: %v", config.CraftType, err)
-		return err
+		return result, err
 	}
 
-	err = w.pullFluidResults(config, tanksToPull)
+	_, err = w.pullFluidResults(config, tanksToPull, pulled)
 	if err != nil {
 		log.Printf("[WARN] %s Error on pull fluids: %v", config.CraftType, err)
-		return err
+		return result, err
+	}
+
+	waits, err = w.attributeResults(config, waits, pulled)
+	if err != nil {
+		return result, err
+	}
+
+	maxInFlight := max(config.MaxInFlight, 1)
+	result.inFlight = len(waits)
+	if config.WaitResults && result.inFlight >= maxInFlight {
+		result.busy = true
+		return result, nil
 	}
 
 	checkNext := true
@@ -221,127 +526,157 @@ func (w *ProcessingCrafterWorker) do(config config.ProcessCrafterConfig) error {
 		checkNext = false
 		crafts, err := w.daos.Crafts.FindNextByTypes([]string{config.CraftType}, "unknown")
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		for _, craft := range crafts {
+			if craft.Status != dao.PENDING_CRAFT_STATUS {
+				// part of craft is processed right now
+				continue
+			}
 
 			if config.CraftCondition != "" {
-				ready, err := w.condService.Check(config.CraftCondition, nil)
+				ready, err := w.condService.Eval(config.CraftCondition)
 				if err != nil {
-					return err
+					return result, err
 				}
 				if !ready {
-					return nil
+					return result, nil
 				}
 			}
 
-			if config.ReagentMode == "block" {
-				if config.InputInventory != "" {
-					items, err := w.storageAdapter.ListItems(config.InputInventory)
-					if err != nil {
-						return err
-					}
-					if len(items) > 0 {
-						return nil
-					}
-				}
-				if config.InputTank != "" {
-					fluids, err := w.storageAdapter.GetTanks(config.InputTank)
-					if err != nil {
-						return err
-					}
-					if len(fluids) > 0 {
-						return nil
-					}
-				}
+			recipe, err := w.daos.Recipes.GetRecipeById(craft.RecipeID)
+			if err != nil {
+				return result, err
 			}
 
-			recipe, err := w.daos.Recipes.GetRecipeById(craft.RecipeID)
+			ready, err := w.canProcess(config, recipe)
+			if err != nil {
+				return result, err
+			}
+			if !ready {
+				result.busy = true
+				return result, nil
+			}
+
+			repeats, err := w.batchRepeats(config, craft, recipe)
+			if err != nil {
+				return result, err
+			}
+
+			layout, err := w.daos.RecipeTypes.GetRecipeLayout(recipe.Type)
+			if err != nil {
+				return result, err
+			}
+			ingredientSlots, catalystSlots, err := layout.AssignSlots(recipe)
 			if err != nil {
-				return err
+				return result, err
 			}
 
 			var req storage.ExportRequest
-			slot := 0
-			for _, ing := range recipe.Ingredients {
+			for i, ing := range recipe.Ingredients {
 				if common.IsFluid(ing.ItemUID) {
 					if config.InputTank == "" {
-						return fmt.Errorf("input tank not set")
+						return result, fmt.Errorf("input tank not set")
 					}
 					req.RequestFluids = append(req.RequestFluids, storage.ExportRequestFluids{
 						TargetTankName: config.InputTank,
 						Uid:            common.FluidUid(ing.ItemUID),
-						Amount:         ing.Amount,
+						Amount:         ing.Amount * repeats,
 					})
 				} else {
 					if config.InputInventory == "" {
-						return fmt.Errorf("input storage not set")
-					}
-					slot += 1
-					if ing.Slot != nil {
-						slot = *ing.Slot
+						return result, fmt.Errorf("input storage not set")
 					}
 					req.RequestItems = append(req.RequestItems, storage.ExportRequestItems{
 						TargetStorage: config.InputInventory,
 						Uid:           ing.ItemUID,
-						ToSlot:        slot,
-						Amount:        ing.Amount,
+						ToSlot:        ingredientSlots[i],
+						Amount:        ing.Amount * repeats,
 					})
 				}
 			}
 
-			for _, ing := range recipe.Catalysts {
+			for i, ing := range recipe.Catalysts {
 				if config.InputInventory == "" {
-					return fmt.Errorf("input storage not set")
-				}
-				slot += 1
-				if ing.Slot != nil {
-					slot = *ing.Slot
+					return result, fmt.Errorf("input storage not set")
 				}
 				req.RequestItems = append(req.RequestItems, storage.ExportRequestItems{
 					TargetStorage: config.InputInventory,
 					Uid:           ing.ItemUID,
-					ToSlot:        slot,
+					ToSlot:        catalystSlots[i],
 					Amount:        ing.Amount,
 				})
 			}
 
-			tx, err := w.tm.CreateExportTransaction(req)
+			moved, err := w.submitCraft(config, craft, recipe, repeats, req)
+			result.moved += moved
 			if err != nil {
-				return err
+				return result, err
 			}
+			result.submitted += 1
 
-			defer tx.Rollback()
-
-			err = dao.CommitCraftInOuterTx(tx.DBTx, craft, recipe, 1)
+			err = w.actuator.runSteps(config.PostCraft)
 			if err != nil {
-				return err
+				return result, fmt.Errorf("post craft: %w", err)
 			}
 
 			if config.WaitResults {
-				err = dao.SetWorkerWaitCraftInOuterTx(tx.DBTx, config.WorkerKey, craft)
-			} else {
-				err = dao.CompleteCraftInOuterTx(tx.DBTx, craft)
+				result.inFlight += 1
+				if result.inFlight >= maxInFlight || config.ReagentMode == "block" {
+					result.busy = true
+					return result, nil
+				}
 			}
-
-			if err != nil {
-				return err
+			if config.MaxCrafts > 0 && result.submitted >= config.MaxCrafts {
+				return result, nil
 			}
+			checkNext = true
+			break
+		}
+	}
 
-			err = tx.Commit()
-			if err != nil {
-				return err
-			}
+	return result, nil
+}
 
-			if config.WaitResults {
-				checkNext = false
-				break
-			} else {
-				checkNext = true
-			}
+// submitCraft moves ingredients to machine and commits craft in single
+// transfer transaction, waited craft is split and registered as worker wait.
+// Pre craft steps run once ingredients are staged, so failed staging leaves
+// machine untouched. Returns items moved to machine
+func (w *ProcessingCrafterWorker) submitCraft(config config.ProcessCrafterConfig, craft *dao.Craft, recipe *dao.Recipe, repeats int, req storage.ExportRequest) (int, error) {
+	tx, err := w.tm.CreateExportTransaction(context.Background(), req)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if config.WaitResults {
+		// waited part is split, so rest of craft can be pipelined
+		craft, err = dao.SplitCraftInOuterTx(tx.DBTx, craft, repeats)
+		if err != nil {
+			return 0, err
 		}
 	}
 
-	return nil
+	err = dao.CommitCraftInOuterTx(tx.DBTx, craft, recipe, repeats)
+	if err != nil {
+		return 0, err
+	}
+
+	if config.WaitResults {
+		err = dao.SetWorkerWaitCraftInOuterTx(tx.DBTx, config.WorkerKey, craft)
+	} else {
+		err = dao.CompleteCraftInOuterTx(tx.DBTx, craft)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = w.actuator.runSteps(config.PreCraft)
+	if err != nil {
+		return 0, fmt.Errorf("pre craft: %w", err)
+	}
+
+	err = tx.Commit()
+	return tx.Moved(), err
 }
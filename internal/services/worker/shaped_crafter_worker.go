@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/crafter"
+	"github.com/asek-ll/aecc-server/internal/services/storage"
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
+)
+
+type ShapedCrafterWorker struct {
+	daos           *dao.DaoProvider
+	storage        *storage.Storage
+	tm             *storage.TransferTransactionManager
+	clientsManager *wsmethods.ClientsManager
+
+	// recipe types supported by turtles of worker on last run
+	types map[string][]string
+	mu    sync.Mutex
+}
+
+func NewShapedCrafterWorker(
+	daos *dao.DaoProvider,
+	storage *storage.Storage,
+	tm *storage.TransferTransactionManager,
+	clientsManager *wsmethods.ClientsManager,
+) *ShapedCrafterWorker {
+	return &ShapedCrafterWorker{
+		daos:           daos,
+		storage:        storage,
+		tm:             tm,
+		clientsManager: clientsManager,
+		types:          make(map[string][]string),
+	}
+}
+
+// turtleWorkerID is id of worker assigned to crafts of single turtle
+func turtleWorkerID(key string, clientID string) string {
+	return fmt.Sprintf("%s:%s", key, clientID)
+}
+
+func (w *ShapedCrafterWorker) getClient(clientID string) (*wsmethods.CrafterClient, error) {
+	client, e := w.clientsManager.GetClientByID(clientID)
+	if !e {
+		return nil, fmt.Errorf("client '%s' is not connected", clientID)
+	}
+	crafterClient, ok := client.(*wsmethods.CrafterClient)
+	if !ok {
+		return nil, fmt.Errorf("client '%s' is not crafter", clientID)
+	}
+	return crafterClient, nil
+}
+
+func (w *ShapedCrafterWorker) processTurtle(key string, config *dao.ShapedCrafterWorkerConfig, turtle *dao.ShapedCrafterTurtleConfig) (int, []string, error) {
+	client, err := w.getClient(turtle.Client)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	inputStorage, outputStorage := config.TurtleStorages(turtle)
+
+	craftWorker := crafter.NewCraftWorker(turtleWorkerID(key, turtle.Client), client, w.storage, w.tm, w.daos, inputStorage, outputStorage)
+	completed, err := craftWorker.Process()
+	if err != nil {
+		err = fmt.Errorf("turtle '%s': %w", turtle.Client, err)
+	}
+	return completed, craftWorker.GetLastTypes(), err
+}
+
+// do runs all turtles of worker in parallel, returns number of completed crafts
+func (w *ShapedCrafterWorker) do(key string, config *dao.ShapedCrafterWorkerConfig) (int, error) {
+	// workers saved before storage check can have shared storages
+	err := validateShapedCrafterWorkerConfig(config)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	var types []string
+	var errs []error
+
+	for i := range config.Turtles {
+		turtle := &config.Turtles[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			completed, turtleTypes, err := w.processTurtle(key, config, turtle)
+
+			mu.Lock()
+			defer mu.Unlock()
+			total += completed
+			for _, tp := range turtleTypes {
+				if !slices.Contains(types, tp) {
+					types = append(types, tp)
+				}
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	if len(types) > 0 {
+		w.types[key] = types
+	}
+	w.mu.Unlock()
+
+	return total, errors.Join(errs...)
+}
+
+// keysForType returns keys of workers which turtles supported recipe type on
+// last run
+func (w *ShapedCrafterWorker) keysForType(recipeType string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []string
+	for key, types := range w.types {
+		if slices.Contains(types, recipeType) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -1,34 +1,103 @@
 package worker
 
 import (
+	"errors"
 	"log"
+	"sync"
 	"time"
+
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
+const pollInterval = time.Second * 5
+const eventPollInterval = time.Second * 60
+const maxBackoffInterval = time.Minute * 5
+
+// worker is disabled after this number of consecutive failures
+const maxFailures = 10
+
+const WORKER_STATE_STOPPED = "stopped"
+const WORKER_STATE_IDLE = "idle"
+const WORKER_STATE_RUNNING = "running"
+const WORKER_STATE_BACKOFF = "backoff"
+const WORKER_STATE_DISABLED = "disabled"
+
+var ErrWorkerDisabled = errors.New("worker is disabled")
+
+// WorkerRunner performs single worker run and returns number of moved items
+type WorkerRunner = func() (int, error)
+
+type WorkerStatus struct {
+	Key            string
+	State          string
+	DisabledReason string
+	LastRun        time.Time
+	LastDuration   time.Duration
+	LastError      string
+	LastErrorTime  time.Time
+	Failures       int
+	Runs           int
+	LastMoved      int
+	ItemsMoved     int
+	NextRun        time.Time
+}
+
 type WorkerHandler struct {
-	key       string
-	runner    func() error
+	key    string
+	runner WorkerRunner
+	done   chan struct{}
+	ping   chan bool
+	events *wsmethods.EventsManager
+	wakeOn []string
+
+	mu        sync.Mutex
 	isStarted bool
-	done      chan bool
-	ping      chan bool
+	status    WorkerStatus
+}
+
+func newWorkerHandler(key string, runner WorkerRunner, events *wsmethods.EventsManager, wakeOn []string) *WorkerHandler {
+	return &WorkerHandler{
+		key:    key,
+		runner: runner,
+		ping:   make(chan bool, 1),
+		events: events,
+		wakeOn: wakeOn,
+		status: WorkerStatus{
+			Key:   key,
+			State: WORKER_STATE_STOPPED,
+		},
+	}
 }
 
 func (wh *WorkerHandler) IsStarted() bool {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
 	return wh.isStarted
 }
+
 func (c *WorkerHandler) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if !c.isStarted {
 		return
 	}
 	log.Printf("[INFO] Stop worker for '%s'", c.key)
 	c.isStarted = false
-	c.done <- true
+	c.status.State = WORKER_STATE_STOPPED
+	close(c.done)
 }
 
 func (c *WorkerHandler) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isStarted {
+		return nil
+	}
 	log.Printf("[INFO] Start worker for '%s'", c.key)
 	c.isStarted = true
-	go c.work()
+	c.status.State = WORKER_STATE_IDLE
+	c.done = make(chan struct{})
+	go c.work(c.done)
 	return nil
 }
 
@@ -42,23 +111,189 @@ func (c *WorkerHandler) Ping() {
 	}
 }
 
-func (wh *WorkerHandler) work() {
+func (c *WorkerHandler) GetStatus() WorkerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// RunNow wakes worker to run immediately, skipping backoff delay
+func (c *WorkerHandler) RunNow() error {
+	c.mu.Lock()
+	disabled := c.status.State == WORKER_STATE_DISABLED
+	c.mu.Unlock()
+	if disabled {
+		return ErrWorkerDisabled
+	}
+	c.Ping()
+	return nil
+}
+
+func (c *WorkerHandler) Pause() {
+	c.disable("paused")
+	c.Ping()
+}
+
+func (c *WorkerHandler) Resume() {
+	c.mu.Lock()
+	if c.status.State == WORKER_STATE_DISABLED {
+		c.status.State = WORKER_STATE_IDLE
+		c.status.DisabledReason = ""
+	}
+	c.mu.Unlock()
+	c.Ping()
+}
+
+// Reset clears failures and counters and resumes disabled worker
+func (c *WorkerHandler) Reset() {
+	c.mu.Lock()
+	state := c.status.State
+	if state == WORKER_STATE_DISABLED || state == WORKER_STATE_BACKOFF {
+		state = WORKER_STATE_IDLE
+	}
+	c.status = WorkerStatus{
+		Key:   c.key,
+		State: state,
+	}
+	c.mu.Unlock()
+	c.Ping()
+}
+
+func (c *WorkerHandler) disable(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.State == WORKER_STATE_STOPPED {
+		return
+	}
+	c.status.State = WORKER_STATE_DISABLED
+	c.status.DisabledReason = reason
+	c.status.NextRun = time.Time{}
+}
+
+func (c *WorkerHandler) isDisabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status.State == WORKER_STATE_DISABLED
+}
+
+func (c *WorkerHandler) isBackoff() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status.State == WORKER_STATE_BACKOFF
+}
+
+func backoffInterval(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 1; i < failures && delay < maxBackoffInterval; i += 1 {
+		delay *= 2
+	}
+	return min(delay, maxBackoffInterval)
+}
+
+// run performs single run and returns delay before next one
+func (c *WorkerHandler) run(interval time.Duration) time.Duration {
+	c.mu.Lock()
+	c.status.State = WORKER_STATE_RUNNING
+	c.mu.Unlock()
+
+	start := time.Now()
+	moved, err := c.runner()
+	duration := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pausedDuringRun := c.status.State == WORKER_STATE_DISABLED
+
+	c.status.LastRun = start
+	c.status.LastDuration = duration
+	c.status.Runs += 1
+	c.status.LastMoved = moved
+	c.status.ItemsMoved += moved
+
+	delay := interval
+	if err != nil {
+		log.Printf("[ERROR] Can't process worker '%s', error: %v", c.key, err)
+		c.status.LastError = err.Error()
+		c.status.LastErrorTime = time.Now()
+		c.status.Failures += 1
+		c.status.State = WORKER_STATE_BACKOFF
+		delay = backoffInterval(pollInterval, c.status.Failures)
+		if c.status.Failures >= maxFailures {
+			log.Printf("[WARN] Disable worker '%s' after %d failures", c.key, c.status.Failures)
+			c.status.State = WORKER_STATE_DISABLED
+			c.status.DisabledReason = "too many failures"
+		}
+	} else {
+		c.status.Failures = 0
+		c.status.State = WORKER_STATE_IDLE
+	}
+	// state could be changed by stop or pause during run
+	if pausedDuringRun {
+		c.status.State = WORKER_STATE_DISABLED
+	}
+	if !c.isStarted {
+		c.status.State = WORKER_STATE_STOPPED
+	}
+
+	if c.status.State == WORKER_STATE_DISABLED {
+		c.status.NextRun = time.Time{}
+	} else {
+		c.status.NextRun = time.Now().Add(delay)
+	}
+	return delay
+}
+
+func (wh *WorkerHandler) work(done <-chan struct{}) {
+	var wake <-chan wsmethods.ClientEvent
+	interval := pollInterval
+	if wh.events != nil && len(wh.wakeOn) > 0 {
+		sub := wh.events.Subscribe(wh.wakeOn...)
+		defer sub.Close()
+		wake = sub.C
+		interval = eventPollInterval
+	}
+
 	for {
-		if !wh.isStarted {
-			<-wh.done
-			return
+		if wh.isDisabled() {
+			select {
+			case <-done:
+				return
+			case <-wh.ping:
+				continue
+			}
 		}
-		err := wh.runner()
-		if err != nil {
-			log.Printf("[ERROR] Can't process worker '%s', error: %v", wh.key, err)
+
+		delay := wh.run(interval)
+
+		if !wh.waitNext(done, wake, delay) {
+			return
 		}
+	}
+}
+
+// waitNext waits for next run, wake events are ignored while worker backs off
+// after failure, so failing worker is not rerun on every event. Returns false
+// when worker is done
+func (wh *WorkerHandler) waitNext(done <-chan struct{}, wake <-chan wsmethods.ClientEvent, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	backoff := wh.isBackoff()
+	for {
 		select {
-		case <-wh.done:
-			return
-		case <-time.After(time.Second * 5):
-			continue
+		case <-done:
+			return false
+		case <-timer.C:
+			return true
 		case <-wh.ping:
-			continue
+			return true
+		case event := <-wake:
+			if backoff {
+				continue
+			}
+			log.Printf("[DEBUG] Worker '%s' woken by event '%s' from %d", wh.key, event.Stream, event.WsClientID)
+			return true
 		}
 	}
 }
@@ -1,39 +1,53 @@
 package worker
 
-import "sync"
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
+)
+
+var ErrWorkerNotFound = errors.New("worker not found")
 
 type WorkerHandlerManager struct {
 	workerHanlders map[string]*WorkerHandler
+	events         *wsmethods.EventsManager
 
 	mu sync.RWMutex
 }
 
-func NewWorkerHandlerManager() *WorkerHandlerManager {
+func NewWorkerHandlerManager(events *wsmethods.EventsManager) *WorkerHandlerManager {
 	return &WorkerHandlerManager{
 		workerHanlders: make(map[string]*WorkerHandler),
+		events:         events,
 	}
 }
 
-func (w *WorkerHandlerManager) Ping(key string) {
+func (w *WorkerHandlerManager) Get(key string) (*WorkerHandler, error) {
 	w.mu.RLock()
-	if h, e := w.workerHanlders[key]; e {
-		h.Ping()
+	defer w.mu.RUnlock()
+	h, e := w.workerHanlders[key]
+	if !e {
+		return nil, ErrWorkerNotFound
 	}
-	w.mu.RUnlock()
+	return h, nil
+}
+
+func (w *WorkerHandlerManager) Ping(key string) bool {
+	h, err := w.Get(key)
+	if err != nil || !h.IsStarted() {
+		return false
+	}
+	return h.RunNow() == nil
 }
 
-func (w *WorkerHandlerManager) Add(key string, runner func() error) (*WorkerHandler, error) {
+func (w *WorkerHandlerManager) Add(key string, runner WorkerRunner, wakeOn ...string) (*WorkerHandler, error) {
 	w.mu.Lock()
 	if h, e := w.workerHanlders[key]; e {
 		h.Stop()
 	}
-	worker := &WorkerHandler{
-		key:       key,
-		runner:    runner,
-		isStarted: false,
-		done:      make(chan bool),
-		ping:      make(chan bool),
-	}
+	worker := newWorkerHandler(key, runner, w.events, wakeOn)
 	w.workerHanlders[key] = worker
 	w.mu.Unlock()
 	return worker, nil
@@ -47,3 +61,17 @@ func (w *WorkerHandlerManager) Remove(key string) {
 	delete(w.workerHanlders, key)
 	w.mu.Unlock()
 }
+
+func (w *WorkerHandlerManager) GetStatuses() []WorkerStatus {
+	w.mu.RLock()
+	result := make([]WorkerStatus, 0, len(w.workerHanlders))
+	for _, h := range w.workerHanlders {
+		result = append(result, h.GetStatus())
+	}
+	w.mu.RUnlock()
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Key < result[b].Key
+	})
+	return result
+}
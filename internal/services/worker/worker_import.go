@@ -0,0 +1,536 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/cond"
+	"gopkg.in/yaml.v3"
+)
+
+const WORKERS_FORMAT_JSON = "json"
+const WORKERS_FORMAT_YAML = "yaml"
+
+const WORKER_CHANGE_ADD = "add"
+const WORKER_CHANGE_UPDATE = "update"
+const WORKER_CHANGE_REMOVE = "remove"
+const WORKER_CHANGE_KEEP = "keep"
+
+// WorkersSet is portable representation of all workers used for export and
+// import
+type WorkersSet struct {
+	Workers []dao.Worker `json:"workers"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type WorkerChange struct {
+	Key    string     `json:"key"`
+	Action string     `json:"action"`
+	Diff   []DiffLine `json:"diff,omitempty"`
+}
+
+// WorkersImportPlan describes changes which import of workers set makes,
+// with Prune workers missing in imported set are removed
+type WorkersImportPlan struct {
+	Workers []dao.Worker   `json:"-"`
+	Prune   bool           `json:"prune"`
+	Changes []WorkerChange `json:"changes"`
+	Errors  []string       `json:"errors"`
+}
+
+func (p *WorkersImportPlan) HasChanges() bool {
+	for _, change := range p.Changes {
+		if change.Action != WORKER_CHANGE_KEEP {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WorkerManager) ExportWorkers(format string) ([]byte, error) {
+	workers, err := w.daos.Workers.GetWorkers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(workers, func(a, b int) bool {
+		return workers[a].Key < workers[b].Key
+	})
+	set := WorkersSet{Workers: workers}
+	if set.Workers == nil {
+		set.Workers = []dao.Worker{}
+	}
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case WORKERS_FORMAT_JSON, "":
+		return data, nil
+	case WORKERS_FORMAT_YAML:
+		return jsonToYaml(data)
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// jsonToYaml converts json document to yaml in block style keeping order of
+// fields
+func jsonToYaml(data []byte) ([]byte, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(data, &node)
+	if err != nil {
+		return nil, err
+	}
+	resetYamlStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&node)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func resetYamlStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYamlStyle(child)
+	}
+}
+
+// parseWorkersSet parses workers set in json or yaml format, json is valid
+// yaml so format is not required
+func parseWorkersSet(data []byte) (*WorkersSet, error) {
+	var raw any
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	var set WorkersSet
+	err = decoder.Decode(&set)
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// PlanWorkersImport parses and validates workers set and compares it with
+// current workers
+func (w *WorkerManager) PlanWorkersImport(data []byte, prune bool) (*WorkersImportPlan, error) {
+	set, err := parseWorkersSet(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workers set: %w", err)
+	}
+
+	current, err := w.daos.Workers.GetWorkers()
+	if err != nil {
+		return nil, err
+	}
+	currentByKey := make(map[string]*dao.Worker, len(current))
+	for i := range current {
+		currentByKey[current[i].Key] = &current[i]
+	}
+
+	plan := &WorkersImportPlan{
+		Workers: set.Workers,
+		Prune:   prune,
+	}
+
+	importedKeys := make(map[string]struct{}, len(set.Workers))
+	craftTypes := make(map[string]string)
+	for i := range set.Workers {
+		worker := &set.Workers[i]
+		if _, e := importedKeys[worker.Key]; e {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("worker '%s': duplicate key", worker.Key))
+			continue
+		}
+		importedKeys[worker.Key] = struct{}{}
+
+		err := w.validateWorker(worker)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("worker '%s': %v", worker.Key, err))
+			continue
+		}
+
+		if craftType := worker.Config.CraftType(); craftType != "" {
+			if other, e := craftTypes[craftType]; e {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("worker '%s': recipe type '%s' is already used by '%s'", worker.Key, craftType, other))
+			}
+			craftTypes[craftType] = worker.Key
+		}
+
+		prev, e := currentByKey[worker.Key]
+		if !e {
+			plan.Changes = append(plan.Changes, WorkerChange{
+				Key:    worker.Key,
+				Action: WORKER_CHANGE_ADD,
+				Diff:   diffLines(nil, workerLines(worker)),
+			})
+			continue
+		}
+		prevLines := workerLines(prev)
+		nextLines := workerLines(worker)
+		if slices.Equal(prevLines, nextLines) {
+			plan.Changes = append(plan.Changes, WorkerChange{
+				Key:    worker.Key,
+				Action: WORKER_CHANGE_KEEP,
+			})
+			continue
+		}
+		plan.Changes = append(plan.Changes, WorkerChange{
+			Key:    worker.Key,
+			Action: WORKER_CHANGE_UPDATE,
+			Diff:   diffLines(prevLines, nextLines),
+		})
+	}
+
+	for i := range current {
+		worker := &current[i]
+		if _, e := importedKeys[worker.Key]; e {
+			continue
+		}
+		if prune {
+			plan.Changes = append(plan.Changes, WorkerChange{
+				Key:    worker.Key,
+				Action: WORKER_CHANGE_REMOVE,
+				Diff:   diffLines(workerLines(worker), nil),
+			})
+			continue
+		}
+		if craftType := worker.Config.CraftType(); craftType != "" {
+			if other, e := craftTypes[craftType]; e {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("worker '%s': recipe type '%s' is already used by '%s'", other, craftType, worker.Key))
+			}
+		}
+	}
+
+	sort.SliceStable(plan.Changes, func(a, b int) bool {
+		return plan.Changes[a].Key < plan.Changes[b].Key
+	})
+
+	return plan, nil
+}
+
+// ApplyWorkersImport saves planned changes and restarts changed workers
+func (w *WorkerManager) ApplyWorkersImport(plan *WorkersImportPlan) error {
+	if len(plan.Errors) > 0 {
+		return fmt.Errorf("invalid workers set: %s", strings.Join(plan.Errors, "; "))
+	}
+
+	actions := make(map[string]string, len(plan.Changes))
+	var removes []string
+	for _, change := range plan.Changes {
+		actions[change.Key] = change.Action
+		if change.Action == WORKER_CHANGE_REMOVE {
+			removes = append(removes, change.Key)
+		}
+	}
+	var upserts []dao.Worker
+	for _, worker := range plan.Workers {
+		action := actions[worker.Key]
+		if action == WORKER_CHANGE_ADD || action == WORKER_CHANGE_UPDATE {
+			upserts = append(upserts, worker)
+		}
+	}
+
+	err := w.daos.Workers.ApplyWorkers(upserts, removes)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range removes {
+		w.workerHandlers.Remove(key)
+	}
+	for i := range upserts {
+		worker := &upserts[i]
+		handler, err := w.addHandler(worker)
+		if err != nil {
+			return err
+		}
+		if worker.Enabled {
+			handler.Start()
+		}
+	}
+	return nil
+}
+
+func workerLines(worker *dao.Worker) []string {
+	data, err := json.MarshalIndent(worker, "", "  ")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// diffLines returns line diff of two texts based on longest common subsequence
+func diffLines(a, b []string) []DiffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i -= 1 {
+		for j := len(b) - 1; j >= 0; j -= 1 {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			result = append(result, DiffLine{Op: " ", Text: a[i]})
+			i += 1
+			j += 1
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			result = append(result, DiffLine{Op: "-", Text: a[i]})
+			i += 1
+		} else {
+			result = append(result, DiffLine{Op: "+", Text: b[j]})
+			j += 1
+		}
+	}
+	for ; i < len(a); i += 1 {
+		result = append(result, DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < len(b); j += 1 {
+		result = append(result, DiffLine{Op: "+", Text: b[j]})
+	}
+	return result
+}
+
+func (w *WorkerManager) validateWorker(worker *dao.Worker) error {
+	if worker.Key == "" {
+		return errors.New("worker key is required")
+	}
+
+	switch worker.Type {
+	case dao.WORKER_TYPE_EXPORTER:
+		if worker.Config.Exporter == nil {
+			return errors.New("exporter config is required")
+		}
+		return validateExporterWorkerConfig(worker.Config.Exporter)
+	case dao.WORKER_TYPE_IMPORTER:
+		if worker.Config.Importer == nil {
+			return errors.New("importer config is required")
+		}
+		return validateImporterWorkerConfig(worker.Config.Importer)
+	case dao.WORKER_TYPE_PROCESSING_CRAFTER:
+		if worker.Config.ProcessingCrafter == nil {
+			return errors.New("processing crafter config is required")
+		}
+		return w.validateProcessingCrafterWorkerConfig(worker.Config.ProcessingCrafter)
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		if worker.Config.FluidImporter == nil {
+			return errors.New("fluid importer config is required")
+		}
+		return validateFluidImporterWorkerConfig(worker.Config.FluidImporter)
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		if worker.Config.ShapedCrafter == nil {
+			return errors.New("shaped crafter config is required")
+		}
+		return validateShapedCrafterWorkerConfig(worker.Config.ShapedCrafter)
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		if worker.Config.MachineGroup == nil {
+			return errors.New("machine group config is required")
+		}
+		return w.validateMachineGroupWorkerConfig(worker.Config.MachineGroup)
+	case dao.WORKER_TYPE_ACTUATOR:
+		if worker.Config.Actuator == nil {
+			return errors.New("actuator config is required")
+		}
+		return validateActuatorWorkerConfig(worker.Config.Actuator)
+	}
+	return fmt.Errorf("invalid worker type: %s", worker.Type)
+}
+
+// validateCondition checks syntax of optional condition expression
+func validateCondition(condition string) error {
+	if condition == "" {
+		return nil
+	}
+	_, err := cond.Parse(condition)
+	if err != nil {
+		return fmt.Errorf("invalid condition '%s': %v", condition, err)
+	}
+	return nil
+}
+
+func validateExporterWorkerConfig(config *dao.ExporterWorkerConfig) error {
+	if len(config.Exports) == 0 {
+		return errors.New("empty export configs")
+	}
+	for _, exportConfig := range config.Exports {
+		if exportConfig.Storage == "" {
+			return errors.New("empty storage config")
+		}
+		if exportConfig.Item == "" && exportConfig.Tag == "" {
+			return errors.New("empty item config")
+		}
+		if exportConfig.Amount <= 0 {
+			return errors.New("export amount should be positive")
+		}
+		if exportConfig.Min < 0 {
+			return errors.New("export min should not be negative")
+		}
+	}
+	return validateCondition(config.Condition)
+}
+
+func validateImporterWorkerConfig(config *dao.ImporterWorkerConfig) error {
+	if len(config.Imports) == 0 {
+		return errors.New("empty imports configs")
+	}
+	for _, importConfig := range config.Imports {
+		if importConfig.Storage == "" {
+			return errors.New("empty storage config")
+		}
+		if importConfig.Leave < 0 || importConfig.MaxPerTick < 0 {
+			return errors.New("import limits should not be negative")
+		}
+	}
+	return validateCondition(config.Condition)
+}
+
+func validateFluidImporterWorkerConfig(config *dao.FluidImporterWorkerConfig) error {
+	if len(config.Imports) == 0 {
+		return errors.New("empty fluid imports configs")
+	}
+	for _, importConfig := range config.Imports {
+		if importConfig.Tank == "" {
+			return errors.New("empty tank config")
+		}
+		if len(importConfig.Fluids) == 0 {
+			return fmt.Errorf("no fluids for tank %s", importConfig.Tank)
+		}
+	}
+	return nil
+}
+
+func validateShapedCrafterWorkerConfig(config *dao.ShapedCrafterWorkerConfig) error {
+	if len(config.Turtles) == 0 {
+		return errors.New("no turtles assigned")
+	}
+	var clients []string
+	for _, turtle := range config.Turtles {
+		if turtle.Client == "" {
+			return errors.New("empty turtle client")
+		}
+		if slices.Contains(clients, turtle.Client) {
+			return fmt.Errorf("turtle client %s is assigned twice", turtle.Client)
+		}
+		clients = append(clients, turtle.Client)
+	}
+	if len(config.Turtles) == 1 {
+		return nil
+	}
+	// turtles import everything from own storages, so shared storage lets
+	// one turtle take ingredients of other
+	owners := make(map[string]string)
+	for i := range config.Turtles {
+		turtle := &config.Turtles[i]
+		inputStorage, outputStorage := config.TurtleStorages(turtle)
+		if outputStorage == "" {
+			outputStorage = inputStorage
+		}
+		for _, storage := range []string{inputStorage, outputStorage} {
+			// empty input storage is own buffer of turtle
+			if storage == "" {
+				continue
+			}
+			if owner, e := owners[storage]; e && owner != turtle.Client {
+				return fmt.Errorf("storage %s is shared by turtles %s and %s, each turtle needs own input and output storage", storage, owner, turtle.Client)
+			}
+			owners[storage] = turtle.Client
+		}
+	}
+	return nil
+}
+
+func (w *WorkerManager) validateProcessingCrafterWorkerConfig(config *dao.ProcessingCrafterWorkerConfig) error {
+	if config.CraftType == "" {
+		return errors.New("craft type is required")
+	}
+	recipeType, err := w.daos.RecipeTypes.GetRecipeType(config.CraftType)
+	if err != nil {
+		return fmt.Errorf("recipe type get: %v", err)
+	}
+	if recipeType == nil {
+		return fmt.Errorf("recipe type '%s' not found", config.CraftType)
+	}
+	err = validateActuatorSteps(config.PreCraft)
+	if err != nil {
+		return fmt.Errorf("pre craft: %w", err)
+	}
+	err = validateActuatorSteps(config.PostCraft)
+	if err != nil {
+		return fmt.Errorf("post craft: %w", err)
+	}
+	return validateCondition(config.CraftCondition)
+}
+
+func validateActuatorWorkerConfig(config *dao.ActuatorWorkerConfig) error {
+	if len(config.Steps) == 0 {
+		return errors.New("no actuator steps")
+	}
+	err := validateActuatorSteps(config.Steps)
+	if err != nil {
+		return err
+	}
+	return validateCondition(config.Condition)
+}
+
+func (w *WorkerManager) validateMachineGroupWorkerConfig(config *dao.MachineGroupWorkerConfig) error {
+	err := w.validateProcessingCrafterWorkerConfig(&config.ProcessingCrafterWorkerConfig)
+	if err != nil {
+		return err
+	}
+	if len(config.Machines) == 0 {
+		return errors.New("no machines in group")
+	}
+	var names []string
+	for _, machine := range config.Machines {
+		name := machine.GetName()
+		if name == "" {
+			return errors.New("machine without input inventory or tank")
+		}
+		if slices.Contains(names, name) {
+			return fmt.Errorf("machine %s is defined twice", name)
+		}
+		names = append(names, name)
+		err = validateActuatorSteps(machine.PreCraft)
+		if err == nil {
+			err = validateActuatorSteps(machine.PostCraft)
+		}
+		if err != nil {
+			return fmt.Errorf("machine %s: %w", name, err)
+		}
+	}
+	return nil
+}
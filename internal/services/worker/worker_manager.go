@@ -7,9 +7,12 @@ import (
 	"log"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/asek-ll/aecc-server/internal/config"
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/crafter"
+	"github.com/asek-ll/aecc-server/internal/wsmethods"
 )
 
 type WorkerManager struct {
@@ -20,6 +23,10 @@ type WorkerManager struct {
 	processCrafterWorker *ProcessingCrafterWorker
 	configLoader         *config.ConfigLoader
 	fluidImporterWorker  *FluidImporterWorker
+	shapedCrafterWorker  *ShapedCrafterWorker
+	machineGroupWorker   *MachineGroupWorker
+	actuatorWorker       *ActuatorWorker
+	workerFactory        *crafter.WorkerFactory
 }
 
 func NewWorkerManager(
@@ -29,65 +36,163 @@ func NewWorkerManager(
 	importerWorker *ImporterWorker,
 	processCrafterWorker *ProcessingCrafterWorker,
 	fluidImporterWorker *FluidImporterWorker,
+	shapedCrafterWorker *ShapedCrafterWorker,
+	machineGroupWorker *MachineGroupWorker,
+	actuatorWorker *ActuatorWorker,
+	workerFactory *crafter.WorkerFactory,
+	events *wsmethods.EventsManager,
 ) *WorkerManager {
 	wm := &WorkerManager{
-		workerHandlers:       NewWorkerHandlerManager(),
+		workerHandlers:       NewWorkerHandlerManager(events),
 		configLoader:         config,
 		daos:                 daos,
 		exporterWorker:       exporterWorker,
 		importerWorker:       importerWorker,
 		processCrafterWorker: processCrafterWorker,
 		fluidImporterWorker:  fluidImporterWorker,
+		shapedCrafterWorker:  shapedCrafterWorker,
+		machineGroupWorker:   machineGroupWorker,
+		actuatorWorker:       actuatorWorker,
+		workerFactory:        workerFactory,
 	}
+	workerFactory.SetClientFilter(wm.isShapedCrafterClient)
+	workerFactory.AddPingListener(wm.pingShapedCrafters)
 	wm.init()
 
 	return wm
 }
 
-func (w *WorkerManager) getRunner(worker *dao.Worker) func() error {
+func (w *WorkerManager) getRunner(worker *dao.Worker) WorkerRunner {
 	switch worker.Type {
 	case dao.WORKER_TYPE_EXPORTER:
-		return func() error {
+		return func() (int, error) {
 			log.Printf("%s worker tick", worker.Key)
 			return w.exporterWorker.do(worker.Config.Exporter)
 		}
 	case dao.WORKER_TYPE_IMPORTER:
-		return func() error {
+		return func() (int, error) {
 			log.Printf("%s worker tick", worker.Key)
 			return w.importerWorker.do(worker.Config.Importer)
 		}
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		return func() (int, error) {
+			log.Printf("%s shaped crafter tick", worker.Key)
+			return w.shapedCrafterWorker.do(worker.Key, worker.Config.ShapedCrafter)
+		}
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		return func() (int, error) {
+			log.Printf("%s worker tick", worker.Key)
+			return w.fluidImporterWorker.do(worker.Config.FluidImporter)
+		}
 	case dao.WORKER_TYPE_PROCESSING_CRAFTER:
-		cfg := worker.Config.ProcessingCrafter
-		workerConfig := config.ProcessCrafterConfig{
-			WorkerKey:      worker.Key,
-			CraftType:      cfg.CraftType,
-			InputInventory: cfg.InputInventory,
-			InputTank:      cfg.InputTank,
-			ReagentMode:    cfg.ReagentMode,
-			Enabled:        true,
-			WaitResults:    cfg.WaitResults && worker.Key != "",
-			CraftCondition: cfg.CraftCondition,
-
-			ResultItems:          cfg.ResultItems,
-			ResultInventory:      cfg.ResultInventory,
-			ResultInventorySlots: cfg.ResultInventorySlots,
-
-			ResultFluids: cfg.ResultFluids,
-			ResultTank:   cfg.ResultTank,
-		}
-		return func() error {
+		workerConfig := processCrafterConfig(worker.Key, worker.Config.ProcessingCrafter)
+		return func() (int, error) {
 			log.Printf("%s processer crafter tick tick", worker.Key)
 			return w.processCrafterWorker.do(workerConfig)
 		}
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		return func() (int, error) {
+			log.Printf("%s machine group tick", worker.Key)
+			return w.machineGroupWorker.do(worker.Key, worker.Config.MachineGroup)
+		}
+	case dao.WORKER_TYPE_ACTUATOR:
+		return func() (int, error) {
+			log.Printf("%s actuator tick", worker.Key)
+			return w.actuatorWorker.do(worker.Config.Actuator)
+		}
 	}
-	return func() error {
+	return func() (int, error) {
 		log.Printf("NOP %s worker tick", worker.Key)
-		return nil
+		return 0, nil
+	}
+}
+
+func processCrafterConfig(key string, cfg *dao.ProcessingCrafterWorkerConfig) config.ProcessCrafterConfig {
+	return config.ProcessCrafterConfig{
+		WorkerKey:      key,
+		CraftType:      cfg.CraftType,
+		InputInventory: cfg.InputInventory,
+		InputTank:      cfg.InputTank,
+		ReagentMode:    cfg.ReagentMode,
+		Enabled:        true,
+		WaitResults:    cfg.WaitResults && key != "",
+		CraftCondition: cfg.CraftCondition,
+
+		ResultItems:          cfg.ResultItems,
+		ResultInventory:      cfg.ResultInventory,
+		ResultInventorySlots: cfg.ResultInventorySlots,
+
+		ResultFluids: cfg.ResultFluids,
+		ResultTank:   cfg.ResultTank,
+
+		WakeOn: cfg.WakeOn,
+
+		BatchSize:    cfg.BatchSize,
+		SlotCapacity: cfg.SlotCapacity,
+		TankCapacity: cfg.TankCapacity,
+		MaxInFlight:  cfg.MaxInFlight,
+		WaitTimeout:  cfg.WaitTimeout,
+
+		PreCraft:  cfg.PreCraft,
+		PostCraft: cfg.PostCraft,
+	}
+}
+
+func getWakeEvents(worker *dao.Worker) []string {
+	if worker.Type == dao.WORKER_TYPE_PROCESSING_CRAFTER && worker.Config.ProcessingCrafter != nil {
+		return worker.Config.ProcessingCrafter.WakeOn
+	}
+	if worker.Type == dao.WORKER_TYPE_MACHINE_GROUP && worker.Config.MachineGroup != nil {
+		return worker.Config.MachineGroup.WakeOn
+	}
+	if worker.Type == dao.WORKER_TYPE_IMPORTER && worker.Config.Importer != nil {
+		return worker.Config.Importer.WakeOn
+	}
+	if worker.Type == dao.WORKER_TYPE_ACTUATOR && worker.Config.Actuator != nil {
+		return worker.Config.Actuator.WakeOn
+	}
+	return nil
+}
+
+// addHandler replaces handler of worker, turtles of enabled shaped crafter
+// are taken from automatically created crafter workers
+func (w *WorkerManager) addHandler(worker *dao.Worker) (*WorkerHandler, error) {
+	if worker.Enabled && worker.Config.ShapedCrafter != nil {
+		for _, turtle := range worker.Config.ShapedCrafter.Turtles {
+			w.workerFactory.ReleaseClient(turtle.Client)
+		}
+	}
+	return w.workerHandlers.Add(worker.Key, w.getRunner(worker), getWakeEvents(worker)...)
+}
+
+// isShapedCrafterClient checks if client is assigned to enabled shaped crafter
+func (w *WorkerManager) isShapedCrafterClient(clientID string) bool {
+	workers, err := w.daos.Workers.GetWorkers()
+	if err != nil {
+		log.Printf("[ERROR] Can't get workers: %v", err)
+		return false
+	}
+	for _, worker := range workers {
+		if !worker.Enabled || worker.Config.ShapedCrafter == nil {
+			continue
+		}
+		for _, turtle := range worker.Config.ShapedCrafter.Turtles {
+			if turtle.Client == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *WorkerManager) pingShapedCrafters(recipeType string) {
+	for _, key := range w.shapedCrafterWorker.keysForType(recipeType) {
+		w.workerHandlers.Ping(key)
 	}
 }
 
 func (w *WorkerManager) addAndStart(worker *dao.Worker) error {
-	handler, err := w.workerHandlers.Add(worker.Key, w.getRunner(worker))
+	handler, err := w.addHandler(worker)
 	if err != nil {
 		return err
 	}
@@ -96,6 +201,11 @@ func (w *WorkerManager) addAndStart(worker *dao.Worker) error {
 }
 
 func (w *WorkerManager) init() error {
+	err := w.migrateConfigWorkers()
+	if err != nil {
+		log.Printf("[ERROR] Can't migrate config workers: %v", err)
+	}
+
 	workers, err := w.daos.Workers.GetWorkers()
 	if err != nil {
 		return err
@@ -110,34 +220,6 @@ func (w *WorkerManager) init() error {
 		}
 	}
 
-	for _, pc := range w.configLoader.Config.Crafters.ProcessCrafters {
-		if pc.Enabled {
-			handler, err := w.workerHandlers.Add(fmt.Sprintf("pc_%s", pc.CraftType), func() error {
-				return w.processCrafterWorker.do(pc)
-			})
-			if err != nil {
-				return err
-			}
-			err = handler.Start()
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	if len(w.configLoader.Config.Importers.FluidImporters) > 0 {
-		handler, err := w.workerHandlers.Add("fluidimporter", func() error {
-			return w.fluidImporterWorker.do()
-		})
-		if err != nil {
-			return err
-		}
-		err = handler.Start()
-		if err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
 
@@ -155,7 +237,7 @@ func (w *WorkerManager) UpdateWorker(key string, worker *dao.Worker) error {
 		return err
 	}
 
-	handler, err := w.workerHandlers.Add(worker.Key, w.getRunner(worker))
+	handler, err := w.addHandler(worker)
 	if err != nil {
 		return err
 	}
@@ -175,6 +257,114 @@ func (w *WorkerManager) DeleteWorker(key string) error {
 	return nil
 }
 
+// TriggerWorker runs worker immediately, returns false if worker is not running
+func (w *WorkerManager) TriggerWorker(key string) bool {
+	return w.workerHandlers.Ping(key)
+}
+
+func (w *WorkerManager) GetWorkerStatuses() []WorkerStatus {
+	return w.workerHandlers.GetStatuses()
+}
+
+func (w *WorkerManager) PauseWorker(key string) error {
+	handler, err := w.workerHandlers.Get(key)
+	if err != nil {
+		return err
+	}
+	handler.Pause()
+	return nil
+}
+
+func (w *WorkerManager) ResumeWorker(key string) error {
+	handler, err := w.workerHandlers.Get(key)
+	if err != nil {
+		return err
+	}
+	handler.Resume()
+	return nil
+}
+
+func (w *WorkerManager) RunWorkerNow(key string) error {
+	handler, err := w.workerHandlers.Get(key)
+	if err != nil {
+		return err
+	}
+	if !handler.IsStarted() {
+		return fmt.Errorf("worker '%s' is not started", key)
+	}
+	return handler.RunNow()
+}
+
+func (w *WorkerManager) ResetWorker(key string) error {
+	handler, err := w.workerHandlers.Get(key)
+	if err != nil {
+		return err
+	}
+	handler.Reset()
+	w.machineGroupWorker.resetStats(key)
+	return nil
+}
+
+// GetWorkerState returns current state of running worker
+func (w *WorkerManager) GetWorkerState(key string) (string, bool) {
+	handler, err := w.workerHandlers.Get(key)
+	if err != nil {
+		return "", false
+	}
+	return handler.GetStatus().State, true
+}
+
+// WorkerCondition is condition expression used by worker
+type WorkerCondition struct {
+	Key  string
+	Type string
+	Expr string
+}
+
+// GetConditions returns non empty conditions of all workers
+func (w *WorkerManager) GetConditions() ([]WorkerCondition, error) {
+	workers, err := w.daos.Workers.GetWorkers()
+	if err != nil {
+		return nil, err
+	}
+	var result []WorkerCondition
+	for _, worker := range workers {
+		var expr string
+		switch {
+		case worker.Config.ProcessingCrafter != nil:
+			expr = worker.Config.ProcessingCrafter.CraftCondition
+		case worker.Config.MachineGroup != nil:
+			expr = worker.Config.MachineGroup.CraftCondition
+		case worker.Config.Importer != nil:
+			expr = worker.Config.Importer.Condition
+		case worker.Config.Exporter != nil:
+			expr = worker.Config.Exporter.Condition
+		case worker.Config.Actuator != nil:
+			expr = worker.Config.Actuator.Condition
+		}
+		if expr != "" {
+			result = append(result, WorkerCondition{
+				Key:  worker.Key,
+				Type: worker.Type,
+				Expr: expr,
+			})
+		}
+	}
+	return result, nil
+}
+
+// GetMachineStats returns utilisation of machines of machine group worker
+func (w *WorkerManager) GetMachineStats(key string) ([]MachineStats, error) {
+	worker, err := w.daos.Workers.GetWorker(key)
+	if err != nil {
+		return nil, err
+	}
+	if worker.Config.MachineGroup == nil {
+		return nil, fmt.Errorf("worker '%s' is not machine group", key)
+	}
+	return w.machineGroupWorker.getStats(key, worker.Config.MachineGroup), nil
+}
+
 func (w *WorkerManager) GetWorkers() ([]dao.Worker, error) {
 	return w.daos.Workers.GetWorkers()
 }
@@ -202,6 +392,14 @@ func (w *WorkerManager) ParseWorker(params *WorkerParams) (*dao.Worker, error) {
 		config.Importer, err = parseImporterWorkerConfig(params.Config.Importer)
 	case dao.WORKER_TYPE_PROCESSING_CRAFTER:
 		config.ProcessingCrafter, err = w.parseProcessingCrafterWorkerConfig(params.Config.ProcessingCrafter)
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		config.FluidImporter, err = parseFluidImporterWorkerConfig(params.Config.FluidImporter)
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		config.ShapedCrafter, err = parseShapedCrafterWorkerConfig(params.Config.ShapedCrafter)
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		config.MachineGroup, err = w.parseMachineGroupWorkerConfig(params.Config.MachineGroup)
+	case dao.WORKER_TYPE_ACTUATOR:
+		config.Actuator, err = parseActuatorWorkerConfig(params.Config.Actuator)
 	default:
 		return nil, errors.New("invalid worker type")
 	}
@@ -225,11 +423,11 @@ func parseExporterWorkerConfig(params *ExporterWorkerConfigParams) (*dao.Exporte
 			return nil, fmt.Errorf("empty storage config")
 		}
 
-		if exportConfig.Item == "" {
+		if exportConfig.Item == "" && exportConfig.Tag == "" {
 			return nil, fmt.Errorf("empty item config")
 		}
 
-		slot, err := strconv.Atoi(exportConfig.Slot)
+		slots, err := parseSlots(exportConfig.Slot)
 		if err != nil {
 			return nil, err
 		}
@@ -238,20 +436,73 @@ func parseExporterWorkerConfig(params *ExporterWorkerConfigParams) (*dao.Exporte
 		if err != nil {
 			return nil, err
 		}
+		if amount <= 0 {
+			return nil, fmt.Errorf("export amount should be positive")
+		}
+
+		minAmount, err := parseOptionalInt(exportConfig.Min)
+		if err != nil {
+			return nil, err
+		}
 
 		config.Exports = append(config.Exports, dao.SingleExportConfig{
 			Storage: exportConfig.Storage,
 			Item:    exportConfig.Item,
-			Slot:    slot,
+			Tag:     strings.TrimPrefix(exportConfig.Tag, "#"),
+			Slots:   slots,
 			Amount:  amount,
+			Min:     minAmount,
 		})
 	}
 	if len(config.Exports) == 0 {
 		return nil, fmt.Errorf("empty export configs")
 	}
+	config.Condition = strings.TrimSpace(params.Condition)
+	err := validateCondition(config.Condition)
+	if err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+func parseOptionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseList parses comma separated list of values
+func parseList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// parseSlots parses comma separated slots list, empty list means whole inventory
+func parseSlots(value string) ([]int, error) {
+	var slots []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		slot, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		if slot > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
 func parseImporterWorkerConfig(params *ImporterWorkerConfigParams) (*dao.ImporterWorkerConfig, error) {
 	config := dao.ImporterWorkerConfig{}
 
@@ -260,19 +511,37 @@ func parseImporterWorkerConfig(params *ImporterWorkerConfigParams) (*dao.Importe
 		if importConfig.Storage == "" {
 			return nil, fmt.Errorf("empty storage config")
 		}
-		slot, err := strconv.Atoi(importConfig.Slot)
+		slot, err := parseOptionalInt(importConfig.Slot)
+		if err != nil {
+			return nil, err
+		}
+		leave, err := parseOptionalInt(importConfig.Leave)
+		if err != nil {
+			return nil, err
+		}
+		maxPerTick, err := parseOptionalInt(importConfig.MaxPerTick)
 		if err != nil {
 			return nil, err
 		}
 
 		config.Imports = append(config.Imports, dao.SingleImportConfig{
-			Storage: importConfig.Storage,
-			Slot:    slot,
+			Storage:    importConfig.Storage,
+			Slot:       slot,
+			Allow:      parseList(importConfig.Allow),
+			Deny:       parseList(importConfig.Deny),
+			Leave:      leave,
+			MaxPerTick: maxPerTick,
 		})
 	}
 	if len(config.Imports) == 0 {
 		return nil, fmt.Errorf("empty imports configs")
 	}
+	config.WakeOn = parseList(params.WakeOn)
+	config.Condition = strings.TrimSpace(params.Condition)
+	err := validateCondition(config.Condition)
+	if err != nil {
+		return nil, err
+	}
 	return &config, nil
 
 }
@@ -291,13 +560,91 @@ func (w *WorkerManager) parseProcessingCrafterWorkerConfig(params *ProcessingCra
 
 	config.CraftType = params.CraftType
 
-	recipeType, err := w.daos.RecipeTypes.GetRecipeType(config.CraftType)
+	err = w.validateProcessingCrafterWorkerConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func parseFluidImporterWorkerConfig(params *FluidImporterWorkerConfigParams) (*dao.FluidImporterWorkerConfig, error) {
+	config := dao.FluidImporterWorkerConfig{}
+
+	if params == nil || params.RawConfig == "" {
+		return nil, errors.New("empty fluid importer config")
+	}
+
+	err := json.Unmarshal([]byte(params.RawConfig), &config)
 	if err != nil {
-		return nil, fmt.Errorf("recipe type get: %v", err)
+		return nil, fmt.Errorf("fluid importer config parse error: %v", err)
 	}
 
-	if recipeType == nil {
-		return nil, fmt.Errorf("recipe type not found")
+	err = validateFluidImporterWorkerConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func parseShapedCrafterWorkerConfig(params *ShapedCrafterWorkerConfigParams) (*dao.ShapedCrafterWorkerConfig, error) {
+	config := dao.ShapedCrafterWorkerConfig{}
+
+	if params == nil || params.RawConfig == "" {
+		return nil, errors.New("empty shaped crafter config")
+	}
+
+	err := json.Unmarshal([]byte(params.RawConfig), &config)
+	if err != nil {
+		return nil, fmt.Errorf("shaped crafter config parse error: %v", err)
+	}
+
+	err = validateShapedCrafterWorkerConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (w *WorkerManager) parseMachineGroupWorkerConfig(params *MachineGroupWorkerConfigParams) (*dao.MachineGroupWorkerConfig, error) {
+	config := dao.MachineGroupWorkerConfig{}
+
+	if params == nil || params.RawConfig == "" {
+		return nil, errors.New("empty machine group config")
+	}
+
+	err := json.Unmarshal([]byte(params.RawConfig), &config)
+	if err != nil {
+		return nil, fmt.Errorf("machine group config parse error: %v", err)
+	}
+
+	config.CraftType = params.CraftType
+
+	err = w.validateMachineGroupWorkerConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func parseActuatorWorkerConfig(params *ActuatorWorkerConfigParams) (*dao.ActuatorWorkerConfig, error) {
+	config := dao.ActuatorWorkerConfig{}
+
+	if params == nil || params.RawConfig == "" {
+		return nil, errors.New("empty actuator config")
+	}
+
+	err := json.Unmarshal([]byte(params.RawConfig), &config)
+	if err != nil {
+		return nil, fmt.Errorf("actuator config parse error: %v", err)
+	}
+
+	err = validateActuatorWorkerConfig(&config)
+	if err != nil {
+		return nil, err
 	}
 
 	return &config, nil
@@ -310,7 +657,9 @@ func (w *WorkerManager) WorkerToParams(worker *dao.Worker) *WorkerParams {
 func (w *WorkerManager) AddWorkerItemUids(params *WorkerParams, itemLoader *dao.ItemDeferedLoader) {
 	if params.Config.Exporter != nil {
 		for _, exportConfig := range params.Config.Exporter.Exports {
-			itemLoader.AddUid(exportConfig.Item)
+			if exportConfig.Item != "" {
+				itemLoader.AddUid(exportConfig.Item)
+			}
 		}
 	}
 }
@@ -335,6 +684,23 @@ func (w *WorkerManager) NewWorkerParamsForType(workerType string) *WorkerParams
 			CraftType: "",
 			RawConfig: "",
 		}
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		config.FluidImporter = &FluidImporterWorkerConfigParams{
+			RawConfig: "",
+		}
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		config.ShapedCrafter = &ShapedCrafterWorkerConfigParams{
+			RawConfig: "",
+		}
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		config.MachineGroup = &MachineGroupWorkerConfigParams{
+			CraftType: "",
+			RawConfig: "",
+		}
+	case dao.WORKER_TYPE_ACTUATOR:
+		config.Actuator = &ActuatorWorkerConfigParams{
+			RawConfig: "",
+		}
 	}
 
 	return &WorkerParams{
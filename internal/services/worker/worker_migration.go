@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/asek-ll/aecc-server/internal/config"
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+// configWorkersMigration moves workers defined in config file to worker table
+const configWorkersMigration = "config_workers"
+
+func processCrafterWorkerKey(pc *config.ProcessCrafterConfig) string {
+	if pc.WorkerKey != "" {
+		return pc.WorkerKey
+	}
+	return fmt.Sprintf("pc_%s", pc.CraftType)
+}
+
+// configWorkers converts crafters and importers from config file to workers
+// with same keys as they were started before
+func configWorkers(cfg *config.AppConfig) []dao.Worker {
+	var workers []dao.Worker
+
+	for _, pc := range cfg.Crafters.ProcessCrafters {
+		workers = append(workers, dao.Worker{
+			Key:     processCrafterWorkerKey(&pc),
+			Type:    dao.WORKER_TYPE_PROCESSING_CRAFTER,
+			Enabled: pc.Enabled,
+			Config: dao.WorkerConfig{
+				ProcessingCrafter: &dao.ProcessingCrafterWorkerConfig{
+					CraftType:      pc.CraftType,
+					InputInventory: pc.InputInventory,
+					InputTank:      pc.InputTank,
+					ReagentMode:    pc.ReagentMode,
+					WaitResults:    pc.WaitResults,
+					CraftCondition: pc.CraftCondition,
+
+					ResultItems:          pc.ResultItems,
+					ResultInventory:      pc.ResultInventory,
+					ResultInventorySlots: pc.ResultInventorySlots,
+
+					ResultFluids: pc.ResultFluids,
+					ResultTank:   pc.ResultTank,
+
+					WakeOn: pc.WakeOn,
+
+					BatchSize:    pc.BatchSize,
+					SlotCapacity: pc.SlotCapacity,
+					TankCapacity: pc.TankCapacity,
+					MaxInFlight:  pc.MaxInFlight,
+					WaitTimeout:  pc.WaitTimeout,
+				},
+			},
+		})
+	}
+
+	// storage importers were never run from config file, empty items list
+	// imports everything, so they are created disabled for review
+	for _, si := range cfg.Importers.StorageImporters {
+		workers = append(workers, dao.Worker{
+			Key:     fmt.Sprintf("importer_%s", si.Storage),
+			Type:    dao.WORKER_TYPE_IMPORTER,
+			Enabled: false,
+			Config: dao.WorkerConfig{
+				Importer: &dao.ImporterWorkerConfig{
+					Imports: []dao.SingleImportConfig{{
+						Storage: si.Storage,
+						Allow:   si.Items,
+					}},
+				},
+			},
+		})
+	}
+
+	if len(cfg.Importers.FluidImporters) > 0 {
+		fluidConfig := &dao.FluidImporterWorkerConfig{}
+		for _, fi := range cfg.Importers.FluidImporters {
+			fluidConfig.Imports = append(fluidConfig.Imports, dao.SingleFluidImportConfig{
+				Tank:   fi.Tank,
+				Fluids: fi.Fluids,
+			})
+		}
+		workers = append(workers, dao.Worker{
+			Key:     "fluidimporter",
+			Type:    dao.WORKER_TYPE_FLUID_IMPORTER,
+			Enabled: true,
+			Config: dao.WorkerConfig{
+				FluidImporter: fluidConfig,
+			},
+		})
+	}
+
+	return workers
+}
+
+func (w *WorkerManager) migrateConfigWorkers() error {
+	workers := configWorkers(&w.configLoader.Config)
+	skipped, applied, err := w.daos.Workers.MigrateWorkers(configWorkersMigration, workers)
+	if err != nil {
+		return err
+	}
+	if applied {
+		log.Printf("[INFO] Migrated %d workers from config file", len(workers)-len(skipped))
+		for _, worker := range workers {
+			if !worker.Enabled && !slices.Contains(skipped, worker.Key) {
+				log.Printf("[WARN] Config worker '%s' is created disabled, review and enable it on workers page", worker.Key)
+			}
+		}
+		if len(skipped) > 0 {
+			log.Printf("[WARN] Config workers %v are not migrated, they conflict with existing workers, recreate them on workers page", skipped)
+		}
+	} else if len(workers) > 0 {
+		log.Printf("[WARN] Config file still defines %d workers, they are ignored, manage them on workers page", len(workers))
+	}
+	return nil
+}
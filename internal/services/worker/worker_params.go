@@ -14,21 +14,30 @@ import (
 type SingleExportConfigParams struct {
 	Storage string
 	Item    string
+	Tag     string
 	Slot    string
 	Amount  string
+	Min     string
 }
 
 type ExporterWorkerConfigParams struct {
-	Exports []SingleExportConfigParams
+	Exports   []SingleExportConfigParams
+	Condition string
 }
 
 type SingleImportConfigParams struct {
-	Storage string
-	Slot    string
+	Storage    string
+	Slot       string
+	Allow      string
+	Deny       string
+	Leave      string
+	MaxPerTick string
 }
 
 type ImporterWorkerConfigParams struct {
-	Imports []SingleImportConfigParams
+	Imports   []SingleImportConfigParams
+	WakeOn    string
+	Condition string
 }
 
 type ProcessingCrafterWorkerConfigParams struct {
@@ -36,10 +45,31 @@ type ProcessingCrafterWorkerConfigParams struct {
 	RawConfig string
 }
 
+type FluidImporterWorkerConfigParams struct {
+	RawConfig string
+}
+
+type ShapedCrafterWorkerConfigParams struct {
+	RawConfig string
+}
+
+type MachineGroupWorkerConfigParams struct {
+	CraftType string
+	RawConfig string
+}
+
+type ActuatorWorkerConfigParams struct {
+	RawConfig string
+}
+
 type WorkerConfigParams struct {
 	Exporter          *ExporterWorkerConfigParams
 	Importer          *ImporterWorkerConfigParams
 	ProcessingCrafter *ProcessingCrafterWorkerConfigParams
+	FluidImporter     *FluidImporterWorkerConfigParams
+	ShapedCrafter     *ShapedCrafterWorkerConfigParams
+	MachineGroup      *MachineGroupWorkerConfigParams
+	Actuator          *ActuatorWorkerConfigParams
 }
 
 type WorkerParams struct {
@@ -63,6 +93,23 @@ func ParseWorkerParams(values url.Values) *WorkerParams {
 		config.Importer = parseImporterWorkerConfigParams(values)
 	case dao.WORKER_TYPE_PROCESSING_CRAFTER:
 		config.ProcessingCrafter = parseProcessingCrafterWorkerConfigParams(values)
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		config.FluidImporter = &FluidImporterWorkerConfigParams{
+			RawConfig: values.Get("config"),
+		}
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		config.ShapedCrafter = &ShapedCrafterWorkerConfigParams{
+			RawConfig: values.Get("config"),
+		}
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		config.MachineGroup = &MachineGroupWorkerConfigParams{
+			CraftType: values.Get("craft_type"),
+			RawConfig: values.Get("config"),
+		}
+	case dao.WORKER_TYPE_ACTUATOR:
+		config.Actuator = &ActuatorWorkerConfigParams{
+			RawConfig: values.Get("config"),
+		}
 	}
 
 	return &WorkerParams{
@@ -94,10 +141,14 @@ func parseExporterWorkerConfigParams(values url.Values) *ExporterWorkerConfigPar
 			exportConfig.Storage = values[0]
 		case "item":
 			exportConfig.Item = values[0]
+		case "tag":
+			exportConfig.Tag = values[0]
 		case "slot":
 			exportConfig.Slot = values[0]
 		case "amount":
 			exportConfig.Amount = values[0]
+		case "min":
+			exportConfig.Min = values[0]
 		}
 	}
 
@@ -108,6 +159,7 @@ func parseExporterWorkerConfigParams(values url.Values) *ExporterWorkerConfigPar
 		exportConfig := exportConfigs[key]
 		config.Exports = append(config.Exports, *exportConfig)
 	}
+	config.Condition = values.Get("condition")
 	return &config
 }
 
@@ -132,12 +184,22 @@ func parseImporterWorkerConfigParams(values url.Values) *ImporterWorkerConfigPar
 			importConfig.Storage = values[0]
 		case "slot":
 			importConfig.Slot = values[0]
+		case "allow":
+			importConfig.Allow = values[0]
+		case "deny":
+			importConfig.Deny = values[0]
+		case "leave":
+			importConfig.Leave = values[0]
+		case "max":
+			importConfig.MaxPerTick = values[0]
 		}
 	}
 
 	for _, importConfig := range importConfigs {
 		config.Imports = append(config.Imports, *importConfig)
 	}
+	config.WakeOn = values.Get("wakeon")
+	config.Condition = values.Get("condition")
 	return &config
 }
 
@@ -153,23 +215,38 @@ func NewWorkerParams(worker *dao.Worker) *WorkerParams {
 
 	switch worker.Type {
 	case dao.WORKER_TYPE_EXPORTER:
-		exporterConfig := &ExporterWorkerConfigParams{}
+		exporterConfig := &ExporterWorkerConfigParams{
+			Condition: worker.Config.Exporter.Condition,
+		}
 		for _, exportConfig := range worker.Config.Exporter.Exports {
+			var slots []string
+			for _, slot := range exportConfig.TargetSlots() {
+				slots = append(slots, strconv.Itoa(slot))
+			}
 			exporterConfig.Exports = append(exporterConfig.Exports, SingleExportConfigParams{
 				Storage: exportConfig.Storage,
 				Item:    exportConfig.Item,
-				Slot:    strconv.Itoa(exportConfig.Slot),
+				Tag:     exportConfig.Tag,
+				Slot:    strings.Join(slots, ","),
 				Amount:  strconv.Itoa(exportConfig.Amount),
+				Min:     strconv.Itoa(exportConfig.Min),
 			})
 		}
 		config.Exporter = exporterConfig
 
 	case dao.WORKER_TYPE_IMPORTER:
-		importerConfig := &ImporterWorkerConfigParams{}
+		importerConfig := &ImporterWorkerConfigParams{
+			WakeOn:    strings.Join(worker.Config.Importer.WakeOn, ","),
+			Condition: worker.Config.Importer.Condition,
+		}
 		for _, importConfig := range worker.Config.Importer.Imports {
 			importerConfig.Imports = append(importerConfig.Imports, SingleImportConfigParams{
-				Storage: importConfig.Storage,
-				Slot:    strconv.Itoa(importConfig.Slot),
+				Storage:    importConfig.Storage,
+				Slot:       strconv.Itoa(importConfig.Slot),
+				Allow:      strings.Join(importConfig.Allow, ","),
+				Deny:       strings.Join(importConfig.Deny, ","),
+				Leave:      strconv.Itoa(importConfig.Leave),
+				MaxPerTick: strconv.Itoa(importConfig.MaxPerTick),
 			})
 		}
 		config.Importer = importerConfig
@@ -184,6 +261,43 @@ func NewWorkerParams(worker *dao.Worker) *WorkerParams {
 				}
 			}
 		}
+	case dao.WORKER_TYPE_FLUID_IMPORTER:
+		if worker.Config.FluidImporter != nil {
+			result, err := json.MarshalIndent(*worker.Config.FluidImporter, "", "  ")
+			if err == nil {
+				config.FluidImporter = &FluidImporterWorkerConfigParams{
+					RawConfig: string(result),
+				}
+			}
+		}
+	case dao.WORKER_TYPE_SHAPED_CRAFTER:
+		if worker.Config.ShapedCrafter != nil {
+			result, err := json.MarshalIndent(*worker.Config.ShapedCrafter, "", "  ")
+			if err == nil {
+				config.ShapedCrafter = &ShapedCrafterWorkerConfigParams{
+					RawConfig: string(result),
+				}
+			}
+		}
+	case dao.WORKER_TYPE_MACHINE_GROUP:
+		if worker.Config.MachineGroup != nil {
+			result, err := json.MarshalIndent(*worker.Config.MachineGroup, "", "  ")
+			if err == nil {
+				config.MachineGroup = &MachineGroupWorkerConfigParams{
+					CraftType: worker.Config.MachineGroup.CraftType,
+					RawConfig: string(result),
+				}
+			}
+		}
+	case dao.WORKER_TYPE_ACTUATOR:
+		if worker.Config.Actuator != nil {
+			result, err := json.MarshalIndent(*worker.Config.Actuator, "", "  ")
+			if err == nil {
+				config.Actuator = &ActuatorWorkerConfigParams{
+					RawConfig: string(result),
+				}
+			}
+		}
 	}
 
 	return &WorkerParams{
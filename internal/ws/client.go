@@ -1,21 +1,28 @@
 package ws
 
 import (
+	"compress/flate"
 	"encoding/json"
 	"io"
 	"net"
 	"sync"
 
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
+// messages smaller than this are sent uncompressed even if deflate is negotiated
+const compressThreshold = 512
+
 type Client struct {
-	ID      uint
-	conn    net.Conn
-	io      sync.Mutex
-	handler Handler
-	ExtID   string
+	ID         uint
+	conn       net.Conn
+	io         sync.Mutex
+	handler    Handler
+	ExtID      string
+	Compressed bool
+	close      func()
 }
 
 func (c *Client) Receive() error {
@@ -36,6 +43,10 @@ func (c *Client) readMessage() ([]byte, error) {
 	c.io.Lock()
 	defer c.io.Unlock()
 
+	if c.Compressed {
+		return c.readCompressedMessage()
+	}
+
 	h, r, err := wsutil.NextReader(c.conn, ws.StateServerSide)
 	if err != nil {
 		return nil, err
@@ -52,26 +63,77 @@ func (c *Client) readMessage() ([]byte, error) {
 	return data, nil
 }
 
+func (c *Client) readCompressedMessage() ([]byte, error) {
+	var msg wsflate.MessageState
+	controlHandler := wsutil.ControlFrameHandler(c.conn, ws.StateServerSide)
+	rd := &wsutil.Reader{
+		Source:         c.conn,
+		State:          ws.StateServerSide | ws.StateExtended,
+		OnIntermediate: controlHandler,
+		Extensions:     []wsutil.RecvExtension{&msg},
+	}
+
+	h, err := rd.NextFrame()
+	if err != nil {
+		return nil, err
+	}
+	if h.OpCode.IsControl() {
+		return nil, controlHandler(h, rd)
+	}
+
+	var r io.Reader = rd
+	if msg.IsCompressed() {
+		fr := wsflate.NewReader(rd, func(r io.Reader) wsflate.Decompressor {
+			return flate.NewReader(r)
+		})
+		defer fr.Close()
+		r = fr
+	}
+
+	return io.ReadAll(r)
+}
+
 func (c *Client) WriteJSON(x any) error {
-	w := wsutil.NewWriter(c.conn, ws.StateServerSide, ws.OpText)
-	encoder := json.NewEncoder(w)
+	data, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	return c.WriteText(data)
+}
 
+func (c *Client) WriteText(p []byte) error {
 	c.io.Lock()
 	defer c.io.Unlock()
 
-	if err := encoder.Encode(x); err != nil {
+	if c.Compressed && len(p) >= compressThreshold {
+		return c.writeCompressed(p)
+	}
+
+	w := wsutil.NewWriter(c.conn, ws.StateServerSide, ws.OpText)
+	_, err := w.Write(p)
+	if err != nil {
 		return err
 	}
 
 	return w.Flush()
 }
 
-func (c *Client) WriteText(p []byte) error {
-	w := wsutil.NewWriter(c.conn, ws.StateServerSide, ws.OpText)
-	c.io.Lock()
-	defer c.io.Unlock()
+func (c *Client) writeCompressed(p []byte) error {
+	var msg wsflate.MessageState
+	msg.SetCompressed(true)
 
-	_, err := w.Write(p)
+	w := wsutil.NewWriter(c.conn, ws.StateServerSide|ws.StateExtended, ws.OpText)
+	w.SetExtensions(&msg)
+
+	fw := wsflate.NewWriter(w, func(w io.Writer) wsflate.Compressor {
+		f, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return f
+	})
+	_, err := fw.Write(p)
+	if err != nil {
+		return err
+	}
+	err = fw.Close()
 	if err != nil {
 		return err
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/asek-ll/aecc-server/pkg/gopool"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/mailru/easygo/netpoll"
 )
 
@@ -19,8 +20,9 @@ type Server struct {
 	clientsMu  sync.RWMutex
 	clientsSec uint
 
-	exit      chan struct{}
-	ioTimeout time.Duration
+	exit        chan struct{}
+	ioTimeout   time.Duration
+	compression bool
 
 	handler *delegateHandler
 }
@@ -43,6 +45,10 @@ func (s *Server) SetHandler(handler Handler) {
 	s.handler.delegate = handler
 }
 
+func (s *Server) SetCompression(enabled bool) {
+	s.compression = enabled
+}
+
 func (s *Server) CreateHttpHandle() (func(http.ResponseWriter, *http.Request, string), error) {
 	poller, err := netpoll.New(nil)
 	if err != nil {
@@ -50,17 +56,35 @@ func (s *Server) CreateHttpHandle() (func(http.ResponseWriter, *http.Request, st
 	}
 
 	handle := func(w http.ResponseWriter, r *http.Request, externalID string) {
-		conn, _, hs, err := ws.UpgradeHTTP(r, w)
+		upgrader := ws.HTTPUpgrader{}
+		var ext *wsflate.Extension
+		if s.compression {
+			ext = &wsflate.Extension{
+				Parameters: wsflate.DefaultParameters,
+			}
+			upgrader.Negotiate = ext.Negotiate
+		}
+
+		conn, _, hs, err := upgrader.Upgrade(r, w)
 		if err != nil {
 			log.Printf("upgrade error: %v", err)
 			return
 		}
-		log.Printf("established websocket connection: %+v", hs)
+		compressed := false
+		if ext != nil {
+			_, compressed = ext.Accepted()
+		}
+		log.Printf("established websocket connection: %+v, compressed: %v", hs, compressed)
 		safeConn := NewDeadliner(conn, s.ioTimeout)
 
-		client := s.register(safeConn, externalID)
+		client := s.register(safeConn, externalID, compressed)
 
 		desc := netpoll.Must(netpoll.HandleReadOnce(conn))
+		client.close = func() {
+			poller.Stop(desc)
+			conn.Close()
+			s.remove(client)
+		}
 
 		poller.Start(desc, func(ev netpoll.Event) {
 			if ev&(netpoll.EventReadHup|netpoll.EventHup) != 0 {
@@ -103,7 +127,7 @@ func (s *Server) Start(addr string) error {
 
 		log.Printf("%s: established websocket connection: %+v", nameConn(conn), hs)
 
-		client := s.register(safeConn, "unknown")
+		client := s.register(safeConn, "unknown", false)
 
 		desc := netpoll.Must(netpoll.HandleReadOnce(conn))
 
@@ -187,11 +211,12 @@ func (s *Server) Start(addr string) error {
 	return nil
 }
 
-func (c *Server) register(conn net.Conn, externalID string) *Client {
+func (c *Server) register(conn net.Conn, externalID string, compressed bool) *Client {
 	client := &Client{
-		conn:    conn,
-		handler: c.handler,
-		ExtID:   externalID,
+		conn:       conn,
+		handler:    c.handler,
+		ExtID:      externalID,
+		Compressed: compressed,
 	}
 	c.clientsMu.Lock()
 	{
@@ -206,10 +231,27 @@ func (c *Server) register(conn net.Conn, externalID string) *Client {
 
 func (c *Server) remove(client *Client) {
 	c.clientsMu.Lock()
+	_, e := c.clients[client.ID]
 	delete(c.clients, client.ID)
 	c.clientsMu.Unlock()
 
-	c.handler.HandleDisconnect(client)
+	if e {
+		c.handler.HandleDisconnect(client)
+	}
+}
+
+func (c *Server) CloseClient(id uint) error {
+	client, e := c.GetClient(id)
+	if !e {
+		return nil
+	}
+	if client.close != nil {
+		client.close()
+		return nil
+	}
+	err := client.conn.Close()
+	c.remove(client)
+	return err
 }
 
 func (c *Server) GetClient(id uint) (*Client, bool) {
@@ -2,9 +2,11 @@ package wsmethods
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/asek-ll/aecc-server/internal/common"
 	"github.com/asek-ll/aecc-server/internal/config"
 	"github.com/asek-ll/aecc-server/internal/dao"
+	"github.com/asek-ll/aecc-server/internal/services/clients"
 	"github.com/asek-ll/aecc-server/internal/services/clientscripts"
 	"github.com/asek-ll/aecc-server/internal/ws"
 	"github.com/asek-ll/aecc-server/internal/wsrpc"
@@ -24,6 +27,20 @@ const CLIENT_ROLE_PLAYER = "player"
 const CLIENT_ROLE_MODEM = "modem"
 const CLIENT_ROLE_COND = "cond"
 
+const ENCODING_JSON = "json"
+const ENCODING_COMPACT = "compact"
+
+var supportedEncodings = []string{ENCODING_COMPACT}
+
+func negotiateEncoding(offered []string) string {
+	for _, encoding := range offered {
+		if slices.Contains(supportedEncodings, encoding) {
+			return encoding
+		}
+	}
+	return ENCODING_JSON
+}
+
 type Client interface {
 	GetGenericClient() *GenericClient
 }
@@ -34,6 +51,7 @@ type GenericClient struct {
 	JoinTime time.Time
 	Props    map[string]any
 	WS       wsrpc.ClientWrapper
+	Encoding string
 }
 
 func (c *GenericClient) GetGenericClient() *GenericClient {
@@ -48,6 +66,7 @@ type ClientsManager struct {
 	configLoader   *config.ConfigLoader
 
 	clientIdByType map[string]uint
+	events         *EventsManager
 
 	mu sync.RWMutex
 }
@@ -57,6 +76,7 @@ func NewClientsManager(
 	clientsDao *dao.ClientsDao,
 	configLoader *config.ConfigLoader,
 	scriptsManager *clientscripts.ScriptsManager,
+	clientsService *clients.ClientsService,
 ) *ClientsManager {
 	clientsManager := &ClientsManager{
 		server:         server,
@@ -65,16 +85,30 @@ func NewClientsManager(
 		clientListener: DumpCycleListener{},
 		clientIdByType: make(map[string]uint),
 		configLoader:   configLoader,
+		events:         NewEventsManager(),
 	}
 
+	clientsManager.events.setupMethods(server, clientsManager)
+
 	server.SetDisconnectHandler(func(client *ws.Client) error {
 		return clientsManager.RemoveClient(client.ID)
 	})
 
+	server.SetRoleResolver(clientsManager.getRole)
+	server.SetRecordRedactor(redactRecord)
+
 	scriptsManager.SetOnUpdate(func(script *dao.ClientsScript) error {
 		return clientsManager.OnUpdateScript(script)
 	})
 
+	clientsService.SetOnRevoke(func(client *dao.Client) error {
+		if client.WSClientID == nil {
+			return nil
+		}
+		log.Printf("[INFO] Disconnect revoked client %s", client.ID)
+		return server.CloseClient(*client.WSClientID)
+	})
+
 	server.AddMethod("login", wsrpc.Typed(func(wsClient *ws.Client, params LoginV3Params) (any, error) {
 
 		client, err := clientsDao.GetClientByID(wsClient.ExtID)
@@ -85,6 +119,15 @@ func NewClientsManager(
 			return nil, fmt.Errorf("Unathorized")
 		}
 
+		validToken, err := clientsService.CheckToken(client.ID, params.Token)
+		if err != nil {
+			return nil, err
+		}
+		if !validToken {
+			log.Printf("[WARN] Client %s presented invalid token", client.ID)
+			return nil, fmt.Errorf("Unathorized")
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 		defer cancel()
 
@@ -109,6 +152,11 @@ func NewClientsManager(
 			return "OK", nil
 		}
 
+		err = clientsManager.rotateToken(clientsService, wsClient.ID, client.ID)
+		if err != nil {
+			log.Printf("[WARN] Can't rotate token for client %s: %v", client.ID, err)
+		}
+
 		err = clientsDao.LoginClient(client, wsClient.ID)
 		if err != nil {
 			return nil, err
@@ -135,7 +183,10 @@ func NewClientsManager(
 			}
 		}
 
-		err = clientsManager.RegisterClient(wsClient.ID, fmt.Sprintf("%d", params.ID), client.Role, nil)
+		encoding := negotiateEncoding(params.Encodings)
+		log.Printf("[INFO] Client %s negotiated encoding '%s'", client.ID, encoding)
+
+		err = clientsManager.RegisterClient(wsClient.ID, fmt.Sprintf("%d", params.ID), client.Role, nil, encoding)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +197,36 @@ func NewClientsManager(
 	return clientsManager
 }
 
-func (c *ClientsManager) RegisterClient(webscoketClientId uint, id string, role string, props map[string]any) error {
+// rotateToken issues new token on every login. Token is saved as pending
+// before it is sent, both tokens are accepted until client confirms that new
+// one was stored, then old token is dropped
+func (c *ClientsManager) rotateToken(clientsService *clients.ClientsService, wsClientID uint, clientID string) error {
+	token, err := clientsService.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = clientsService.SavePendingToken(clientID, token)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var stored bool
+	err = c.server.SendRequestSync(ctx, wsClientID, "setToken", token, &stored)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return errors.New("client can't store token")
+	}
+
+	return clientsService.ConfirmToken(clientID)
+}
+
+func (c *ClientsManager) RegisterClient(webscoketClientId uint, id string, role string, props map[string]any, encoding string) error {
 
 	ws := wsrpc.NewClientWrapper(c.server, webscoketClientId)
 
@@ -156,6 +236,7 @@ func (c *ClientsManager) RegisterClient(webscoketClientId uint, id string, role
 		JoinTime: time.Now(),
 		Props:    props,
 		WS:       ws,
+		Encoding: encoding,
 	}
 	var client Client
 
@@ -195,6 +276,7 @@ func (c *ClientsManager) RemoveClient(id uint) error {
 	c.mu.RUnlock()
 
 	c.clientsDao.LogoutClient(id)
+	c.events.removeClient(id)
 
 	c.mu.Lock()
 	delete(c.clients, id)
@@ -228,6 +310,67 @@ func CallWithClientForType[T any, V any](c *ClientsManager, fn func(client T) (V
 	return fn(client)
 }
 
+func (c *ClientsManager) GetClient(id uint) (Client, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	client, e := c.clients[id]
+	return client, e
+}
+
+// GetClientByID returns connected client with given client ID
+func (c *ClientsManager) GetClientByID(clientID string) (Client, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, client := range c.clients {
+		if client.GetGenericClient().ID == clientID {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+func (c *ClientsManager) getRole(id uint) string {
+	client, e := c.GetClient(id)
+	if !e {
+		return ""
+	}
+	return client.GetGenericClient().Role
+}
+
+// redactRecord hides client tokens from recorded rpc traffic
+func redactRecord(method string, payload []byte) []byte {
+	if method != "login" && method != "setToken" {
+		return payload
+	}
+	var message map[string]any
+	err := json.Unmarshal(payload, &message)
+	if err != nil {
+		return payload
+	}
+	params, isMap := message["params"].(map[string]any)
+	switch {
+	case method == "setToken" && message["params"] != nil:
+		message["params"] = "***"
+	case method == "login" && isMap && params["token"] != nil:
+		params["token"] = "***"
+	default:
+		return payload
+	}
+	redacted, err := json.Marshal(message)
+	if err != nil {
+		return payload
+	}
+	return redacted
+}
+
+func (c *ClientsManager) GetQueueStats() map[uint]wsrpc.QueueStats {
+	return c.server.GetQueueStats()
+}
+
+func (c *ClientsManager) Events() *EventsManager {
+	return c.events
+}
+
 func (c *ClientsManager) GetClients() []Client {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -274,7 +417,7 @@ func (c *ClientsManager) OnUpdateScript(script *dao.ClientsScript) error {
 }
 
 func (c *ClientsManager) CallMethod(role string, method string, params map[string]any) (any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	ctx, cancel := context.WithTimeout(wsrpc.WithPriority(context.Background(), wsrpc.PRIORITY_INTERACTIVE), time.Second*20)
 	defer cancel()
 
 	clients, err := c.clientsDao.GetActiveClientsByRole(role)
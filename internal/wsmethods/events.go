@@ -0,0 +1,182 @@
+package wsmethods
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/asek-ll/aecc-server/internal/ws"
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
+)
+
+type RegisterStreamsParams struct {
+	Streams []string `json:"streams"`
+}
+
+type EventParams struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type ClientEvent struct {
+	WsClientID uint
+	ClientID   string
+	Role       string
+	Stream     string
+	Data       json.RawMessage
+	Time       time.Time
+}
+
+func (e ClientEvent) Decode(target any) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, target)
+}
+
+type EventSubscription struct {
+	id      uint
+	streams []string
+	C       <-chan ClientEvent
+	ch      chan ClientEvent
+	manager *EventsManager
+}
+
+func (s *EventSubscription) Close() {
+	s.manager.unsubscribe(s)
+}
+
+type EventsManager struct {
+	clientStreams map[uint][]string
+	subscriptions map[string]map[uint]*EventSubscription
+	subSeq        uint
+
+	mu sync.RWMutex
+}
+
+func NewEventsManager() *EventsManager {
+	return &EventsManager{
+		clientStreams: make(map[uint][]string),
+		subscriptions: make(map[string]map[uint]*EventSubscription),
+	}
+}
+
+func (m *EventsManager) setupMethods(server *wsrpc.JsonRpcServer, clientsManager *ClientsManager) {
+	server.AddMethod("registerStreams", wsrpc.Typed(func(wsClient *ws.Client, params RegisterStreamsParams) (any, error) {
+		m.mu.Lock()
+		m.clientStreams[wsClient.ID] = params.Streams
+		m.mu.Unlock()
+		log.Printf("[INFO] Client %d registered streams: %v", wsClient.ID, params.Streams)
+		return "OK", nil
+	}))
+
+	server.AddMethod("event", wsrpc.Typed(func(wsClient *ws.Client, params EventParams) (any, error) {
+		if params.Stream == "" {
+			return nil, errors.New("empty stream name")
+		}
+		event := ClientEvent{
+			WsClientID: wsClient.ID,
+			Stream:     params.Stream,
+			Data:       params.Data,
+			Time:       time.Now(),
+		}
+		client, e := clientsManager.GetClient(wsClient.ID)
+		if e {
+			generic := client.GetGenericClient()
+			event.ClientID = generic.ID
+			event.Role = generic.Role
+		}
+		m.Publish(event)
+		return nil, nil
+	}))
+}
+
+// ClientStream returns subscription key of stream of single client, plain
+// stream names are global and match events of any client
+func ClientStream(clientID string, stream string) string {
+	return clientID + "/" + stream
+}
+
+// Publish delivers event to subscribers of global stream and to subscribers
+// of stream of emitting client
+func (m *EventsManager) Publish(event ClientEvent) {
+	keys := []string{event.Stream}
+	if event.ClientID != "" {
+		keys = append(keys, ClientStream(event.ClientID, event.Stream))
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range keys {
+		for _, sub := range m.subscriptions[key] {
+			select {
+			case sub.ch <- event:
+			default:
+				log.Printf("[WARN] Drop event '%s' for subscription %d", key, sub.id)
+			}
+		}
+	}
+}
+
+// Subscribe subscribes to global stream names or to client streams made by
+// ClientStream
+func (m *EventsManager) Subscribe(streams ...string) *EventSubscription {
+	ch := make(chan ClientEvent, 16)
+
+	m.mu.Lock()
+	m.subSeq += 1
+	sub := &EventSubscription{
+		id:      m.subSeq,
+		streams: streams,
+		C:       ch,
+		ch:      ch,
+		manager: m,
+	}
+	for _, stream := range streams {
+		subs, e := m.subscriptions[stream]
+		if !e {
+			subs = make(map[uint]*EventSubscription)
+			m.subscriptions[stream] = subs
+		}
+		subs[sub.id] = sub
+	}
+	m.mu.Unlock()
+
+	return sub
+}
+
+func (m *EventsManager) unsubscribe(sub *EventSubscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stream := range sub.streams {
+		subs := m.subscriptions[stream]
+		delete(subs, sub.id)
+		if len(subs) == 0 {
+			delete(m.subscriptions, stream)
+		}
+	}
+}
+
+func (m *EventsManager) GetStreams() map[string][]uint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string][]uint)
+	for clientID, streams := range m.clientStreams {
+		for _, stream := range streams {
+			result[stream] = append(result[stream], clientID)
+		}
+	}
+	for _, clients := range result {
+		slices.Sort(clients)
+	}
+	return result
+}
+
+func (m *EventsManager) removeClient(id uint) {
+	m.mu.Lock()
+	delete(m.clientStreams, id)
+	m.mu.Unlock()
+}
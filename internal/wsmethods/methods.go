@@ -21,9 +21,11 @@ type LoginV2Params struct {
 }
 
 type LoginV3Params struct {
-	ID      int    `json:"id"`
-	Label   string `json:"label"`
-	Version string `json:"version"`
+	ID        int      `json:"id"`
+	Label     string   `json:"label"`
+	Version   string   `json:"version"`
+	Encodings []string `json:"encodings"`
+	Token     string   `json:"token"`
 }
 
 func withInnerId[T any](mapper *wsrpc.IdMapper, f func(id string, params T) (any, error)) wsrpc.RpcMethod {
@@ -41,3 +41,43 @@ func (c *ModemClient) GetMethodsRemote(ctx context.Context, name string) ([]stri
 	}
 	return res, nil
 }
+
+type CallPeripheralParams struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Args   []any  `json:"args"`
+}
+
+type RedstoneParams struct {
+	Name  string `json:"name"`
+	Side  string `json:"side"`
+	Power int    `json:"power"`
+}
+
+// callPeripheral({name, method, args}), returns first value of method
+func (c *ModemClient) CallPeripheral(ctx context.Context, params CallPeripheralParams) (any, error) {
+	var res struct {
+		Value any `json:"value"`
+	}
+	err := c.WS.SendRequestSync(ctx, "callPeripheral", params, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Value, nil
+}
+
+// setRedstone({name, side, power}), empty name is side of modem computer
+func (c *ModemClient) SetRedstone(ctx context.Context, params RedstoneParams) error {
+	var res bool
+	return c.WS.SendRequestSync(ctx, "setRedstone", params, &res)
+}
+
+// getRedstone({name, side}) returns analog output of side
+func (c *ModemClient) GetRedstone(ctx context.Context, name string, side string) (int, error) {
+	var res int
+	err := c.WS.SendRequestSync(ctx, "getRedstone", RedstoneParams{Name: name, Side: side}, &res)
+	if err != nil {
+		return 0, err
+	}
+	return res, nil
+}
@@ -23,3 +23,22 @@ func (c *ModemAdapter) GetMethodsRemote(ctx context.Context, name string) ([]str
 		return client.GetMethodsRemote(ctx, name)
 	})
 }
+
+func (c *ModemAdapter) CallPeripheral(ctx context.Context, params CallPeripheralParams) (any, error) {
+	return CallWithClientForType(c.clientsManager, func(client *ModemClient) (any, error) {
+		return client.CallPeripheral(ctx, params)
+	})
+}
+
+func (c *ModemAdapter) SetRedstone(ctx context.Context, params RedstoneParams) error {
+	_, err := CallWithClientForType(c.clientsManager, func(client *ModemClient) (bool, error) {
+		return true, client.SetRedstone(ctx, params)
+	})
+	return err
+}
+
+func (c *ModemAdapter) GetRedstone(ctx context.Context, name string, side string) (int, error) {
+	return CallWithClientForType(c.clientsManager, func(client *ModemClient) (int, error) {
+		return client.GetRedstone(ctx, name, side)
+	})
+}
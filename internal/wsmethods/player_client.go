@@ -2,8 +2,9 @@ package wsmethods
 
 import (
 	"context"
-	"sync"
 	"time"
+
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
 )
 
 type ItemStack struct {
@@ -14,8 +15,6 @@ type ItemStack struct {
 
 type PlayerClient struct {
 	GenericClient
-
-	mu sync.Mutex
 }
 
 func NewPlayerClient(base GenericClient) *PlayerClient {
@@ -25,7 +24,7 @@ func NewPlayerClient(base GenericClient) *PlayerClient {
 }
 
 func (s *PlayerClient) GetItems() (map[int]ItemStack, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(wsrpc.WithPriority(context.Background(), wsrpc.PRIORITY_INTERACTIVE), time.Second*10)
 	defer cancel()
 	var res map[int]ItemStack
 	err := s.WS.SendRequestSync(ctx, "getItems", nil, &res)
@@ -37,10 +36,7 @@ func (s *PlayerClient) GetItems() (map[int]ItemStack, error) {
 }
 
 func (s *PlayerClient) RemoveItem(slot int) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(wsrpc.WithPriority(context.Background(), wsrpc.PRIORITY_INTERACTIVE), time.Second*10)
 	defer cancel()
 	var res int
 	err := s.WS.SendRequestSync(ctx, "removeItemFromPlayer", []int{slot}, &res)
@@ -48,20 +44,14 @@ func (s *PlayerClient) RemoveItem(slot int) (int, error) {
 }
 
 func (s *PlayerClient) RemoveItems(slots []int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(wsrpc.WithPriority(context.Background(), wsrpc.PRIORITY_INTERACTIVE), time.Second*10)
 	defer cancel()
 	var res int
 	return s.WS.SendRequestSync(ctx, "removeItemFromPlayer", slots, &res)
 }
 
 func (s *PlayerClient) AddItems(slots []int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(wsrpc.WithPriority(context.Background(), wsrpc.PRIORITY_INTERACTIVE), time.Second*10)
 	defer cancel()
 	var res int
 	return s.WS.SendRequestSync(ctx, "addItemToPlayer", slots, &res)
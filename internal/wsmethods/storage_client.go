@@ -3,11 +3,12 @@ package wsmethods
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/asek-ll/aecc-server/internal/common"
+	"github.com/asek-ll/aecc-server/internal/wsrpc"
 )
 
 type StorageClient struct {
@@ -17,10 +18,10 @@ type StorageClient struct {
 	ColdStoragePrefix          string
 	WarmStoragePrefix          string
 	SingleFluidContainerPrefix string
-	TransactionStorage         string
-	TransactionTanks           []string
-
-	mu sync.Mutex
+	// TransactionStorages and TransactionTanks are pool of staging
+	// inventories and tanks of transfer transactions
+	TransactionStorages []string
+	TransactionTanks    []string
 }
 
 type ItemRef struct {
@@ -85,6 +86,53 @@ type Inventory struct {
 	Size  int             `json:"size"`
 }
 
+// CompactInventory stores slots as flat triples of slot, item dictionary index
+// (1-based, as in lua tables) and count
+type CompactInventory struct {
+	Name  string `json:"name"`
+	Size  int    `json:"size"`
+	Slots []int  `json:"slots"`
+}
+
+type CompactInventories struct {
+	Dict        []ItemRef          `json:"dict"`
+	Inventories []CompactInventory `json:"inventories"`
+}
+
+func (c CompactInventories) Expand() ([]Inventory, error) {
+	result := make([]Inventory, 0, len(c.Inventories))
+	for _, compact := range c.Inventories {
+		if len(compact.Slots)%3 != 0 {
+			return nil, fmt.Errorf("invalid compact slots for inventory %s", compact.Name)
+		}
+		inventory := Inventory{
+			Name:  compact.Name,
+			Size:  compact.Size,
+			Items: make([]StackWithSlot, 0, len(compact.Slots)/3),
+		}
+		for i := 0; i < len(compact.Slots); i += 3 {
+			idx := compact.Slots[i+1] - 1
+			if idx < 0 || idx >= len(c.Dict) {
+				return nil, fmt.Errorf("invalid item index %d for inventory %s", idx+1, compact.Name)
+			}
+			item := c.Dict[idx]
+			count := compact.Slots[i+2]
+			// compact encoding has no max count, it is resolved by item
+			// detail when needed
+			inventory.Items = append(inventory.Items, StackWithSlot{
+				Slot: compact.Slots[i],
+				Item: Stack{
+					Name:  item.Name,
+					NBT:   item.NBT,
+					Count: count,
+				},
+			})
+		}
+		result = append(result, inventory)
+	}
+	return result, nil
+}
+
 type FluidStack struct {
 	Name   string `json:"name"`
 	Amount int    `json:"amount"`
@@ -147,7 +195,7 @@ func NewStorageClient(base GenericClient) (*StorageClient, error) {
 	}
 
 	transactionTanks, _ := base.Props["transaction_tank"].(string)
-	transactionStorage, _ := base.Props["transaction_storage"].(string)
+	transactionStorages, _ := base.Props["transaction_storage"].(string)
 
 	return &StorageClient{
 		GenericClient:              base,
@@ -155,11 +203,23 @@ func NewStorageClient(base GenericClient) (*StorageClient, error) {
 		ColdStoragePrefix:          coldStoragePrefix,
 		WarmStoragePrefix:          warmStoragePrefix,
 		SingleFluidContainerPrefix: singleFluidContainerPrefix,
-		TransactionStorage:         transactionStorage,
-		TransactionTanks:           strings.Split(transactionTanks, ","),
+		TransactionStorages:        splitNames(transactionStorages),
+		TransactionTanks:           splitNames(transactionTanks),
 	}, nil
 }
 
+// splitNames splits comma separated list of peripheral names
+func splitNames(value string) []string {
+	var result []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
 type MoveStackParams struct {
 	From   SlotRef `json:"from"`
 	To     SlotRef `json:"to"`
@@ -173,12 +233,8 @@ type MoveFluidParams struct {
 	Amount int    `json:"amount"`
 }
 
-func (s *StorageClient) MoveStack(fromInventory string, fromSlot int, toInventory string, toSlot int, amount int) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
-	defer cancel()
+func (s *StorageClient) MoveStack(ctx context.Context, fromInventory string, fromSlot int, toInventory string, toSlot int, amount int) (int, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*1)
 	var moved int
 	err := s.WS.SendRequestSync(ctx, "moveStack", MoveStackParams{
 		From:   SlotRef{InventoryName: fromInventory, Slot: fromSlot},
@@ -191,12 +247,8 @@ func (s *StorageClient) MoveStack(fromInventory string, fromSlot int, toInventor
 	return moved, nil
 }
 
-func (s *StorageClient) GetStackDetail(slotRef SlotRef) (*StackDetail, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
-	defer cancel()
+func (s *StorageClient) GetStackDetail(ctx context.Context, slotRef SlotRef) (*StackDetail, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*1)
 	var detail StackDetail
 	err := s.WS.SendRequestSync(ctx, "getItemDetail", slotRef, &detail)
 	if err != nil {
@@ -205,12 +257,17 @@ func (s *StorageClient) GetStackDetail(slotRef SlotRef) (*StackDetail, error) {
 	return &detail, nil
 }
 
-func (s *StorageClient) GetItems(prefixes []string) ([]Inventory, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *StorageClient) GetItems(ctx context.Context, prefixes []string) ([]Inventory, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*20)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
+	if s.Encoding == ENCODING_COMPACT {
+		var compact CompactInventories
+		err := s.WS.SendRequestSync(ctx, "getItemsCompact", prefixes, &compact)
+		if err != nil {
+			return nil, err
+		}
+		return compact.Expand()
+	}
 
 	var res []Inventory
 	err := s.WS.SendRequestSync(ctx, "getItems", prefixes, &res)
@@ -220,12 +277,8 @@ func (s *StorageClient) GetItems(prefixes []string) ([]Inventory, error) {
 	return res, nil
 }
 
-func (s *StorageClient) ListItems(inventoryName string) ([]StackWithSlot, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
+func (s *StorageClient) ListItems(ctx context.Context, inventoryName string) ([]StackWithSlot, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*20)
 
 	var res []StackWithSlot
 	err := s.WS.SendRequestSync(ctx, "getInventoryItems", inventoryName, &res)
@@ -235,12 +288,8 @@ func (s *StorageClient) ListItems(inventoryName string) ([]StackWithSlot, error)
 	return res, nil
 }
 
-func (s *StorageClient) GetTanks(tankName string) ([]FluidTank, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
+func (s *StorageClient) GetTanks(ctx context.Context, tankName string) ([]FluidTank, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*20)
 
 	var res []FluidTank
 	err := s.WS.SendRequestSync(ctx, "getTanks", tankName, &res)
@@ -250,12 +299,8 @@ func (s *StorageClient) GetTanks(tankName string) ([]FluidTank, error) {
 	return res, nil
 }
 
-func (s *StorageClient) GetFluidContainers(prefixes []string) ([]FluidContainer, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
+func (s *StorageClient) GetFluidContainers(ctx context.Context, prefixes []string) ([]FluidContainer, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*20)
 
 	var res []FluidContainer
 	err := s.WS.SendRequestSync(ctx, "getFluidContainers", prefixes, &res)
@@ -265,12 +310,8 @@ func (s *StorageClient) GetFluidContainers(prefixes []string) ([]FluidContainer,
 	return res, nil
 }
 
-func (s *StorageClient) MoveFluid(fromContainer string, toContainer string, amount int, fluidName string) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
-	defer cancel()
+func (s *StorageClient) MoveFluid(ctx context.Context, fromContainer string, toContainer string, amount int, fluidName string) (int, error) {
+	ctx = wsrpc.WithRequestTimeout(ctx, time.Second*1)
 	var moved int
 	err := s.WS.SendRequestSync(ctx, "moveFluid", MoveFluidParams{
 		From:   fromContainer,
@@ -1,5 +1,7 @@
 package wsmethods
 
+import "context"
+
 type StorageAdapter struct {
 	clientsManager *ClientsManager
 }
@@ -16,44 +18,45 @@ func (s *StorageAdapter) GetClient() (*StorageClient, error) {
 	})
 }
 
-func (s *StorageAdapter) MoveStack(fromInventory string, fromSlot int, toInventory string, toSlot int, amount int) (int, error) {
+// MoveStack moves stack with priority of ctx, other calls are background
+func (s *StorageAdapter) MoveStack(ctx context.Context, fromInventory string, fromSlot int, toInventory string, toSlot int, amount int) (int, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) (int, error) {
-		return client.MoveStack(fromInventory, fromSlot, toInventory, toSlot, amount)
+		return client.MoveStack(ctx, fromInventory, fromSlot, toInventory, toSlot, amount)
 	})
 }
 
 func (s *StorageAdapter) GetStackDetail(slotRef SlotRef) (*StackDetail, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) (*StackDetail, error) {
-		return client.GetStackDetail(slotRef)
+		return client.GetStackDetail(context.Background(), slotRef)
 	})
 }
 
 func (s *StorageAdapter) GetItems(prefixes []string) ([]Inventory, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) ([]Inventory, error) {
-		return client.GetItems(prefixes)
+		return client.GetItems(context.Background(), prefixes)
 	})
 }
 
 func (s *StorageAdapter) ListItems(inventoryName string) ([]StackWithSlot, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) ([]StackWithSlot, error) {
-		return client.ListItems(inventoryName)
+		return client.ListItems(context.Background(), inventoryName)
 	})
 }
 
-func (s *StorageAdapter) MoveFluid(fromContainer string, toContainer string, amount int, fluidName string) (int, error) {
+func (s *StorageAdapter) MoveFluid(ctx context.Context, fromContainer string, toContainer string, amount int, fluidName string) (int, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) (int, error) {
-		return client.MoveFluid(fromContainer, toContainer, amount, fluidName)
+		return client.MoveFluid(ctx, fromContainer, toContainer, amount, fluidName)
 	})
 }
 
 func (s *StorageAdapter) GetFluidContainers(prefixes []string) ([]FluidContainer, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) ([]FluidContainer, error) {
-		return client.GetFluidContainers(prefixes)
+		return client.GetFluidContainers(context.Background(), prefixes)
 	})
 }
 
 func (s *StorageAdapter) GetTanks(name string) ([]FluidTank, error) {
 	return CallWithClientForType(s.clientsManager, func(client *StorageClient) ([]FluidTank, error) {
-		return client.GetTanks(name)
+		return client.GetTanks(context.Background(), name)
 	})
 }
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/asek-ll/aecc-server/internal/dao"
 	"github.com/asek-ll/aecc-server/internal/ws"
 	"github.com/asek-ll/aecc-server/pkg/gopool"
 )
@@ -39,8 +41,19 @@ type JsonRpcServer struct {
 	reqSeqMu          sync.RWMutex
 	reqSeq            uint
 	pending           map[uint]chan *Message
+	pendingMethods    map[uint]string
 	pool              *gopool.Pool
 	disconnectHandler func(*ws.Client) error
+
+	queuesMu    sync.Mutex
+	queues      map[uint]*clientQueue
+	maxInFlight int
+	maxQueued   int
+
+	recorder     Recorder
+	records      chan *dao.RpcRecord
+	roleResolver func(clientId uint) string
+	redactor     func(method string, payload []byte) []byte
 }
 
 func NewServer(server *ws.Server) *JsonRpcServer {
@@ -49,6 +62,10 @@ func NewServer(server *ws.Server) *JsonRpcServer {
 		wsServer: server,
 		pending:  make(map[uint]chan *Message),
 		pool:     gopool.NewPool(128, 1, 1),
+		queues:   make(map[uint]*clientQueue),
+
+		pendingMethods: make(map[uint]string),
+		maxInFlight:    1,
 	}
 
 	server.SetHandler(rpcServer)
@@ -60,6 +77,109 @@ func (h *JsonRpcServer) SetDisconnectHandler(handler func(*ws.Client) error) {
 	h.disconnectHandler = handler
 }
 
+func (h *JsonRpcServer) SetQueueLimits(maxInFlight int, maxQueued int) {
+	h.queuesMu.Lock()
+	defer h.queuesMu.Unlock()
+	if maxInFlight > 0 {
+		h.maxInFlight = maxInFlight
+	}
+	h.maxQueued = maxQueued
+}
+
+func (h *JsonRpcServer) getQueue(clientId uint) *clientQueue {
+	h.queuesMu.Lock()
+	defer h.queuesMu.Unlock()
+	queue, e := h.queues[clientId]
+	if !e {
+		queue = newClientQueue(h.maxInFlight, h.maxQueued)
+		h.queues[clientId] = queue
+	}
+	return queue
+}
+
+func (h *JsonRpcServer) GetQueueStats() map[uint]QueueStats {
+	h.queuesMu.Lock()
+	queues := make(map[uint]*clientQueue, len(h.queues))
+	for id, queue := range h.queues {
+		queues[id] = queue
+	}
+	h.queuesMu.Unlock()
+
+	result := make(map[uint]QueueStats, len(queues))
+	for id, queue := range queues {
+		result[id] = queue.stats(id)
+	}
+	return result
+}
+
+// SetRecorder enables recording of every inbound and outbound message,
+// records are written in background and dropped if recorder falls behind
+func (h *JsonRpcServer) SetRecorder(recorder Recorder) {
+	h.recorder = recorder
+	h.records = make(chan *dao.RpcRecord, 1024)
+	go func() {
+		for record := range h.records {
+			err := recorder.Record(record)
+			if err != nil {
+				log.Printf("[WARN] Can't record rpc message: %v", err)
+			}
+		}
+	}()
+}
+
+func (h *JsonRpcServer) GetRecorder() Recorder {
+	return h.recorder
+}
+
+func (h *JsonRpcServer) SetRoleResolver(resolver func(clientId uint) string) {
+	h.roleResolver = resolver
+}
+
+// SetRecordRedactor sets function to strip secrets from recorded payloads
+func (h *JsonRpcServer) SetRecordRedactor(redactor func(method string, payload []byte) []byte) {
+	h.redactor = redactor
+}
+
+func (h *JsonRpcServer) record(direction string, client *ws.Client, method string, id uint, payload []byte) {
+	if h.records == nil {
+		return
+	}
+	if h.redactor != nil {
+		payload = h.redactor(method, payload)
+	}
+	role := ""
+	if h.roleResolver != nil {
+		role = h.roleResolver(client.ID)
+	}
+	record := &dao.RpcRecord{
+		Time:       time.Now(),
+		Direction:  direction,
+		WsClientID: client.ID,
+		ClientID:   client.ExtID,
+		Role:       role,
+		Method:     method,
+		MessageID:  id,
+		Payload:    payload,
+	}
+	select {
+	case h.records <- record:
+	default:
+		log.Printf("[WARN] Rpc recorder queue is full, drop %s record for %s", direction, method)
+	}
+}
+
+func (h *JsonRpcServer) writeJSON(client *ws.Client, method string, id uint, message any) error {
+	if h.records == nil {
+		return client.WriteJSON(message)
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	h.record(RECORD_DIRECTION_OUT, client, method, id, data)
+	return client.WriteText(data)
+}
+
 func (h *JsonRpcServer) AddMethod(name string, method RpcMethod) {
 	h.methods[name] = method
 }
@@ -72,6 +192,18 @@ func (h *JsonRpcServer) HandleMessage(content []byte, client *ws.Client) error {
 		return nil
 	}
 
+	if h.records != nil {
+		method := ""
+		if msg.Method != nil {
+			method = *msg.Method
+		} else {
+			h.reqSeqMu.RLock()
+			method = h.pendingMethods[msg.ID]
+			h.reqSeqMu.RUnlock()
+		}
+		h.record(RECORD_DIRECTION_IN, client, method, msg.ID, content)
+	}
+
 	if msg.Method != nil && msg.Result == nil && msg.Error == nil {
 		m, e := h.methods[*msg.Method]
 		if !e {
@@ -99,7 +231,7 @@ func (h *JsonRpcServer) HandleMessage(content []byte, client *ws.Client) error {
 				}
 			}
 			if response != nil {
-				err := client.WriteJSON(*response)
+				err := h.writeJSON(client, *msg.Method, msg.ID, *response)
 				if err != nil {
 					log.Printf("[ERROR] Error on method handle: %s, %v", *msg.Method, err)
 				}
@@ -143,7 +275,7 @@ func (h *JsonRpcServer) SendRequest(clientId uint, method string, params any) (u
 		Params:  params,
 	}
 
-	err := client.WriteJSON(request)
+	err := h.writeJSON(client, method, reqId, request)
 
 	if err != nil {
 		return 0, err
@@ -159,14 +291,35 @@ func (h *JsonRpcServer) SendRequestSync(ctx context.Context, clientId uint, meth
 	}
 	log.Printf("[INFO] Client call %d %s %v", clientId, method, params)
 
-	done := make(chan *Message)
+	// request timeout starts once the request leaves the queue
+	queue := h.getQueue(clientId)
+	err := queue.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer queue.release()
+	if timeout, e := requestTimeoutFromContext(ctx); e {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan *Message, 1)
 
 	h.reqSeqMu.Lock()
 	reqId := h.reqSeq
 	h.reqSeq = (h.reqSeq + 1) % 1000000
 	h.pending[reqId] = done
+	h.pendingMethods[reqId] = method
 	h.reqSeqMu.Unlock()
 
+	defer func() {
+		h.reqSeqMu.Lock()
+		delete(h.pending, reqId)
+		delete(h.pendingMethods, reqId)
+		h.reqSeqMu.Unlock()
+	}()
+
 	request := Request{
 		JsonRpc: "2.0",
 		ID:      reqId,
@@ -174,19 +327,13 @@ func (h *JsonRpcServer) SendRequestSync(ctx context.Context, clientId uint, meth
 		Params:  params,
 	}
 
-	err := client.WriteJSON(request)
+	err = h.writeJSON(client, method, reqId, request)
 	if err != nil {
-		h.reqSeqMu.Lock()
-		delete(h.pending, reqId)
-		h.reqSeqMu.Unlock()
 		return err
 	}
 
 	select {
 	case <-ctx.Done():
-		h.reqSeqMu.Lock()
-		delete(h.pending, reqId)
-		h.reqSeqMu.Unlock()
 		return errors.New("Timeout")
 	case msg := <-done:
 
@@ -206,7 +353,18 @@ func (h *JsonRpcServer) SendRequestSync(ctx context.Context, clientId uint, meth
 	return nil
 }
 
+func (h *JsonRpcServer) CloseClient(clientId uint) error {
+	return h.wsServer.CloseClient(clientId)
+}
+
 func (h *JsonRpcServer) HandleDisconnect(client *ws.Client) {
+	h.queuesMu.Lock()
+	if queue, e := h.queues[client.ID]; e {
+		queue.close()
+		delete(h.queues, client.ID)
+	}
+	h.queuesMu.Unlock()
+
 	if h.disconnectHandler != nil {
 		err := h.disconnectHandler(client)
 		if err != nil {
@@ -0,0 +1,197 @@
+package wsrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const PRIORITY_INTERACTIVE = 0
+const PRIORITY_BACKGROUND = 1
+
+const prioritiesCount = 2
+
+var ErrQueueFull = errors.New("client queue is full")
+var ErrQueueClosed = errors.New("client disconnected")
+
+type priorityKey struct{}
+type requestTimeoutKey struct{}
+
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// WithRequestTimeout sets timeout of request which starts once request leaves
+// client queue, time in queue is limited only by ctx
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
+
+func requestTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration)
+	return timeout, ok && timeout > 0
+}
+
+func priorityFromContext(ctx context.Context) int {
+	priority, ok := ctx.Value(priorityKey{}).(int)
+	if !ok || priority < 0 || priority >= prioritiesCount {
+		return PRIORITY_BACKGROUND
+	}
+	return priority
+}
+
+type QueueStats struct {
+	ClientID    uint    `json:"clientId"`
+	Limit       int     `json:"limit"`
+	InFlight    int     `json:"inFlight"`
+	Interactive int     `json:"interactive"`
+	Background  int     `json:"background"`
+	MaxDepth    int     `json:"maxDepth"`
+	Completed   uint64  `json:"completed"`
+	Rejected    uint64  `json:"rejected"`
+	AvgWaitMs   float64 `json:"avgWaitMs"`
+}
+
+func (s QueueStats) Depth() int {
+	return s.Interactive + s.Background
+}
+
+type clientQueue struct {
+	limit     int
+	maxQueued int
+	inFlight  int
+	lanes     [prioritiesCount][]chan struct{}
+	next      int
+	closed    chan struct{}
+
+	maxDepth  int
+	completed uint64
+	rejected  uint64
+	waitTotal time.Duration
+
+	mu sync.Mutex
+}
+
+func newClientQueue(limit int, maxQueued int) *clientQueue {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &clientQueue{
+		limit:     limit,
+		maxQueued: maxQueued,
+		closed:    make(chan struct{}),
+	}
+}
+
+func (q *clientQueue) depth() int {
+	depth := 0
+	for _, lane := range q.lanes {
+		depth += len(lane)
+	}
+	return depth
+}
+
+func (q *clientQueue) acquire(ctx context.Context) error {
+	priority := priorityFromContext(ctx)
+	start := time.Now()
+
+	q.mu.Lock()
+	if q.inFlight < q.limit && q.depth() == 0 {
+		q.inFlight += 1
+		q.mu.Unlock()
+		return nil
+	}
+	if q.maxQueued > 0 && priority == PRIORITY_BACKGROUND && len(q.lanes[priority]) >= q.maxQueued {
+		q.rejected += 1
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+	ticket := make(chan struct{})
+	q.lanes[priority] = append(q.lanes[priority], ticket)
+	q.maxDepth = max(q.maxDepth, q.depth())
+	q.mu.Unlock()
+
+	var cause error
+	select {
+	case <-ticket:
+		q.mu.Lock()
+		q.waitTotal += time.Since(start)
+		q.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		cause = ctx.Err()
+	case <-q.closed:
+		cause = ErrQueueClosed
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, t := range q.lanes[priority] {
+		if t == ticket {
+			q.lanes[priority] = append(q.lanes[priority][:i], q.lanes[priority][i+1:]...)
+			q.rejected += 1
+			return cause
+		}
+	}
+	// ticket was granted concurrently with cancellation
+	q.inFlight -= 1
+	q.completed += 1
+	q.dispatch()
+	return cause
+}
+
+// close fails waiting requests of disconnected client
+func (q *clientQueue) close() {
+	close(q.closed)
+}
+
+func (q *clientQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight -= 1
+	q.completed += 1
+	q.dispatch()
+}
+
+// dispatch grants free slots to waiting requests, alternating between
+// priorities so that neither lane can monopolise the client
+func (q *clientQueue) dispatch() {
+	for q.inFlight < q.limit {
+		lane := -1
+		for i := 0; i < prioritiesCount; i += 1 {
+			candidate := (q.next + i) % prioritiesCount
+			if len(q.lanes[candidate]) > 0 {
+				lane = candidate
+				break
+			}
+		}
+		if lane < 0 {
+			return
+		}
+		ticket := q.lanes[lane][0]
+		q.lanes[lane] = q.lanes[lane][1:]
+		q.next = (lane + 1) % prioritiesCount
+		q.inFlight += 1
+		close(ticket)
+	}
+}
+
+func (q *clientQueue) stats(clientID uint) QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		ClientID:    clientID,
+		Limit:       q.limit,
+		InFlight:    q.inFlight,
+		Interactive: len(q.lanes[PRIORITY_INTERACTIVE]),
+		Background:  len(q.lanes[PRIORITY_BACKGROUND]),
+		MaxDepth:    q.maxDepth,
+		Completed:   q.completed,
+		Rejected:    q.rejected,
+	}
+	if q.completed > 0 {
+		stats.AvgWaitMs = float64(q.waitTotal.Milliseconds()) / float64(q.completed)
+	}
+	return stats
+}
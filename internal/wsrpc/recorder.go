@@ -0,0 +1,162 @@
+package wsrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/asek-ll/aecc-server/internal/dao"
+)
+
+const RECORD_DIRECTION_IN = "in"
+const RECORD_DIRECTION_OUT = "out"
+
+type Recorder interface {
+	Record(record *dao.RpcRecord) error
+	Query(filter dao.RpcRecordFilter) ([]dao.RpcRecord, error)
+}
+
+var _ Recorder = &dao.RpcRecordsDao{}
+var _ Recorder = &FileRecorder{}
+
+// FileRecorder writes records as json lines and rotates file when it exceeds
+// maxSize, keeping up to maxFiles rotated files as path.1, path.2, ...
+type FileRecorder struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+
+	mu sync.Mutex
+}
+
+func NewFileRecorder(path string, maxSize int64, maxFiles int) (*FileRecorder, error) {
+	recorder := &FileRecorder{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: max(maxFiles, 1),
+	}
+	err := recorder.open()
+	if err != nil {
+		return nil, err
+	}
+	return recorder, nil
+}
+
+func (r *FileRecorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = stat.Size()
+	return nil
+}
+
+func (r *FileRecorder) rotatedPath(idx int) string {
+	return fmt.Sprintf("%s.%d", r.path, idx)
+}
+
+func (r *FileRecorder) rotate() error {
+	err := r.file.Close()
+	if err != nil {
+		return err
+	}
+	for i := r.maxFiles - 1; i > 0; i -= 1 {
+		err := os.Rename(r.rotatedPath(i), r.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = os.Rename(r.path, r.rotatedPath(1))
+	if err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *FileRecorder) Record(record *dao.RpcRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size+int64(len(data)) > r.maxSize {
+		err := r.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(data)
+	r.size += int64(n)
+	return err
+}
+
+func (r *FileRecorder) Query(filter dao.RpcRecordFilter) ([]dao.RpcRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var paths []string
+	for i := r.maxFiles; i > 0; i -= 1 {
+		paths = append(paths, r.rotatedPath(i))
+	}
+	paths = append(paths, r.path)
+
+	var result []dao.RpcRecord
+	for _, path := range paths {
+		records, err := ReadRecordsFile(path, filter)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, records...)
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+
+	return result, nil
+}
+
+func ReadRecordsFile(path string, filter dao.RpcRecordFilter) ([]dao.RpcRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []dao.RpcRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record dao.RpcRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			log.Printf("[WARN] Skip invalid record in %s: %v", path, err)
+			continue
+		}
+		if filter.Match(&record) {
+			result = append(result, record)
+		}
+	}
+
+	return result, scanner.Err()
+}